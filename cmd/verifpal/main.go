@@ -11,10 +11,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"verifpal.com/cmd/vplogic"
@@ -22,6 +30,15 @@ import (
 
 const version = "0.27.4"
 
+// Exit codes of the verify command.
+const (
+	exitVerified     = 0
+	exitAttackFound  = 1
+	exitInconclusive = 2
+	exitError        = 3
+	exitUnexpected   = 4
+)
+
 var rootCmd = &cobra.Command{
 	Use:     "verifpal",
 	Version: version,
@@ -36,21 +53,80 @@ var cmdVerify = &cobra.Command{
 	Long: strings.Join([]string{
 		"`verify` loads a Verifpal model from the given file path and analyzes it using Verifpal's analysis logic.",
 		"Output is displayed in the terminal as the model is being analyzed.",
+		"Exits with status 0 if all queries pass, 1 if any query fails, 2 if any query is inconclusive,",
+		"3 if the model cannot be analyzed and 4 if the results code does not match --expect.",
 	}, "\n"),
 	Args:       cobra.ExactArgs(1),
 	Hidden:     false,
 	SuggestFor: []string{"analyze", "run"},
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Fprintf(os.Stdout, "Verifpal %s - https://verifpal.com", version)
-		fmt.Fprintf(os.Stdout, "\n")
-		vplogic.InfoMessage("Verifpal is Beta software.",
-			"warning", false,
-		)
-		vplogic.VerifHubScheduledShared, _ = cmd.Flags().GetBool("verifhub")
-		_, _, err := vplogic.Verify(args[0])
-		if err != nil {
-			log.Fatal(err)
-		}
+		os.Exit(verifyRun(cmd, args))
+	},
+}
+
+var cmdTest = &cobra.Command{
+	Use:     "test [dir...]",
+	Example: "  verifpal test examples/test",
+	Short:   "check Verifpal models against their expected results",
+	Long: strings.Join([]string{
+		"`test` verifies every Verifpal model found in the given directories (or the current directory),",
+		"and compares the outcome of each query annotated with `// expect: pass` or `// expect: fail`",
+		"with its expected outcome. Models without annotated queries are skipped.",
+		"Exits with status 0 if all annotated queries have their expected outcome, and 1 otherwise.",
+	}, "\n"),
+	Hidden: false,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(testRun(cmd, args))
+	},
+}
+
+var cmdBatch = &cobra.Command{
+	Use:     "batch [model.vp|glob|dir...]",
+	Example: "  verifpal batch --parallel 4 --json results.json examples/test '*.vp'",
+	Short:   "analyze many Verifpal models in parallel",
+	Long: strings.Join([]string{
+		"`batch` analyzes every Verifpal model given as a file, glob or directory (searched recursively),",
+		"running several analyses in parallel, and prints a table of the results of each query.",
+		"Exits with status 3 if any model cannot be analyzed, otherwise 1 if any query fails,",
+		"otherwise 2 if any query is inconclusive, and otherwise 0.",
+	}, "\n"),
+	Args:   cobra.MinimumNArgs(1),
+	Hidden: false,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(batchRun(cmd, args))
+	},
+}
+
+var cmdHistory = &cobra.Command{
+	Use:     "history [model.vp...]",
+	Example: "  verifpal history examples/simple.vp",
+	Short:   "list archived verification results and compare them over time",
+	Long: strings.Join([]string{
+		"`history` lists the results archived by `verify --archive` and `batch --archive`, from oldest to newest,",
+		"optionally only for the models with the same file names as those given, and compares each result with the previous result for the same model,",
+		"showing whether the model changed and which queries changed status.",
+		"Exits with status 3 if the archive cannot be read, and otherwise 0.",
+	}, "\n"),
+	Hidden: false,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(historyRun(cmd, args))
+	},
+}
+
+var cmdLint = &cobra.Command{
+	Use:     "lint [model.vp|glob|dir...]",
+	Example: "  verifpal lint examples/simple.vp",
+	Short:   "check Verifpal models for likely modelling mistakes",
+	Long: strings.Join([]string{
+		"`lint` checks every Verifpal model given as a file, glob or directory (searched recursively)",
+		"for likely modelling mistakes which are not errors, such as values which are generated but never used,",
+		"or checkable primitives whose results are used without being checked, and prints a warning for each.",
+		"Exits with status 3 if any model cannot be checked, otherwise 1 if there are warnings, and otherwise 0.",
+	}, "\n"),
+	Args:   cobra.MinimumNArgs(1),
+	Hidden: false,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(lintRun(args))
 	},
 }
 
@@ -85,7 +161,8 @@ var cmdTranslateCoq = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		err := vplogic.Coq(args[0])
 		if err != nil {
-			log.Fatal(err)
+			fmt.Fprint(os.Stderr, modelErrorMessage(args[0], err))
+			os.Exit(1)
 		}
 	},
 }
@@ -107,7 +184,8 @@ var cmdTranslatePv = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		err := vplogic.Pv(args[0])
 		if err != nil {
-			log.Fatal(err)
+			fmt.Fprint(os.Stderr, modelErrorMessage(args[0], err))
+			os.Exit(1)
 		}
 	},
 }
@@ -126,6 +204,81 @@ var cmdPretty = &cobra.Command{
 	Hidden:                false,
 	Run: func(cmd *cobra.Command, args []string) {
 		err := vplogic.PrettyPrint(args[0])
+		if err != nil {
+			fmt.Fprint(os.Stderr, modelErrorMessage(args[0], err))
+			os.Exit(1)
+		}
+	},
+}
+
+var cmdDiagram = &cobra.Command{
+	Use:     "diagram [model.vp]",
+	Example: "  verifpal diagram --format mermaid --results results.json examples/simple.vp",
+	Short:   "render Verifpal model as a sequence diagram",
+	Long: strings.Join([]string{
+		"`diagram` loads a Verifpal model from the given file path and renders it as a sequence diagram",
+		"in Mermaid, PlantUML, Graphviz (dot) or js-sequence-diagrams (seqdiag) syntax,",
+		"with principals as lifelines, principal blocks as notes and phases as separators.",
+		"With `--results`, the values mutated by the attacker in the attacks found by `verify --format json`",
+		"are shown on the messages carrying them.",
+	}, "\n"),
+	Args:   cobra.ExactArgs(1),
+	Hidden: false,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(diagramRun(cmd, args))
+	},
+}
+
+var cmdFmt = &cobra.Command{
+	Use:     "fmt [model.vp|glob|dir...]",
+	Example: "  verifpal fmt --check examples",
+	Short:   "rewrite Verifpal models in their canonical format",
+	Long: strings.Join([]string{
+		"`fmt` rewrites every Verifpal model given as a file, glob or directory (searched recursively)",
+		"in the canonical format printed by `pretty`, keeping its comments.",
+		"With `--check`, models are left unchanged and a diff is printed for each model which is not formatted.",
+		"Exits with status 3 if any model cannot be formatted, otherwise 1 if `--check` finds a model",
+		"which is not formatted, and otherwise 0.",
+	}, "\n"),
+	Args:   cobra.MinimumNArgs(1),
+	Hidden: false,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(fmtRun(cmd, args))
+	},
+}
+
+var cmdServe = &cobra.Command{
+	Use:     "serve",
+	Example: "  verifpal serve --addr localhost:8080 --parallel 2",
+	Short:   "run a local HTTP API server for checking, translating and verifying models",
+	Long: strings.Join([]string{
+		"`serve` runs an HTTP API server which checks, pretty-prints, diagrams and translates Verifpal models",
+		"sent as request bodies, and verifies them as asynchronous jobs which can be polled and cancelled.",
+		"See USAGE.md for the endpoints and their responses.",
+	}, "\n"),
+	Args:   cobra.ExactArgs(0),
+	Hidden: false,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(serveRun(cmd))
+	},
+}
+
+var cmdLsp = &cobra.Command{
+	Use:     "lsp",
+	Example: "  verifpal lsp",
+	Short:   "run a Language Server Protocol server over standard input and output",
+	Long: strings.Join([]string{
+		"`lsp` runs a Language Server Protocol server, communicating with an editor over standard input and output.",
+		"The server reports errors in Verifpal models as they are edited, completes primitive names,",
+		"describes constants on hover, finds the definition of and references to constants and principals,",
+		"and formats models.",
+	}, "\n"),
+	DisableFlagsInUseLine: true,
+	DisableFlagParsing:    true,
+	Args:                  cobra.ExactArgs(0),
+	Hidden:                false,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := vplogic.LSP(os.Stdin, os.Stdout)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -187,12 +340,449 @@ var cmdJSON = &cobra.Command{
 	},
 }
 
+func verifyRun(cmd *cobra.Command, args []string) int {
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "text":
+		fmt.Fprintf(os.Stdout, "Verifpal %s - https://verifpal.com", version)
+		fmt.Fprintf(os.Stdout, "\n")
+		vplogic.InfoMessage("Verifpal is Beta software.",
+			"warning", 0,
+		)
+	case "json", "sarif", "junit":
+	default:
+		log.Print(fmt.Errorf("invalid output format (%s)", format))
+		return exitError
+	}
+	opts := vplogic.DefaultOptions()
+	opts.Sinks = verifySinks(cmd)
+	opts.Workers, _ = cmd.Flags().GetInt("workers")
+	opts.Deterministic, _ = cmd.Flags().GetBool("deterministic")
+	reports := vplogic.NewReportCollector()
+	if format != "text" {
+		opts.Output = nil
+		opts.Color = false
+		opts.Observers = append(opts.Observers, reports)
+	}
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	ctx, cancel := verifyContext(timeout)
+	valVerifyResults, resultsCode, err := vplogic.VerifyFile(ctx, args[0], opts)
+	interrupted := ctx.Err() != nil
+	cancel()
+	var sinkErr *vplogic.SinkError
+	if err != nil && !interrupted && !errors.As(err, &sinkErr) {
+		fmt.Fprint(os.Stderr, modelErrorMessage(args[0], err))
+		return exitError
+	}
+	reportsErr := verifyWriteReports(format, args[0], reports.Reports())
+	if reportsErr != nil {
+		log.Print(reportsErr)
+		return exitError
+	}
+	if sinkErr != nil {
+		log.Print(err)
+		return exitError
+	}
+	expect, _ := cmd.Flags().GetString("expect")
+	if len(expect) > 0 {
+		if resultsCode != expect {
+			log.Printf("expected results code %s, got %s", expect, resultsCode)
+			return exitUnexpected
+		}
+		return exitVerified
+	}
+	return verifyExitCode(valVerifyResults)
+}
+
+func verifySinks(cmd *cobra.Command) []vplogic.ResultsSink {
+	sinks := []vplogic.ResultsSink{}
+	archive, _ := cmd.Flags().GetString("archive")
+	if len(archive) > 0 {
+		sinks = append(sinks, vplogic.NewArchiveSink(archive))
+	}
+	verifHub, _ := cmd.Flags().GetBool("verifhub")
+	if verifHub {
+		sinks = append(sinks, vplogic.NewVerifHubSink())
+	}
+	return sinks
+}
+
+func verifyWriteReports(format string, filePath string, reports []vplogic.Report) error {
+	var err error
+	switch format {
+	case "json":
+		for _, r := range reports {
+			err = vplogic.WriteReportJSON(os.Stdout, r)
+			if err != nil {
+				return err
+			}
+		}
+	case "sarif":
+		for _, r := range reports {
+			err = vplogic.WriteReportSARIF(os.Stdout, r, filepath.ToSlash(filePath))
+			if err != nil {
+				return err
+			}
+		}
+	case "junit":
+		err = vplogic.WriteReportsJUnit(os.Stdout, reports)
+	}
+	return err
+}
+
+func verifyExitCode(valVerifyResults []vplogic.VerifyResult) int {
+	exitCode := exitVerified
+	for _, verifyResult := range valVerifyResults {
+		switch verifyResult.Status {
+		case vplogic.VerifyStatusAttackFound:
+			return exitAttackFound
+		case vplogic.VerifyStatusInconclusive:
+			exitCode = exitInconclusive
+		}
+	}
+	return exitCode
+}
+
+func testRun(cmd *cobra.Command, args []string) int {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	filePaths, err := modelFilePaths(args)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	opts := vplogic.DefaultOptions()
+	opts.Output = nil
+	opts.Workers, _ = cmd.Flags().GetInt("workers")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	expectResults := []vplogic.ExpectResult{}
+	failed := 0
+	errored := 0
+	skipped := 0
+	for _, filePath := range filePaths {
+		ctx, cancel := verifyContext(timeout)
+		modelResults, err := vplogic.VerifyFileExpect(ctx, filePath, opts)
+		cancel()
+		switch {
+		case err != nil:
+			fmt.Fprint(os.Stderr, modelErrorMessage(filePath, err))
+			errored = errored + 1
+		case len(modelResults) == 0:
+			skipped = skipped + 1
+		}
+		for _, expectResult := range modelResults {
+			if !expectResult.Pass {
+				failed = failed + 1
+			}
+		}
+		expectResults = append(expectResults, modelResults...)
+	}
+	err = vplogic.WriteExpectResults(os.Stdout, expectResults)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	fmt.Fprintf(os.Stdout,
+		"\n%d queries checked, %d failed. %d models could not be verified, %d without expectations were skipped.\n",
+		len(expectResults), failed, errored, skipped,
+	)
+	if failed > 0 || errored > 0 {
+		return exitAttackFound
+	}
+	return exitVerified
+}
+
+func batchRun(cmd *cobra.Command, args []string) int {
+	filePaths, err := modelFilePaths(args)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	opts := vplogic.DefaultOptions()
+	opts.Output = nil
+	opts.Color = false
+	opts.Workers, _ = cmd.Flags().GetInt("workers")
+	opts.Sinks = verifySinks(cmd)
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	batchReport := vplogic.VerifyBatch(context.Background(), filePaths, parallel, timeout, opts)
+	err = vplogic.WriteBatchReport(os.Stdout, batchReport)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	jsonPath, _ := cmd.Flags().GetString("json")
+	if len(jsonPath) > 0 {
+		err = batchWriteJSON(jsonPath, batchReport)
+		if err != nil {
+			log.Print(err)
+			return exitError
+		}
+	}
+	exitCode := exitVerified
+	for _, batchResult := range batchReport.Results {
+		switch {
+		case batchResult.Report == nil || len(batchResult.Error) > 0:
+			exitCode = exitError
+		case exitCode == exitError:
+		case batchResult.Report.Status == vplogic.VerifyStatusAttackFound:
+			exitCode = exitAttackFound
+		case batchResult.Report.Status == vplogic.VerifyStatusInconclusive && exitCode == exitVerified:
+			exitCode = exitInconclusive
+		}
+	}
+	return exitCode
+}
+
+func serveRun(cmd *cobra.Command) int {
+	opts := vplogic.DefaultOptions()
+	opts.Output = nil
+	opts.Color = false
+	opts.Workers, _ = cmd.Flags().GetInt("workers")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	addr, _ := cmd.Flags().GetString("addr")
+	jobTTL, _ := cmd.Flags().GetDuration("job-ttl")
+	server := vplogic.NewServer(parallel, jobTTL, opts)
+	defer server.Close()
+	log.Printf("Listening on %s.", addr)
+	err := server.HTTPServer(addr).ListenAndServe()
+	log.Print(err)
+	return exitError
+}
+
+func historyRun(cmd *cobra.Command, args []string) int {
+	archive, _ := cmd.Flags().GetString("archive")
+	entries, skipped, err := vplogic.ReadArchive(archive)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	for _, fileName := range skipped {
+		log.Printf("skipping %s, which does not hold archived results", fileName)
+	}
+	if len(args) > 0 {
+		models := map[string]bool{}
+		for _, arg := range args {
+			models[filepath.Base(arg)] = true
+		}
+		filtered := []vplogic.ArchiveEntry{}
+		for _, entry := range entries {
+			if models[entry.Model] {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	err = vplogic.WriteHistory(os.Stdout, entries)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	return exitVerified
+}
+
+func diagramRun(cmd *cobra.Command, args []string) int {
+	format, _ := cmd.Flags().GetString("format")
+	if !slices.Contains(vplogic.DiagramFormats, format) {
+		log.Print(fmt.Errorf("invalid diagram format (%s)", format))
+		return exitError
+	}
+	var report *vplogic.Report
+	resultsPath, _ := cmd.Flags().GetString("results")
+	if len(resultsPath) > 0 {
+		b, err := os.ReadFile(resultsPath)
+		if err != nil {
+			log.Print(err)
+			return exitError
+		}
+		report = &vplogic.Report{}
+		err = json.Unmarshal(b, report)
+		if err != nil {
+			log.Print(fmt.Errorf("invalid results in %s (%v)", resultsPath, err))
+			return exitError
+		}
+	}
+	err := vplogic.DiagramPrint(args[0], format, report)
+	if err != nil {
+		fmt.Fprint(os.Stderr, modelErrorMessage(args[0], err))
+		return exitError
+	}
+	return exitVerified
+}
+
+func lintRun(args []string) int {
+	filePaths, err := modelFilePaths(args)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	exitCode := exitVerified
+	for _, filePath := range filePaths {
+		warnings, err := vplogic.Lint(filePath)
+		if err != nil {
+			fmt.Fprint(os.Stderr, modelErrorMessage(filePath, err))
+			exitCode = exitError
+			continue
+		}
+		err = vplogic.WriteLintWarnings(os.Stdout, filePath, warnings)
+		if err != nil {
+			log.Print(err)
+			return exitError
+		}
+		if len(warnings) > 0 && exitCode == exitVerified {
+			exitCode = exitAttackFound
+		}
+	}
+	return exitCode
+}
+
+func fmtRun(cmd *cobra.Command, args []string) int {
+	filePaths, err := modelFilePaths(args)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	check, _ := cmd.Flags().GetBool("check")
+	exitCode := exitVerified
+	for _, filePath := range filePaths {
+		original, formatted, err := vplogic.FormatFile(filePath)
+		if err != nil {
+			fmt.Fprint(os.Stderr, modelErrorMessage(filePath, err))
+			exitCode = exitError
+			continue
+		}
+		if bytes.Equal(original, formatted) {
+			continue
+		}
+		if check {
+			fmt.Fprint(os.Stdout, vplogic.FormatDiff(strings.TrimPrefix(filepath.ToSlash(filePath), "/"), original, formatted))
+			if exitCode == exitVerified {
+				exitCode = exitAttackFound
+			}
+			continue
+		}
+		err = fmtWriteFile(filePath, formatted)
+		if err != nil {
+			fmt.Fprint(os.Stderr, modelErrorMessage(filePath, err))
+			exitCode = exitError
+		}
+	}
+	return exitCode
+}
+
+func fmtWriteFile(filePath string, formatted []byte) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, formatted, info.Mode().Perm())
+}
+
+func batchWriteJSON(jsonPath string, batchReport vplogic.BatchReport) error {
+	if jsonPath == "-" {
+		return vplogic.WriteBatchReportJSON(os.Stdout, batchReport)
+	}
+	f, err := os.Create(jsonPath)
+	if err != nil {
+		return err
+	}
+	err = vplogic.WriteBatchReportJSON(f, batchReport)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// modelFilePaths expands model files, globs and directories into the paths
+// of the model files they designate, searching directories recursively.
+func modelFilePaths(args []string) ([]string, error) {
+	filePaths := []string{}
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return filePaths, err
+		}
+		if len(matches) == 0 {
+			return filePaths, fmt.Errorf("no such file or directory (%s)", arg)
+		}
+		for _, match := range matches {
+			err = filepath.WalkDir(match, func(filePath string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if filePath == match && !d.IsDir() {
+					filePaths = append(filePaths, filePath)
+				} else if !d.IsDir() && filepath.Ext(filePath) == ".vp" {
+					filePaths = append(filePaths, filePath)
+				}
+				return nil
+			})
+			if err != nil {
+				return filePaths, err
+			}
+		}
+	}
+	return filePaths, nil
+}
+
+// modelErrorMessage describes an error which occurred on the model at filePath.
+// Errors found at a known position in the model are followed by the line on
+// which they occur, with a caret under the column at which they occur.
+func modelErrorMessage(filePath string, err error) string {
+	var modelErr *vplogic.ModelError
+	if !errors.As(err, &modelErr) {
+		return fmt.Sprintf("%s: %v\n", filePath, err)
+	}
+	if modelErr.Position.Line == 0 {
+		return fmt.Sprintf("%s: %v\n", filePath, modelErr.Err)
+	}
+	message := fmt.Sprintf(
+		"%s:%d:%d: %v\n", filePath,
+		modelErr.Position.Line, modelErr.Position.Column, modelErr.Err,
+	)
+	source, readErr := os.ReadFile(filePath)
+	if readErr != nil {
+		return message
+	}
+	return message + modelErr.Excerpt(source)
+}
+
+func verifyContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
 func main() {
 	cmdVerify.Flags().BoolP("verifhub", "", false, "submit to VerifHub upon analysis completion")
+	cmdVerify.Flags().StringP("archive", "", "", "archive the results in the given directory upon analysis completion (e.g. "+vplogic.ArchiveDefaultDir+")")
+	cmdVerify.Flags().DurationP("timeout", "", 0, "stop analysis after the given duration (e.g. 30m) and report partial results")
+	cmdVerify.Flags().IntP("workers", "", 0, "number of concurrent analysis workers (defaults to GOMAXPROCS)")
+	cmdVerify.Flags().StringP("expect", "", "", "exit with status 0 if the results code matches the given one (e.g. c0a1), and 4 otherwise")
+	cmdVerify.Flags().StringP("format", "", "text", "output format: text, json, sarif or junit")
+	cmdVerify.Flags().BoolP("deterministic", "", false, "analyze in a fixed order so that results and attack traces are reproducible")
+	cmdTest.Flags().DurationP("timeout", "", 0, "stop the analysis of each model after the given duration (e.g. 30m)")
+	cmdTest.Flags().IntP("workers", "", 0, "number of concurrent analysis workers (defaults to GOMAXPROCS)")
+	cmdBatch.Flags().IntP("parallel", "", 0, "number of models analyzed in parallel (defaults to GOMAXPROCS)")
+	cmdBatch.Flags().StringP("json", "", "", "write an aggregated JSON report of all models to the given file (- for standard output)")
+	cmdBatch.Flags().DurationP("timeout", "", 0, "stop the analysis of each model after the given duration (e.g. 30m)")
+	cmdBatch.Flags().IntP("workers", "", 0, "number of concurrent analysis workers per model (defaults to GOMAXPROCS)")
+	cmdBatch.Flags().StringP("archive", "", "", "archive the results of each model in the given directory (e.g. "+vplogic.ArchiveDefaultDir+")")
+	cmdHistory.Flags().StringP("archive", "", vplogic.ArchiveDefaultDir, "directory in which results are archived")
+	cmdServe.Flags().StringP("addr", "", ":8080", "address on which to listen for requests")
+	cmdServe.Flags().IntP("parallel", "", 0, "number of verification jobs run in parallel (defaults to GOMAXPROCS)")
+	cmdServe.Flags().IntP("workers", "", 0, "number of concurrent analysis workers per job (defaults to GOMAXPROCS)")
+	cmdServe.Flags().DurationP("job-ttl", "", time.Hour, "how long verification jobs are kept once they have ended")
+	cmdDiagram.Flags().StringP("format", "", "seqdiag", "diagram format: "+strings.Join(vplogic.DiagramFormats, ", "))
+	cmdDiagram.Flags().StringP("results", "", "", "show the attacker's mutations from the results written by verify --format json to the given file")
+	cmdFmt.Flags().BoolP("check", "", false, "print a diff for each model which is not formatted instead of rewriting it")
 	cmdTranslate.AddCommand(cmdTranslateCoq, cmdTranslatePv)
-	rootCmd.AddCommand(cmdVerify, cmdTranslate, cmdPretty, cmdAbout, cmdJSON)
+	rootCmd.AddCommand(cmdVerify, cmdTest, cmdBatch, cmdHistory, cmdLint, cmdTranslate, cmdPretty, cmdDiagram, cmdFmt, cmdLsp, cmdServe, cmdAbout, cmdJSON)
 	err := rootCmd.Execute()
 	if err != nil {
-		log.Fatal(err)
+		os.Exit(exitError)
 	}
 }
@@ -5,302 +5,75 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 
 	"verifpal.com/cmd/vplogic"
 )
 
-type VerifpalTest struct {
-	Model       string
-	ResultsCode string
-}
-
-var verifpalTests = [61]VerifpalTest{
-	{
-		Model:       "challengeresponse.vp",
-		ResultsCode: "a0a1",
-	},
-	{
-		Model:       "ephemerals_sign.vp",
-		ResultsCode: "c1a1",
-	},
-	{
-		Model:       "hmac_ok.vp",
-		ResultsCode: "c0a0",
-	},
-	{
-		Model:       "hmac_unchecked_assert.vp",
-		ResultsCode: "c0a1",
-	},
-	{
-		Model:       "hmac_unguarded_alice.vp",
-		ResultsCode: "c0a1",
-	},
-	{
-		Model:       "hmac_unguarded_bob.vp",
-		ResultsCode: "c1a0",
-	},
-	{
-		Model:       "ok.vp",
-		ResultsCode: "c0a0a0",
-	},
-	{
-		Model:       "pke.vp",
-		ResultsCode: "c0a0",
-	},
-	{
-		Model:       "pke_unguarded_alice.vp",
-		ResultsCode: "c0a1",
-	},
-	{
-		Model:       "pke_unguarded_bob.vp",
-		ResultsCode: "c1a0",
-	},
-	{
-		Model:       "pke_unchecked_assert.vp",
-		ResultsCode: "c0a1",
-	},
-	{
-		Model:       "pw_hash.vp",
-		ResultsCode: "c1c0c0c0c1c1",
-	},
-	{
-		Model:       "pw_hash2.vp",
-		ResultsCode: "c0",
-	},
-	{
-		Model:       "shamir.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "subkey.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "subkey_hash.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "subkey_hkdf.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "trivial.vp",
-		ResultsCode: "c1a1",
-	},
-	{
-		Model:       "unchecked_aead.vp",
-		ResultsCode: "c0a0a0",
-	},
-	{
-		Model:       "unguarded_alice.vp",
-		ResultsCode: "c0a1a1",
-	},
-	{
-		Model:       "unguarded_bob.vp",
-		ResultsCode: "c1a0a0e1",
-	},
-	/*
-		{
-			Model:       "signal_small_leaks.vp",
-			ResultsCode: "c1a1",
-		},
-		{
-			Model:       "signal_small_leaks_alice.vp",
-			ResultsCode: "c1a1",
-		},
-		{
-			Model:       "signal_small_leaks_bob.vp",
-			ResultsCode: "c1a1",
-		},
-		{
-			Model:       "signal_small_unguarded_alice.vp",
-			ResultsCode: "c1a1",
-		},
-		{
-			Model:       "signal_small_unguarded_bob.vp",
-			ResultsCode: "c1a1",
-		},
-	*/
-	{
-		Model:       "signal_small_nophase.vp",
-		ResultsCode: "c1a1",
-	},
-	{
-		Model:       "signal_small_unguarded.vp",
-		ResultsCode: "c1a1",
-	},
-	{
-		Model:       "auth_with_signing.vp",
-		ResultsCode: "c1a1a1",
-	},
-	{
-		Model:       "auth_with_signing_false-attack.vp",
-		ResultsCode: "c0a1a0",
-	},
-	{
-		Model:       "hmac_verif.vp",
-		ResultsCode: "a1a1",
-	},
-	{
-		Model:       "sign_ciphertext.vp",
-		ResultsCode: "c0a0",
-	},
-	{
-		Model:       "signature.vp",
-		ResultsCode: "c0a0a0",
-	},
-	{
-		Model:       "precondition.vp",
-		ResultsCode: "a1",
-	},
-	{
-		Model:       "e_collection_key.vp",
-		ResultsCode: "c0a1",
-	},
-	{
-		Model:       "ringsign.vp",
-		ResultsCode: "a0",
-	},
-	{
-		Model:       "ringsign_substitute.vp",
-		ResultsCode: "a1a0a1a1",
-	},
-	{
-		Model:       "ringsign_unguarded.vp",
-		ResultsCode: "a1",
-	},
-	{
-		Model:       "saltchannel.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "concat1.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "concat2.vp",
-		ResultsCode: "c0",
-	},
-	{
-		Model:       "freshness.vp",
-		ResultsCode: "f1f0",
-	},
-	{
-		Model:       "unlinkability.vp",
-		ResultsCode: "u1u1u0",
-	},
-	{
-		Model:       "needham-schroeder-pk.vp",
-		ResultsCode: "a1a1c1c1",
-	},
-	{
-		Model:       "needham-schroeder-pk-withfix.vp",
-		ResultsCode: "a1a1c1c0",
-	},
-	{
-		Model:       "fullresolution.vp",
-		ResultsCode: "c1c1c1c1c0",
-	},
-	{
-		Model:       "ql.vp",
-		ResultsCode: "c0",
-	},
-	{
-		Model:       "escore_old.vp",
-		ResultsCode: "c1c1",
-	},
-	{
-		Model:       "test1.vp",
-		ResultsCode: "c1c1c1a1a1a1",
-	},
-	{
-		Model:       "test2.vp",
-		ResultsCode: "c0c0c0a0a1a1",
-	},
-	{
-		Model:       "test3.vp",
-		ResultsCode: "c1c1c1a1a1a1",
-	},
-	{
-		Model:       "test4.vp",
-		ResultsCode: "c0c0c0a0a1a1e0",
-	},
-	{
-		Model:       "test5.vp",
-		ResultsCode: "c1c1c1a1a1a1",
-	},
-	{
-		Model:       "ffgg.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "exa.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "exa2.vp",
-		ResultsCode: "c1",
-	},
-	{
-		Model:       "fakeauth.vp",
-		ResultsCode: "a0",
-	},
-	{
-		Model:       "replay-simple.vp",
-		ResultsCode: "a0f0",
-	},
-	{
-		Model:       "mwe.vp",
-		ResultsCode: "c0",
-	},
-	{
-		Model:       "password.vp",
-		ResultsCode: "c1c1c1c1",
-	},
-	{
-		Model:       "dh_equiv.vp",
-		ResultsCode: "c1c1c1e0",
-	},
-	{
-		Model:       "melanie_bugs.vp",
-		ResultsCode: "c1c1c1c1c1a1",
-	},
-	{
-		Model:       "simple_equiv.vp",
-		ResultsCode: "e0",
-	},
-	{
-		Model:       "ordering_a.vp",
-		ResultsCode: "c1a1",
-	},
-	{
-		Model:       "ordering_b.vp",
-		ResultsCode: "c1a1",
-	},
-	{
-		Model:       "pedersen_commit.vp",
-		ResultsCode: "e0e0e0e0e0e0",
-	},
+// verifpalSlowTests lists annotated models which take too long to verify on
+// every test run, and are only verified if VERIFPAL_SLOW_TESTS is set to 1.
+var verifpalSlowTests = []string{
+	"signal_small_leaks.vp",
+	"signal_small_leaks_alice.vp",
+	"signal_small_leaks_bob.vp",
+	"signal_small_unguarded_alice.vp",
+	"signal_small_unguarded_bob.vp",
 }
 
 func TestMain(t *testing.T) {
-	for _, v := range verifpalTests {
-		testModel(v, t)
+	entries, err := os.ReadDir("../../examples/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".vp" {
+			continue
+		}
+		if os.Getenv("VERIFPAL_SLOW_TESTS") != "1" && slices.Contains(verifpalSlowTests, entry.Name()) {
+			continue
+		}
+		testModel(entry.Name(), t)
 	}
 }
 
-func testModel(v VerifpalTest, t *testing.T) {
-	fileName := fmt.Sprintf("../../examples/test/%s", v.Model)
-	_, resultsCode, err := vplogic.Verify(fileName)
+func testModel(model string, t *testing.T) {
+	fileName := fmt.Sprintf("../../examples/test/%s", model)
+	expectResults, err := vplogic.VerifyFileExpect(context.Background(), fileName, vplogic.DefaultOptions())
 	if err != nil {
 		t.Error(err)
 	}
-	if resultsCode != v.ResultsCode {
-		t.Errorf(
-			"   FAIL • %s (expected %s, got %s)\n",
-			v.Model, v.ResultsCode, resultsCode,
-		)
+	for _, expectResult := range expectResults {
+		if !expectResult.Pass {
+			t.Errorf(
+				"   FAIL • %s: %s (expected %s, got %s)\n",
+				model, expectResult.Query, expectResult.Expected, expectResult.Status,
+			)
+		}
+	}
+}
+
+func TestVerifyExitCode(t *testing.T) {
+	verified := vplogic.VerifyResult{Status: vplogic.VerifyStatusVerified}
+	attackFound := vplogic.VerifyResult{Status: vplogic.VerifyStatusAttackFound}
+	inconclusive := vplogic.VerifyResult{Status: vplogic.VerifyStatusInconclusive}
+	for _, c := range []struct {
+		results  []vplogic.VerifyResult
+		exitCode int
+	}{
+		{[]vplogic.VerifyResult{}, exitVerified},
+		{[]vplogic.VerifyResult{verified, verified}, exitVerified},
+		{[]vplogic.VerifyResult{verified, attackFound}, exitAttackFound},
+		{[]vplogic.VerifyResult{inconclusive, verified}, exitInconclusive},
+		{[]vplogic.VerifyResult{inconclusive, attackFound}, exitAttackFound},
+	} {
+		exitCode := verifyExitCode(c.results)
+		if exitCode != c.exitCode {
+			t.Errorf("expected exit code %d, got %d", c.exitCode, exitCode)
+		}
 	}
 }
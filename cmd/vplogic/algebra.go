@@ -131,13 +131,13 @@ func scalarExprFromConstant(c *Constant) (scalarExpr, bool) {
 	}
 }
 
-func scalarExprToValue(expr scalarExpr) *Value {
+func (s *Session) scalarExprToValue(expr scalarExpr) *Value {
 	normalized := expr.normalize()
 	if normalized.isZero() {
 		return valueZero
 	}
 	name := encodeScalarExpr(normalized)
-	id := valueNamesMapAdd(name)
+	id := s.valueNamesMapAdd(name)
 	return &Value{
 		Kind: typesEnumConstant,
 		Data: &Constant{
@@ -147,7 +147,7 @@ func scalarExprToValue(expr scalarExpr) *Value {
 	}
 }
 
-func scalarExprVariableConstantsFromValue(v *Value) []*Constant {
+func (s *Session) scalarExprVariableConstantsFromValue(v *Value) []*Constant {
 	expr, ok := scalarExprFromValue(v)
 	if !ok {
 		return []*Constant{}
@@ -155,7 +155,7 @@ func scalarExprVariableConstantsFromValue(v *Value) []*Constant {
 	names := expr.variableNames()
 	constants := make([]*Constant, 0, len(names))
 	for _, name := range names {
-		id := valueNamesMapAdd(name)
+		id := s.valueNamesMapAdd(name)
 		constants = append(constants, &Constant{Name: name, ID: id})
 	}
 	return constants
@@ -306,12 +306,13 @@ func rewriteXorPrimitive(p *Primitive) (bool, []*Value) {
 			Arguments: simplified,
 			Output:    p.Output,
 			Check:     p.Check,
+			Position:  p.Position,
 		}
 		return true, []*Value{{Kind: typesEnumPrimitive, Data: rewritten}}
 	}
 }
 
-func rewriteScalarNegPrimitive(p *Primitive) (bool, []*Value) {
+func (s *Session) rewriteScalarNegPrimitive(p *Primitive) (bool, []*Value) {
 	if len(p.Arguments) != 1 {
 		return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 	}
@@ -319,10 +320,10 @@ func rewriteScalarNegPrimitive(p *Primitive) (bool, []*Value) {
 	if !ok {
 		return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 	}
-	return true, []*Value{scalarExprToValue(expr.negate())}
+	return true, []*Value{s.scalarExprToValue(expr.negate())}
 }
 
-func rewriteScalarAddPrimitive(p *Primitive) (bool, []*Value) {
+func (s *Session) rewriteScalarAddPrimitive(p *Primitive) (bool, []*Value) {
 	if len(p.Arguments) < 2 {
 		return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 	}
@@ -335,10 +336,10 @@ func rewriteScalarAddPrimitive(p *Primitive) (bool, []*Value) {
 		sum = sum.add(expr)
 	}
 	sum = sum.normalize()
-	return true, []*Value{scalarExprToValue(sum)}
+	return true, []*Value{s.scalarExprToValue(sum)}
 }
 
-func rewritePedersenCommit(p *Primitive) (bool, []*Value) {
+func (s *Session) rewritePedersenCommit(p *Primitive) (bool, []*Value) {
 	if len(p.Arguments) != 2 {
 		return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 	}
@@ -358,16 +359,17 @@ func rewritePedersenCommit(p *Primitive) (bool, []*Value) {
 	rewritten := &Primitive{
 		ID: primitiveEnumPEDERSENCOMMIT,
 		Arguments: []*Value{
-			scalarExprToValue(vExpr),
-			scalarExprToValue(rExpr),
+			s.scalarExprToValue(vExpr),
+			s.scalarExprToValue(rExpr),
 		},
-		Output: p.Output,
-		Check:  p.Check,
+		Output:   p.Output,
+		Check:    p.Check,
+		Position: p.Position,
 	}
 	return true, []*Value{{Kind: typesEnumPrimitive, Data: rewritten}}
 }
 
-func rewriteNegPrimitive(p *Primitive) (bool, []*Value) {
+func (s *Session) rewriteNegPrimitive(p *Primitive) (bool, []*Value) {
 	if len(p.Arguments) != 1 {
 		return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 	}
@@ -378,7 +380,7 @@ func rewriteNegPrimitive(p *Primitive) (bool, []*Value) {
 	if arg.Kind == typesEnumPrimitive {
 		inner := arg.Data.(*Primitive)
 		if inner.ID == primitiveEnumGROUPADD {
-			_, rewritten := rewriteGroupAddPrimitive(inner)
+			_, rewritten := s.rewriteGroupAddPrimitive(inner)
 			if len(rewritten) != 1 {
 				return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 			}
@@ -411,19 +413,19 @@ func rewriteNegPrimitive(p *Primitive) (bool, []*Value) {
 			neg := &Primitive{
 				ID: primitiveEnumPEDERSENCOMMIT,
 				Arguments: []*Value{
-					scalarExprToValue(vExpr.negate()),
-					scalarExprToValue(rExpr.negate()),
+					s.scalarExprToValue(vExpr.negate()),
+					s.scalarExprToValue(rExpr.negate()),
 				},
 				Output: inner.Output,
 				Check:  inner.Check,
 			}
-			return rewritePedersenCommit(neg)
+			return s.rewritePedersenCommit(neg)
 		}
 	}
 	return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 }
 
-func rewriteGroupAddPrimitive(p *Primitive) (bool, []*Value) {
+func (s *Session) rewriteGroupAddPrimitive(p *Primitive) (bool, []*Value) {
 	if len(p.Arguments) != 2 {
 		return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 	}
@@ -439,7 +441,7 @@ func rewriteGroupAddPrimitive(p *Primitive) (bool, []*Value) {
 		if operand.Kind == typesEnumPrimitive {
 			prim := operand.Data.(*Primitive)
 			if prim.ID == primitiveEnumNEG {
-				_, rewritten := rewriteNegPrimitive(prim)
+				_, rewritten := s.rewriteNegPrimitive(prim)
 				if len(rewritten) != 1 {
 					return true, []*Value{{Kind: typesEnumPrimitive, Data: p}}
 				}
@@ -495,20 +497,21 @@ func rewriteGroupAddPrimitive(p *Primitive) (bool, []*Value) {
 	if allGenerator && sumR.isZero() {
 		combined := &Value{
 			Kind: typesEnumEquation,
-			Data: &Equation{Values: []*Value{valueG, scalarExprToValue(sumV)}},
+			Data: &Equation{Values: []*Value{valueG, s.scalarExprToValue(sumV)}},
 		}
 		return true, []*Value{combined}
 	}
 	combined := &Primitive{
 		ID: primitiveEnumPEDERSENCOMMIT,
 		Arguments: []*Value{
-			scalarExprToValue(sumV),
-			scalarExprToValue(sumR),
+			s.scalarExprToValue(sumV),
+			s.scalarExprToValue(sumR),
 		},
-		Output: p.Output,
-		Check:  p.Check,
+		Output:   p.Output,
+		Check:    p.Check,
+		Position: p.Position,
 	}
-	return rewritePedersenCommit(combined)
+	return s.rewritePedersenCommit(combined)
 }
 
 func flattenGroupAddOperands(args []*Value) []*Value {
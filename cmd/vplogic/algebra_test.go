@@ -1,9 +1,13 @@
 package vplogic
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestScalarExprEncoding(t *testing.T) {
-	v := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: valueNamesMapAdd("v")}}
+	s := NewSession()
+	v := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: s.valueNamesMapAdd("v")}}
 	expr, ok := scalarExprFromValue(v)
 	if !ok {
 		t.Fatalf("expected scalar expression from constant")
@@ -11,7 +15,7 @@ func TestScalarExprEncoding(t *testing.T) {
 	if expr.isZero() {
 		t.Fatalf("expected non-zero scalar expression")
 	}
-	encoded := scalarExprToValue(expr)
+	encoded := s.scalarExprToValue(expr)
 	decoded, ok := scalarExprFromValue(encoded)
 	if !ok {
 		t.Fatalf("expected decoded scalar expression")
@@ -22,14 +26,15 @@ func TestScalarExprEncoding(t *testing.T) {
 }
 
 func TestPedersenCommitRewrite(t *testing.T) {
+	s := NewSession()
 	commit := &Primitive{
 		ID: primitiveEnumPEDERSENCOMMIT,
 		Arguments: []*Value{
-			{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: valueNamesMapAdd("v")}},
-			{Kind: typesEnumConstant, Data: &Constant{Name: "r", ID: valueNamesMapAdd("r")}},
+			{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: s.valueNamesMapAdd("v")}},
+			{Kind: typesEnumConstant, Data: &Constant{Name: "r", ID: s.valueNamesMapAdd("r")}},
 		},
 	}
-	rewritten, values := rewritePedersenCommit(commit)
+	rewritten, values := s.rewritePedersenCommit(commit)
 	if !rewritten || len(values) != 1 {
 		t.Fatalf("expected pedersen commit rewrite")
 	}
@@ -39,30 +44,32 @@ func TestPedersenCommitRewrite(t *testing.T) {
 	}
 	zeroCommit := &Primitive{
 		ID:        primitiveEnumPEDERSENCOMMIT,
-		Arguments: []*Value{scalarExprToValue(newScalarExprZero()), scalarExprToValue(newScalarExprZero())},
+		Arguments: []*Value{s.scalarExprToValue(newScalarExprZero()), s.scalarExprToValue(newScalarExprZero())},
 	}
-	rewritten, values = rewritePedersenCommit(zeroCommit)
+	rewritten, values = s.rewritePedersenCommit(zeroCommit)
 	if !rewritten || len(values) != 1 || values[0] != valueZero {
 		t.Fatalf("expected pedersen commit zero rewrite")
 	}
 }
 
 func TestScalarAddRewriteToZero(t *testing.T) {
-	x := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "sx", ID: valueNamesMapAdd("sx")}}
+	s := NewSession()
+	x := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "sx", ID: s.valueNamesMapAdd("sx")}}
 	neg := &Primitive{ID: primitiveEnumSCALARNEG, Arguments: []*Value{x}}
 	add := &Primitive{ID: primitiveEnumSCALARADD, Arguments: []*Value{x, {Kind: typesEnumPrimitive, Data: neg}}}
-	rewritten, values := rewriteScalarAddPrimitive(add)
+	rewritten, values := s.rewriteScalarAddPrimitive(add)
 	if !rewritten || len(values) != 1 || values[0] != valueZero {
 		t.Fatalf("expected scalar addition to cancel to zero")
 	}
 }
 
 func TestScalarAddRewriteWithHashes(t *testing.T) {
-	secret := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "secret", ID: valueNamesMapAdd("secret")}}
-	salt := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "salt", ID: valueNamesMapAdd("salt")}}
+	s := NewSession()
+	secret := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "secret", ID: s.valueNamesMapAdd("secret")}}
+	salt := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "salt", ID: s.valueNamesMapAdd("salt")}}
 	hash := &Primitive{ID: primitiveEnumHASH, Arguments: []*Value{salt}}
 	add := &Primitive{ID: primitiveEnumSCALARADD, Arguments: []*Value{secret, {Kind: typesEnumPrimitive, Data: hash}}}
-	rewritten, values := rewriteScalarAddPrimitive(add)
+	rewritten, values := s.rewriteScalarAddPrimitive(add)
 	if !rewritten || len(values) != 1 {
 		t.Fatalf("expected scalar addition rewrite result")
 	}
@@ -76,38 +83,40 @@ func TestScalarAddRewriteWithHashes(t *testing.T) {
 }
 
 func TestGroupAdditionRewritesToZero(t *testing.T) {
+	s := NewSession()
 	commit := &Primitive{
 		ID: primitiveEnumPEDERSENCOMMIT,
 		Arguments: []*Value{
-			{Kind: typesEnumConstant, Data: &Constant{Name: "x", ID: valueNamesMapAdd("x")}},
-			{Kind: typesEnumConstant, Data: &Constant{Name: "y", ID: valueNamesMapAdd("y")}},
+			{Kind: typesEnumConstant, Data: &Constant{Name: "x", ID: s.valueNamesMapAdd("x")}},
+			{Kind: typesEnumConstant, Data: &Constant{Name: "y", ID: s.valueNamesMapAdd("y")}},
 		},
 	}
 	negCommit := &Primitive{
 		ID:        primitiveEnumNEG,
 		Arguments: []*Value{{Kind: typesEnumPrimitive, Data: commit}},
 	}
-	_, negValues := rewriteNegPrimitive(negCommit)
+	_, negValues := s.rewriteNegPrimitive(negCommit)
 	add := &Primitive{
 		ID:        primitiveEnumGROUPADD,
 		Arguments: []*Value{{Kind: typesEnumPrimitive, Data: commit}, negValues[0]},
 	}
-	rewritten, values := rewriteGroupAddPrimitive(add)
+	rewritten, values := s.rewriteGroupAddPrimitive(add)
 	if !rewritten || len(values) != 1 || values[0] != valueZero {
 		t.Fatalf("expected group addition to reduce to zero")
 	}
 }
 
 func TestNegDoubleNegation(t *testing.T) {
+	s := NewSession()
 	base := &Primitive{
 		ID: primitiveEnumPEDERSENCOMMIT,
 		Arguments: []*Value{
-			{Kind: typesEnumConstant, Data: &Constant{Name: "a", ID: valueNamesMapAdd("a")}},
-			{Kind: typesEnumConstant, Data: &Constant{Name: "b", ID: valueNamesMapAdd("b")}},
+			{Kind: typesEnumConstant, Data: &Constant{Name: "a", ID: s.valueNamesMapAdd("a")}},
+			{Kind: typesEnumConstant, Data: &Constant{Name: "b", ID: s.valueNamesMapAdd("b")}},
 		},
 	}
 	neg := &Primitive{ID: primitiveEnumNEG, Arguments: []*Value{{Kind: typesEnumPrimitive, Data: base}}}
-	rewritten, values := rewriteNegPrimitive(&Primitive{ID: primitiveEnumNEG, Arguments: []*Value{{Kind: typesEnumPrimitive, Data: neg}}})
+	rewritten, values := s.rewriteNegPrimitive(&Primitive{ID: primitiveEnumNEG, Arguments: []*Value{{Kind: typesEnumPrimitive, Data: neg}}})
 	if !rewritten || len(values) != 1 {
 		t.Fatalf("expected double negation rewrite")
 	}
@@ -119,15 +128,18 @@ func TestNegDoubleNegation(t *testing.T) {
 
 func TestPreprocessLineAddition(t *testing.T) {
 	line := "S1 = C + Cneg"
-	processed, err := preprocessLine(line)
+	processed, offsets, err := preprocessLine(line)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if processed != "S1 = GROUPADD(C, Cneg)" {
 		t.Fatalf("unexpected preprocess result: %s", processed)
 	}
+	if offsets[strings.Index(processed, "Cneg")] != strings.Index(line, "Cneg") || offsets[len(processed)] != len(line) {
+		t.Fatalf("unexpected preprocess offsets: %v", offsets)
+	}
 	line = "Sum = PedersenCommit(a, b) + PedersenCommit(-a, -b)"
-	processed, err = preprocessLine(line)
+	processed, _, err = preprocessLine(line)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -138,9 +150,10 @@ func TestPreprocessLineAddition(t *testing.T) {
 }
 
 func TestGroupAdditionWithHashScalars(t *testing.T) {
-	value := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: valueNamesMapAdd("v")}}
-	salt := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "s", ID: valueNamesMapAdd("s")}}
-	block := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "n_block", ID: valueNamesMapAdd("n_block")}}
+	s := NewSession()
+	value := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: s.valueNamesMapAdd("v")}}
+	salt := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "s", ID: s.valueNamesMapAdd("s")}}
+	block := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "n_block", ID: s.valueNamesMapAdd("n_block")}}
 	hash := &Primitive{ID: primitiveEnumHASH, Arguments: []*Value{salt, block}}
 	commit := &Primitive{ID: primitiveEnumPEDERSENCOMMIT, Arguments: []*Value{value, {Kind: typesEnumPrimitive, Data: hash}}}
 	negCommit := &Primitive{
@@ -151,21 +164,22 @@ func TestGroupAdditionWithHashScalars(t *testing.T) {
 		},
 	}
 	groupAdd := &Primitive{ID: primitiveEnumGROUPADD, Arguments: []*Value{{Kind: typesEnumPrimitive, Data: commit}, {Kind: typesEnumPrimitive, Data: negCommit}}}
-	rewritten, values := rewriteGroupAddPrimitive(groupAdd)
+	rewritten, values := s.rewriteGroupAddPrimitive(groupAdd)
 	if !rewritten || len(values) != 1 || values[0] != valueZero {
 		t.Fatalf("expected hash-backed pedersen commits to cancel out")
 	}
 }
 
 func TestGroupAdditionGeneratorExponentials(t *testing.T) {
-	v := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: valueNamesMapAdd("v")}}
-	k := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "k", ID: valueNamesMapAdd("k")}}
+	s := NewSession()
+	v := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: s.valueNamesMapAdd("v")}}
+	k := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "k", ID: s.valueNamesMapAdd("k")}}
 	hash := &Primitive{ID: primitiveEnumHASH, Arguments: []*Value{v, k}}
 	gToHash := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, {Kind: typesEnumPrimitive, Data: hash}}}}
 	gToK := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, k}}}
 	groupAdd := &Primitive{ID: primitiveEnumGROUPADD, Arguments: []*Value{gToHash, gToK}}
 
-	rewritten, values := rewriteGroupAddPrimitive(groupAdd)
+	rewritten, values := s.rewriteGroupAddPrimitive(groupAdd)
 	if !rewritten || len(values) != 1 {
 		t.Fatalf("expected group addition rewrite result")
 	}
@@ -177,7 +191,7 @@ func TestGroupAdditionGeneratorExponentials(t *testing.T) {
 	if !ok {
 		t.Fatalf("expected scalar expression from k")
 	}
-	expected := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, scalarExprToValue(hashExpr.add(kExpr))}}}
+	expected := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, s.scalarExprToValue(hashExpr.add(kExpr))}}}
 
 	if !valueEquivalentValues(values[0], expected, true) {
 		t.Fatalf("expected generator exponentials to combine in rewrite")
@@ -185,8 +199,9 @@ func TestGroupAdditionGeneratorExponentials(t *testing.T) {
 }
 
 func TestHashScalarExprExponentOrderNormalization(t *testing.T) {
-	r := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "r", ID: valueNamesMapAdd("r")}}
-	v := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: valueNamesMapAdd("v")}}
+	s := NewSession()
+	r := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "r", ID: s.valueNamesMapAdd("r")}}
+	v := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "v", ID: s.valueNamesMapAdd("v")}}
 	gToRV := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, r, v}}}
 	gToVR := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, v, r}}}
 
@@ -211,8 +226,9 @@ func TestHashScalarExprExponentOrderNormalization(t *testing.T) {
 }
 
 func TestXorRewriteCancellation(t *testing.T) {
-	a := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "axor", ID: valueNamesMapAdd("axor")}}
-	b := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "bxor", ID: valueNamesMapAdd("bxor")}}
+	s := NewSession()
+	a := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "axor", ID: s.valueNamesMapAdd("axor")}}
+	b := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "bxor", ID: s.valueNamesMapAdd("bxor")}}
 	inner := &Primitive{ID: primitiveEnumXOR, Arguments: []*Value{a, b}}
 	outer := &Primitive{ID: primitiveEnumXOR, Arguments: []*Value{{Kind: typesEnumPrimitive, Data: inner}, a}}
 	rewritten, values := rewriteXorPrimitive(outer)
@@ -225,7 +241,8 @@ func TestXorRewriteCancellation(t *testing.T) {
 }
 
 func TestXorRewriteToZero(t *testing.T) {
-	a := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "cxor", ID: valueNamesMapAdd("cxor")}}
+	s := NewSession()
+	a := &Value{Kind: typesEnumConstant, Data: &Constant{Name: "cxor", ID: s.valueNamesMapAdd("cxor")}}
 	xor := &Primitive{ID: primitiveEnumXOR, Arguments: []*Value{a, a}}
 	rewritten, values := rewriteXorPrimitive(xor)
 	if !rewritten || len(values) != 1 || values[0] != valueZero {
@@ -0,0 +1,169 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// ArchiveDefaultDir is the directory in which results are archived
+// unless another one is given.
+const ArchiveDefaultDir = ".verifpal/results"
+
+const archiveHashLength = 12
+
+// NewArchiveSink returns a ResultsSink which writes the results of each
+// verification to dir, as an ArchiveEntry in its own JSON file, so that
+// they can be listed and compared over time with ReadArchive and WriteHistory.
+// Nothing is sent outside of the local machine.
+func NewArchiveSink(dir string) ResultsSink {
+	return archiveSink{dir: dir}
+}
+
+// Name returns the name of the archive's directory.
+func (sink archiveSink) Name() string {
+	return fmt.Sprintf("the results archive in %s", sink.dir)
+}
+
+// Submit writes the results to a new file within the archive's directory,
+// creating the directory if needed.
+func (sink archiveSink) Submit(results Results) error {
+	entry := ArchiveEntry{
+		Model:       results.FileName,
+		ModelHash:   archiveModelHash(results.Model),
+		Source:      results.Model,
+		ResultsCode: results.ResultsCode,
+		Timestamp:   results.Report.Completed,
+		Report:      results.Report,
+	}
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(sink.dir, 0o755)
+	if err != nil {
+		return err
+	}
+	fileName := fmt.Sprintf(
+		"%s-%s.json",
+		entry.Timestamp.UTC().Format("20060102T150405.000000000Z"),
+		archiveShortHash(entry.ModelHash),
+	)
+	return os.WriteFile(filepath.Join(sink.dir, fileName), append(b, '\n'), 0o644)
+}
+
+func archiveModelHash(model string) string {
+	h := sha256.Sum256([]byte(model))
+	return hex.EncodeToString(h[:])
+}
+
+func archiveShortHash(hash string) string {
+	if len(hash) < archiveHashLength {
+		return hash
+	}
+	return hash[:archiveHashLength]
+}
+
+// archiveEntryValid indicates whether entry holds results written by the sink
+// returned by NewArchiveSink, rather than some other JSON document.
+func archiveEntryValid(entry ArchiveEntry) bool {
+	if len(entry.ModelHash) != sha256.Size*2 || entry.Timestamp.IsZero() {
+		return false
+	}
+	_, err := hex.DecodeString(entry.ModelHash)
+	return err == nil
+}
+
+// ReadArchive reads the ArchiveEntries written to dir by the sink returned by
+// NewArchiveSink, ordered from oldest to newest. A missing directory is read as
+// an empty archive. Files which do not hold an ArchiveEntry, such as other JSON
+// documents, are skipped, and their paths returned.
+func ReadArchive(dir string) ([]ArchiveEntry, []string, error) {
+	entries := []ArchiveEntry{}
+	skipped := []string{}
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return entries, skipped, err
+	}
+	for _, fileName := range fileNames {
+		b, err := os.ReadFile(fileName)
+		if err != nil {
+			return entries, skipped, err
+		}
+		entry := ArchiveEntry{}
+		err = json.Unmarshal(b, &entry)
+		if err != nil || !archiveEntryValid(entry) {
+			skipped = append(skipped, fileName)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries, skipped, nil
+}
+
+// WriteHistory writes ArchiveEntries as a table, with one row per entry,
+// comparing each entry with the previous entry for the same model.
+func WriteHistory(w io.Writer, entries []ArchiveEntry) error {
+	t := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(t, "TIME\tMODEL\tHASH\tRESULTS\tCHANGES")
+	previous := map[string]ArchiveEntry{}
+	for _, entry := range entries {
+		changes := "first result"
+		if p, ok := previous[entry.Model]; ok {
+			changes = archiveChanges(p, entry)
+		}
+		previous[entry.Model] = entry
+		fmt.Fprintf(t, "%s\t%s\t%s\t%s\t%s\n",
+			entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.Model,
+			archiveShortHash(entry.ModelHash), entry.ResultsCode, changes,
+		)
+	}
+	return t.Flush()
+}
+
+// archiveChanges describes how entry differs from the previous entry for the same
+// model: whether the model changed, and which queries were added, removed or
+// changed status.
+func archiveChanges(previous ArchiveEntry, entry ArchiveEntry) string {
+	changes := []string{}
+	if previous.ModelHash != entry.ModelHash {
+		changes = append(changes, "model changed")
+	}
+	statuses := map[string]VerifyStatus{}
+	for _, q := range previous.Report.Queries {
+		statuses[q.Query] = q.Status
+	}
+	for _, q := range entry.Report.Queries {
+		status, ok := statuses[q.Query]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s added (%s)", q.Query, q.Status))
+		case status != q.Status:
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", q.Query, status, q.Status))
+		}
+		delete(statuses, q.Query)
+	}
+	for _, q := range previous.Report.Queries {
+		if _, ok := statuses[q.Query]; ok {
+			changes = append(changes, fmt.Sprintf("%s removed", q.Query))
+		}
+	}
+	if len(changes) == 0 {
+		return "unchanged"
+	}
+	return strings.Join(changes, "; ")
+}
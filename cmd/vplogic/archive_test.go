@@ -0,0 +1,91 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type failingSink struct{}
+
+func (failingSink) Name() string {
+	return "a failing sink"
+}
+
+func (failingSink) Submit(results Results) error {
+	return errors.New("unavailable")
+}
+
+func TestArchive(t *testing.T) {
+	b, err := os.ReadFile("../../examples/test/hmac_ok.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "results")
+	opts := Options{Sinks: []ResultsSink{NewArchiveSink(dir)}}
+	for i := 0; i < 2; i++ {
+		_, _, err = VerifyBytes(context.Background(), "hmac_ok.vp", b, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	changed := strings.Replace(string(b), "authentication? Alice -> Bob : ciphertext // expect: pass", "", 1)
+	opts.Sinks = append(opts.Sinks, failingSink{})
+	_, _, err = VerifyBytes(context.Background(), "hmac_ok.vp", []byte(changed), opts)
+	var sinkErr *SinkError
+	if !errors.As(err, &sinkErr) || sinkErr.Error() != "cannot submit results to a failing sink (unavailable)" {
+		t.Errorf("expected the failing sink's error, got %v", err)
+	}
+	batchReport := VerifyBatch(context.Background(), []string{
+		"../../examples/test/trivial.vp",
+	}, 1, 0, Options{Sinks: []ResultsSink{failingSink{}}})
+	batchResult := batchReport.Results[0]
+	if batchResult.Report == nil || !strings.Contains(batchResult.Error, "a failing sink") {
+		t.Errorf("expected a report and the failing sink's error, got %+v", batchResult)
+	}
+	err = os.WriteFile(filepath.Join(dir, "foreign.json"), []byte("{}"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, skipped, err := ReadArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || filepath.Base(skipped[0]) != "foreign.json" {
+		t.Errorf("expected foreign.json to be skipped, got %v", skipped)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 archive entries, got %d", len(entries))
+	}
+	if entries[0].Model != "hmac_ok.vp" || entries[0].ResultsCode != "c0a0" ||
+		entries[0].ModelHash != entries[1].ModelHash || entries[1].ModelHash == entries[2].ModelHash ||
+		!strings.Contains(entries[0].Source, "queries[") || len(entries[0].Report.Queries) != 2 {
+		t.Errorf("unexpected archive entries: %+v", entries)
+	}
+	var output bytes.Buffer
+	err = WriteHistory(&output, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"first result",
+		"unchanged",
+		"model changed; authentication? Alice -> Bob: ciphertext removed",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected history to contain %q, got:\n%s", expected, output.String())
+		}
+	}
+	entries, _, err = ReadArchive(filepath.Join(dir, "missing"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected a missing archive to be empty, got %d entries (%v)", len(entries), err)
+	}
+}
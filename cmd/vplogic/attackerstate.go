@@ -4,27 +4,23 @@
 
 package vplogic
 
-import (
-	"sync"
-)
-
-var attackerStateShared AttackerState
-var attackerStateMutex sync.Mutex
-
-func attackerStateInit(active bool) {
-	attackerStateMutex.Lock()
-	attackerStateShared = AttackerState{
+func (s *Session) attackerStateInit(active bool) {
+	s.attackerStateMutex.Lock()
+	s.attackerState = AttackerState{
 		Active:         active,
 		CurrentPhase:   0,
 		Exhausted:      false,
 		Known:          []*Value{},
 		PrincipalState: []*PrincipalState{},
+		knownIndex: &attackerKnownIndex{
+			values: map[uint64][]int{},
+		},
 	}
-	attackerStateMutex.Unlock()
+	s.attackerStateMutex.Unlock()
 }
 
-func attackerStateAbsorbPhaseValues(valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState) error {
-	attackerStateMutex.Lock()
+func (s *Session) attackerStateAbsorbPhaseValues(valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState) error {
+	s.attackerStateMutex.Lock()
 	for i := 0; i < len(valPrincipalState.Constants); i++ {
 		switch valPrincipalState.Assigned[i].Kind {
 		case typesEnumConstant:
@@ -32,7 +28,7 @@ func attackerStateAbsorbPhaseValues(valKnowledgeMap *KnowledgeMap, valPrincipalS
 				continue
 			}
 			earliestPhase, err := minIntInSlice(valPrincipalState.Phase[i])
-			if err == nil && earliestPhase > attackerStateShared.CurrentPhase {
+			if err == nil && earliestPhase > s.attackerState.CurrentPhase {
 				continue
 			}
 			if !valueConstantIsUsedByAtLeastOnePrincipalInKnowledgeMap(
@@ -40,12 +36,8 @@ func attackerStateAbsorbPhaseValues(valKnowledgeMap *KnowledgeMap, valPrincipalS
 			) {
 				continue
 			}
-			if valueEquivalentValueInValues(valPrincipalState.Assigned[i], attackerStateShared.Known) < 0 {
-				valPrincipalStateClone := constructPrincipalStateClone(valPrincipalState, false)
-				attackerStateShared.Known = append(attackerStateShared.Known, valPrincipalState.Assigned[i])
-				attackerStateShared.PrincipalState = append(
-					attackerStateShared.PrincipalState, valPrincipalStateClone,
-				)
+			if attackerStateKnownIndex(s.attackerState, valPrincipalState.Assigned[i]) < 0 {
+				s.attackerStateKnownAppend(valPrincipalState.Assigned[i], valPrincipalState)
 			}
 		}
 	}
@@ -60,73 +52,95 @@ func attackerStateAbsorbPhaseValues(valKnowledgeMap *KnowledgeMap, valPrincipalS
 		}
 		earliestPhase, err := minIntInSlice(valPrincipalState.Phase[i])
 		if err != nil {
+			s.attackerStateMutex.Unlock()
 			return err
 		}
-		if earliestPhase > attackerStateShared.CurrentPhase {
+		if earliestPhase > s.attackerState.CurrentPhase {
 			continue
 		}
-		if valueEquivalentValueInValues(cc, attackerStateShared.Known) < 0 {
-			valPrincipalStateClone := constructPrincipalStateClone(valPrincipalState, false)
-			attackerStateShared.Known = append(attackerStateShared.Known, cc)
-			attackerStateShared.PrincipalState = append(
-				attackerStateShared.PrincipalState, valPrincipalStateClone,
-			)
+		if attackerStateKnownIndex(s.attackerState, cc) < 0 {
+			s.attackerStateKnownAppend(cc, valPrincipalState)
 		}
-		if valueEquivalentValueInValues(a, attackerStateShared.Known) < 0 {
-			valPrincipalStateClone := constructPrincipalStateClone(valPrincipalState, false)
-			attackerStateShared.Known = append(attackerStateShared.Known, a)
-			attackerStateShared.PrincipalState = append(
-				attackerStateShared.PrincipalState, valPrincipalStateClone,
-			)
+		if attackerStateKnownIndex(s.attackerState, a) < 0 {
+			s.attackerStateKnownAppend(a, valPrincipalState)
 		}
 	}
-	attackerStateMutex.Unlock()
+	s.attackerStateMutex.Unlock()
 	return nil
 }
 
-func attackerStateGetRead() AttackerState {
-	attackerStateMutex.Lock()
-	valAttackerState := attackerStateShared
-	attackerStateMutex.Unlock()
+func (s *Session) attackerStateGetRead() AttackerState {
+	s.attackerStateMutex.Lock()
+	valAttackerState := s.attackerState
+	s.attackerStateMutex.Unlock()
 	return valAttackerState
 }
 
-func attackerStateGetExhausted() bool {
+func (s *Session) attackerStateGetExhausted() bool {
 	var exhausted bool
-	attackerStateMutex.Lock()
-	exhausted = attackerStateShared.Exhausted
-	attackerStateMutex.Unlock()
+	s.attackerStateMutex.Lock()
+	exhausted = s.attackerState.Exhausted
+	s.attackerStateMutex.Unlock()
 	return exhausted
 }
 
-func attackerStatePutWrite(known *Value, valPrincipalState *PrincipalState) bool {
+func (s *Session) attackerStatePutWrite(known *Value, valPrincipalState *PrincipalState) bool {
 	written := false
-	if valueEquivalentValueInValues(known, attackerStateShared.Known) < 0 {
-		attackerStateMutex.Lock()
-		if valueEquivalentValueInValues(known, attackerStateShared.Known) < 0 {
-			valPrincipalStateClone := constructPrincipalStateClone(valPrincipalState, false)
-			attackerStateShared.Known = append(attackerStateShared.Known, known)
-			attackerStateShared.PrincipalState = append(
-				attackerStateShared.PrincipalState, valPrincipalStateClone,
-			)
-			written = true
-		}
-		attackerStateMutex.Unlock()
+	s.attackerStateMutex.Lock()
+	if attackerStateKnownIndex(s.attackerState, known) < 0 {
+		s.attackerStateKnownAppend(known, valPrincipalState)
+		written = true
 	}
+	s.attackerStateMutex.Unlock()
 	return written
 }
 
-func attackerStatePutPhaseUpdate(valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState, phase int) error {
-	attackerStateMutex.Lock()
-	attackerStateShared.CurrentPhase = phase
-	attackerStateMutex.Unlock()
-	err := attackerStateAbsorbPhaseValues(valKnowledgeMap, valPrincipalState)
+// attackerStateKnownAppend must be called while holding attackerStateMutex.
+func (s *Session) attackerStateKnownAppend(known *Value, valPrincipalState *PrincipalState) {
+	h := valueHash(known)
+	valPrincipalStateClone := constructPrincipalStateClone(valPrincipalState, false)
+	s.attackerState.knownIndex.mutex.Lock()
+	s.attackerState.knownIndex.values[h] = append(
+		s.attackerState.knownIndex.values[h], len(s.attackerState.Known),
+	)
+	s.attackerState.knownIndex.mutex.Unlock()
+	s.attackerState.Known = append(s.attackerState.Known, known)
+	s.attackerState.PrincipalState = append(
+		s.attackerState.PrincipalState, valPrincipalStateClone,
+	)
+}
+
+// attackerStateKnownIndex returns the index in valAttackerState.Known of a value
+// equivalent to v, or -1 if the attacker does not know such a value.
+func attackerStateKnownIndex(valAttackerState AttackerState, v *Value) int {
+	if valAttackerState.knownIndex == nil {
+		return valueEquivalentValueInValues(v, valAttackerState.Known)
+	}
+	valAttackerState.knownIndex.mutex.RLock()
+	indices := valAttackerState.knownIndex.values[valueHash(v)]
+	valAttackerState.knownIndex.mutex.RUnlock()
+	for _, i := range indices {
+		if i >= len(valAttackerState.Known) {
+			break
+		}
+		if valueEquivalentValues(v, valAttackerState.Known[i], true) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Session) attackerStatePutPhaseUpdate(valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState, phase int) error {
+	s.attackerStateMutex.Lock()
+	s.attackerState.CurrentPhase = phase
+	s.attackerStateMutex.Unlock()
+	err := s.attackerStateAbsorbPhaseValues(valKnowledgeMap, valPrincipalState)
 	return err
 }
 
-func attackerStatePutExhausted() bool {
-	attackerStateMutex.Lock()
-	attackerStateShared.Exhausted = true
-	attackerStateMutex.Unlock()
+func (s *Session) attackerStatePutExhausted() bool {
+	s.attackerStateMutex.Lock()
+	s.attackerState.Exhausted = true
+	s.attackerStateMutex.Unlock()
 	return true
 }
@@ -0,0 +1,83 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestValueHashEquivalence(t *testing.T) {
+	s := NewSessionWithOptions(Options{})
+	constant := func(name string) *Value {
+		return &Value{Kind: typesEnumConstant, Data: &Constant{Name: name, ID: s.valueNamesMapAdd(name)}}
+	}
+	a, b, c := constant("a"), constant("b"), constant("c")
+	gab := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, a, b}}}
+	gba := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{
+		{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, b}}}, a,
+	}}}
+	gac := &Value{Kind: typesEnumEquation, Data: &Equation{Values: []*Value{valueG, a, c}}}
+	hash := func(args ...*Value) *Value {
+		return &Value{Kind: typesEnumPrimitive, Data: &Primitive{ID: primitiveEnumHASH, Arguments: args}}
+	}
+	for _, pair := range [][2]*Value{
+		{gab, gba},
+		{hash(gab, c), hash(gba, c)},
+		{a, constant("a")},
+	} {
+		if !valueEquivalentValues(pair[0], pair[1], true) {
+			t.Fatalf("expected %s and %s to be equivalent", prettyValue(pair[0]), prettyValue(pair[1]))
+		}
+		if valueHash(pair[0]) != valueHash(pair[1]) {
+			t.Errorf("expected %s and %s to hash identically", prettyValue(pair[0]), prettyValue(pair[1]))
+		}
+	}
+	for _, pair := range [][2]*Value{{gab, gac}, {hash(a, b), hash(b, a)}, {a, b}} {
+		if valueHash(pair[0]) == valueHash(pair[1]) {
+			t.Errorf("expected %s and %s to hash differently", prettyValue(pair[0]), prettyValue(pair[1]))
+		}
+	}
+}
+
+func TestAttackerStateKnownIndex(t *testing.T) {
+	s := NewSessionWithOptions(Options{})
+	s.attackerStateInit(true)
+	valPrincipalState := &PrincipalState{}
+	values := []*Value{}
+	for i := 0; i < 1000; i++ {
+		name := "k" + strconv.Itoa(i)
+		values = append(values, &Value{
+			Kind: typesEnumConstant, Data: &Constant{Name: name, ID: s.valueNamesMapAdd(name)},
+		})
+	}
+	snapshot := s.attackerStateGetRead()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			for _, v := range values {
+				s.attackerStatePutWrite(v, valPrincipalState)
+				attackerStateKnownIndex(s.attackerStateGetRead(), v)
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	valAttackerState := s.attackerStateGetRead()
+	if len(valAttackerState.Known) != len(values) {
+		t.Fatalf("expected %d known values, got %d", len(values), len(valAttackerState.Known))
+	}
+	for _, v := range values {
+		i := attackerStateKnownIndex(valAttackerState, v)
+		if i < 0 || valAttackerState.Known[i] != v {
+			t.Fatalf("expected %s to be found in known values", prettyValue(v))
+		}
+		if attackerStateKnownIndex(snapshot, v) >= 0 {
+			t.Fatalf("expected %s not to be known in an earlier snapshot", prettyValue(v))
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// VerifyBatch verifies models loaded from files, running up to parallel verifications
+// side by side (GOMAXPROCS if parallel is zero), each within its own Session
+// configured with the given Options. Each verification stops after timeout,
+// unless timeout is zero.
+func VerifyBatch(
+	ctx context.Context, filePaths []string, parallel int, timeout time.Duration, opts Options,
+) BatchReport {
+	started := time.Now()
+	batchReport := BatchReport{
+		Results: make([]BatchResult, len(filePaths)),
+	}
+	if parallel <= 0 {
+		parallel = runtime.GOMAXPROCS(0)
+	}
+	batchGroup := errgroup.Group{}
+	batchGroup.SetLimit(parallel)
+	for i, filePath := range filePaths {
+		batchGroup.Go(func() error {
+			batchReport.Results[i] = verifyBatchFile(ctx, filePath, timeout, opts)
+			return nil
+		})
+	}
+	_ = batchGroup.Wait()
+	batchReport.Seconds = time.Since(started).Seconds()
+	return batchReport
+}
+
+func verifyBatchFile(ctx context.Context, filePath string, timeout time.Duration, opts Options) BatchResult {
+	batchResult := BatchResult{FilePath: filePath}
+	reports := NewReportCollector()
+	opts.Observers = append(append([]Observer{}, opts.Observers...), reports)
+	fileCtx, cancel := context.WithCancel(ctx)
+	if timeout > 0 {
+		fileCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	_, _, err := VerifyFile(fileCtx, filePath, opts)
+	interrupted := fileCtx.Err() != nil
+	cancel()
+	collected := reports.Reports()
+	var sinkErr *SinkError
+	if err != nil && errors.As(err, &sinkErr) && len(collected) > 0 {
+		batchResult.Report = &collected[0]
+		batchResult.Error = err.Error()
+		return batchResult
+	}
+	if err != nil && (!interrupted || len(collected) == 0) {
+		var modelErr *ModelError
+		if errors.As(err, &modelErr) {
+			modelErr.FileName = filePath
+		}
+		batchResult.Error = err.Error()
+		return batchResult
+	}
+	batchResult.Report = &collected[0]
+	return batchResult
+}
+
+// WriteBatchReport writes a BatchReport as a table, with one row per query
+// and one row per model whose verification could not be completed, followed
+// by the errors which prevented these verifications from completing or their
+// results from being sent.
+func WriteBatchReport(w io.Writer, batchReport BatchReport) error {
+	t := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(t, "MODEL\tQUERY\tSTATUS\tTIME")
+	failures := ""
+	for _, batchResult := range batchReport.Results {
+		switch {
+		case len(batchResult.Error) == 0:
+		case strings.HasPrefix(batchResult.Error, batchResult.FilePath+":"):
+			failures = fmt.Sprintf("%s%s\n", failures, batchResult.Error)
+		default:
+			failures = fmt.Sprintf("%s%s: %s\n", failures, batchResult.FilePath, batchResult.Error)
+		}
+		if batchResult.Report == nil {
+			fmt.Fprintf(t, "%s\t-\terror\t-\n", batchResult.FilePath)
+			continue
+		}
+		r := batchResult.Report
+		for _, q := range r.Queries {
+			query, _, _ := strings.Cut(q.Query, "[")
+			fmt.Fprintf(t, "%s\t%s\t%s\t%.2fs\n", batchResult.FilePath, query, q.Status, r.Seconds)
+		}
+	}
+	err := t.Flush()
+	if err != nil || len(failures) == 0 {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\n%s", failures)
+	return err
+}
+
+// WriteBatchReportJSON writes a BatchReport as an indented JSON document.
+func WriteBatchReportJSON(w io.Writer, batchReport BatchReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(batchReport)
+}
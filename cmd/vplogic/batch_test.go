@@ -0,0 +1,42 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	batchReport := VerifyBatch(context.Background(), []string{
+		"../../examples/test/hmac_unguarded_bob.vp",
+		"../../examples/test/exa2.vp",
+		"../../examples/test/hmac_ok.vp",
+		"../../examples/test/trivial.vp",
+	}, 2, 0, Options{})
+	if len(batchReport.Results) != 4 {
+		t.Fatalf("expected four results, got %d", len(batchReport.Results))
+	}
+	for i, resultsCode := range []string{"c1a0", "", "c0a0", "c1a1"} {
+		batchResult := batchReport.Results[i]
+		switch {
+		case resultsCode == "" && (batchResult.Report != nil || len(batchResult.Error) == 0):
+			t.Errorf("expected %s to fail with an error", batchResult.FilePath)
+		case resultsCode != "" && (batchResult.Report == nil || batchResult.Report.ResultsCode != resultsCode):
+			t.Errorf("expected %s to give %s, got %v", batchResult.FilePath, resultsCode, batchResult)
+		}
+	}
+	var table bytes.Buffer
+	err := WriteBatchReport(&table, batchReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(table.String(), "confidentiality? plaintext") ||
+		!strings.Contains(table.String(), "exa2.vp:25:8: checked primitive fails") {
+		t.Errorf("expected queries and errors to be written, got %s", table.String())
+	}
+}
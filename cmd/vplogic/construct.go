@@ -8,7 +8,7 @@ import (
 	"fmt"
 )
 
-func constructKnowledgeMap(m Model, principals []string, principalIDs []principalEnum) (*KnowledgeMap, error) {
+func (s *Session) constructKnowledgeMap(m Model, principals []string, principalIDs []principalEnum) (*KnowledgeMap, error) {
 	var err error
 	valKnowledgeMap := &KnowledgeMap{
 		Principals:    principals,
@@ -26,7 +26,7 @@ func constructKnowledgeMap(m Model, principals []string, principalIDs []principa
 	currentPhase := 0
 	valKnowledgeMap.Constants = append(valKnowledgeMap.Constants, valueG.Data.(*Constant))
 	valKnowledgeMap.Assigned = append(valKnowledgeMap.Assigned, valueG)
-	valKnowledgeMap.Creator = append(valKnowledgeMap.Creator, principalNamesMap["Attacker"])
+	valKnowledgeMap.Creator = append(valKnowledgeMap.Creator, s.principalGetIDFromName("Attacker"))
 	valKnowledgeMap.KnownBy = append(valKnowledgeMap.KnownBy, []map[principalEnum]principalEnum{})
 	valKnowledgeMap.DeclaredAt = append(valKnowledgeMap.DeclaredAt, declaredAt)
 	valKnowledgeMap.Phase = append(valKnowledgeMap.Phase, []int{currentPhase})
@@ -38,7 +38,7 @@ func constructKnowledgeMap(m Model, principals []string, principalIDs []principa
 	}
 	valKnowledgeMap.Constants = append(valKnowledgeMap.Constants, valueNil.Data.(*Constant))
 	valKnowledgeMap.Assigned = append(valKnowledgeMap.Assigned, valueNil)
-	valKnowledgeMap.Creator = append(valKnowledgeMap.Creator, principalNamesMap["Attacker"])
+	valKnowledgeMap.Creator = append(valKnowledgeMap.Creator, s.principalGetIDFromName("Attacker"))
 	valKnowledgeMap.KnownBy = append(valKnowledgeMap.KnownBy, []map[principalEnum]principalEnum{})
 	valKnowledgeMap.DeclaredAt = append(valKnowledgeMap.DeclaredAt, declaredAt)
 	valKnowledgeMap.Phase = append(valKnowledgeMap.Phase, []int{currentPhase})
@@ -50,7 +50,7 @@ func constructKnowledgeMap(m Model, principals []string, principalIDs []principa
 	}
 	valKnowledgeMap.Constants = append(valKnowledgeMap.Constants, valueZero.Data.(*Constant))
 	valKnowledgeMap.Assigned = append(valKnowledgeMap.Assigned, valueZero)
-	valKnowledgeMap.Creator = append(valKnowledgeMap.Creator, principalNamesMap["Attacker"])
+	valKnowledgeMap.Creator = append(valKnowledgeMap.Creator, s.principalGetIDFromName("Attacker"))
 	valKnowledgeMap.KnownBy = append(valKnowledgeMap.KnownBy, []map[principalEnum]principalEnum{})
 	valKnowledgeMap.DeclaredAt = append(valKnowledgeMap.DeclaredAt, declaredAt)
 	valKnowledgeMap.Phase = append(valKnowledgeMap.Phase, []int{currentPhase})
@@ -72,11 +72,11 @@ func constructKnowledgeMap(m Model, principals []string, principalIDs []principa
 		case "message":
 			declaredAt = declaredAt + 1
 			valKnowledgeMap.MaxDeclaredAt = declaredAt
-			valKnowledgeMap, err = constructKnowledgeMapRenderMessage(
+			valKnowledgeMap, err = s.constructKnowledgeMapRenderMessage(
 				valKnowledgeMap, blck, currentPhase,
 			)
 			if err != nil {
-				return &KnowledgeMap{}, err
+				return &KnowledgeMap{}, modelErrorAt(blck.Position, err)
 			}
 		case "phase":
 			currentPhase = blck.Phase.Number
@@ -97,21 +97,21 @@ func constructKnowledgeMapRenderPrincipal(
 				valKnowledgeMap, blck, declaredAt, expr,
 			)
 			if err != nil {
-				return &KnowledgeMap{}, 0, err
+				return &KnowledgeMap{}, 0, modelErrorAt(expr.Position, err)
 			}
 		case typesEnumGenerates:
 			valKnowledgeMap, err = constructKnowledgeMapRenderGenerates(
 				valKnowledgeMap, blck, declaredAt, expr,
 			)
 			if err != nil {
-				return &KnowledgeMap{}, 0, err
+				return &KnowledgeMap{}, 0, modelErrorAt(expr.Position, err)
 			}
 		case typesEnumAssignment:
 			valKnowledgeMap, err = constructKnowledgeMapRenderAssignment(
 				valKnowledgeMap, blck, declaredAt, expr,
 			)
 			if err != nil {
-				return &KnowledgeMap{}, 0, err
+				return &KnowledgeMap{}, 0, modelErrorAt(expr.Position, err)
 			}
 		case typesEnumLeaks:
 			declaredAt = declaredAt + 1
@@ -119,7 +119,7 @@ func constructKnowledgeMapRenderPrincipal(
 				valKnowledgeMap, blck, expr, currentPhase,
 			)
 			if err != nil {
-				return &KnowledgeMap{}, 0, err
+				return &KnowledgeMap{}, 0, modelErrorAt(expr.Position, err)
 			}
 		}
 	}
@@ -138,10 +138,10 @@ func constructKnowledgeMapRenderKnows(
 			q2 := expr.Qualifier
 			fresh := valKnowledgeMap.Constants[i].Fresh
 			if d1 != d2 || q1 != q2 || fresh {
-				return valKnowledgeMap, fmt.Errorf(
+				return valKnowledgeMap, modelErrorAt(c.Position, fmt.Errorf(
 					"constant is known more than once and in different ways (%s)",
 					prettyConstant(c),
-				)
+				))
 			}
 			valKnowledgeMap.KnownBy[i] = append(
 				valKnowledgeMap.KnownBy[i],
@@ -157,6 +157,7 @@ func constructKnowledgeMapRenderKnows(
 			Leaked:      false,
 			Declaration: typesEnumKnows,
 			Qualifier:   expr.Qualifier,
+			Position:    c.Position,
 		}
 		valKnowledgeMap.Constants = append(valKnowledgeMap.Constants, c)
 		valKnowledgeMap.Assigned = append(valKnowledgeMap.Assigned, &Value{
@@ -189,10 +190,10 @@ func constructKnowledgeMapRenderGenerates(
 	for _, c := range expr.Constants {
 		i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, c)
 		if i >= 0 {
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(c.Position, fmt.Errorf(
 				"generated constant already exists (%s)",
 				prettyConstant(c),
-			)
+			))
 		}
 		c = &Constant{
 			Name:        c.Name,
@@ -202,6 +203,7 @@ func constructKnowledgeMapRenderGenerates(
 			Leaked:      false,
 			Declaration: typesEnumGenerates,
 			Qualifier:   typesEnumPrivate,
+			Position:    c.Position,
 		}
 		valKnowledgeMap.Constants = append(valKnowledgeMap.Constants, c)
 		valKnowledgeMap.Assigned = append(valKnowledgeMap.Assigned, &Value{
@@ -233,10 +235,10 @@ func constructKnowledgeMapRenderAssignment(
 	for _, c := range constants {
 		i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, c)
 		if i < 0 {
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(c.Position, fmt.Errorf(
 				"constant does not exist (%s)",
 				prettyConstant(c),
-			)
+			))
 		}
 		knows := valKnowledgeMap.Creator[i] == blck.Principal.ID
 		for _, m := range valKnowledgeMap.KnownBy[i] {
@@ -246,20 +248,20 @@ func constructKnowledgeMapRenderAssignment(
 			}
 		}
 		if !knows {
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(c.Position, fmt.Errorf(
 				"%s is using constant (%s) despite not knowing it",
 				blck.Principal.Name,
 				prettyConstant(c),
-			)
+			))
 		}
 	}
 	for i, c := range expr.Constants {
 		ii := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, c)
 		if ii >= 0 {
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(c.Position, fmt.Errorf(
 				"constant assigned twice (%s)",
 				prettyConstant(c),
-			)
+			))
 		}
 		c = &Constant{
 			Name:        c.Name,
@@ -269,6 +271,7 @@ func constructKnowledgeMapRenderAssignment(
 			Leaked:      false,
 			Declaration: typesEnumAssignment,
 			Qualifier:   typesEnumPrivate,
+			Position:    c.Position,
 		}
 		a := valueDeepCopy(expr.Assigned)
 		switch a.Kind {
@@ -293,10 +296,10 @@ func constructKnowledgeMapRenderLeaks(
 			valKnowledgeMap, c,
 		)
 		if i < 0 {
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(c.Position, fmt.Errorf(
 				"leaked constant does not exist (%s)",
 				prettyConstant(c),
-			)
+			))
 		}
 		known := valKnowledgeMap.Creator[i] == blck.Principal.ID
 		for _, m := range valKnowledgeMap.KnownBy[i] {
@@ -306,10 +309,10 @@ func constructKnowledgeMapRenderLeaks(
 			}
 		}
 		if !known {
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(c.Position, fmt.Errorf(
 				"%s leaks a constant that they do not know (%s)",
 				blck.Principal.Name, prettyConstant(c),
-			)
+			))
 		}
 		valKnowledgeMap.Constants[i].Leaked = true
 		valKnowledgeMap.Phase[i], _ = appendUniqueInt(
@@ -319,19 +322,20 @@ func constructKnowledgeMapRenderLeaks(
 	return valKnowledgeMap, nil
 }
 
-func constructKnowledgeMapRenderMessage(
+func (s *Session) constructKnowledgeMapRenderMessage(
 	valKnowledgeMap *KnowledgeMap, blck Block, currentPhase int,
 ) (*KnowledgeMap, error) {
 	for _, c := range blck.Message.Constants {
 		i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, c)
 		if i < 0 {
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(c.Position, fmt.Errorf(
 				"%s sends unknown constant to %s (%s)",
-				principalGetNameFromID(blck.Message.Sender),
-				principalGetNameFromID(blck.Message.Recipient),
+				s.principalGetNameFromID(blck.Message.Sender),
+				s.principalGetNameFromID(blck.Message.Recipient),
 				prettyConstant(c),
-			)
+			))
 		}
+		pos := c.Position
 		c = valKnowledgeMap.Constants[i]
 		senderKnows := false
 		recipientKnows := false
@@ -353,17 +357,17 @@ func constructKnowledgeMapRenderMessage(
 		}
 		switch {
 		case !senderKnows:
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(pos, fmt.Errorf(
 				"%s is sending constant (%s) despite not knowing it",
-				principalGetNameFromID(blck.Message.Sender),
+				s.principalGetNameFromID(blck.Message.Sender),
 				prettyConstant(c),
-			)
+			))
 		case recipientKnows:
-			return valKnowledgeMap, fmt.Errorf(
+			return valKnowledgeMap, modelErrorAt(pos, fmt.Errorf(
 				"%s is receiving constant (%s) despite already knowing it",
-				principalGetNameFromID(blck.Message.Recipient),
+				s.principalGetNameFromID(blck.Message.Recipient),
 				prettyConstant(c),
-			)
+			))
 		}
 		valKnowledgeMap.KnownBy[i] = append(
 			valKnowledgeMap.KnownBy[i], map[principalEnum]principalEnum{
@@ -471,49 +475,83 @@ func constructPrincipalStatesGetValueMutatability(
 	return wire, guard, mutatableTo
 }
 
+// constructPrincipalStateClone returns a PrincipalState sharing all of its columns
+// with valPrincipalState. Columns are only copied once they are written to, via
+// principalStateWritable, so valPrincipalState must not itself be written to
+// once it has been cloned. If purify is set, the clone's Assigned and BeforeRewrite
+// columns are those values which valPrincipalState held before being mutated.
 func constructPrincipalStateClone(valPrincipalState *PrincipalState, purify bool) *PrincipalState {
 	valPrincipalStateClone := PrincipalState{
 		Name:          valPrincipalState.Name,
 		ID:            valPrincipalState.ID,
-		Constants:     make([]*Constant, len(valPrincipalState.Constants)),
-		Assigned:      make([]*Value, len(valPrincipalState.Assigned)),
-		Guard:         make([]bool, len(valPrincipalState.Guard)),
-		Known:         make([]bool, len(valPrincipalState.Known)),
-		Wire:          make([][]principalEnum, len(valPrincipalState.Wire)),
-		KnownBy:       make([][]map[principalEnum]principalEnum, len(valPrincipalState.KnownBy)),
-		DeclaredAt:    make([]int, len(valPrincipalState.DeclaredAt)),
+		Constants:     valPrincipalState.Constants,
+		Assigned:      valPrincipalState.Assigned,
+		Guard:         valPrincipalState.Guard,
+		Known:         valPrincipalState.Known,
+		Wire:          valPrincipalState.Wire,
+		KnownBy:       valPrincipalState.KnownBy,
+		DeclaredAt:    valPrincipalState.DeclaredAt,
 		MaxDeclaredAt: valPrincipalState.MaxDeclaredAt,
-		Creator:       make([]principalEnum, len(valPrincipalState.Creator)),
-		Sender:        make([]principalEnum, len(valPrincipalState.Sender)),
-		Rewritten:     make([]bool, len(valPrincipalState.Rewritten)),
-		BeforeRewrite: make([]*Value, len(valPrincipalState.BeforeRewrite)),
-		Mutated:       make([]bool, len(valPrincipalState.Mutated)),
-		MutatableTo:   make([][]principalEnum, len(valPrincipalState.MutatableTo)),
-		BeforeMutate:  make([]*Value, len(valPrincipalState.BeforeMutate)),
-		Phase:         make([][]int, len(valPrincipalState.Phase)),
+		Creator:       valPrincipalState.Creator,
+		Sender:        valPrincipalState.Sender,
+		Rewritten:     valPrincipalState.Rewritten,
+		BeforeRewrite: valPrincipalState.BeforeRewrite,
+		Mutated:       valPrincipalState.Mutated,
+		MutatableTo:   valPrincipalState.MutatableTo,
+		BeforeMutate:  valPrincipalState.BeforeMutate,
+		Phase:         valPrincipalState.Phase,
+		shared:        principalStateColumnAll,
 	}
-	copy(valPrincipalStateClone.Constants, valPrincipalState.Constants)
 	if purify {
-		copy(valPrincipalStateClone.Assigned, valPrincipalState.BeforeMutate)
-	} else {
-		copy(valPrincipalStateClone.Assigned, valPrincipalState.Assigned)
+		valPrincipalStateClone.Assigned = valPrincipalState.BeforeMutate
+		valPrincipalStateClone.BeforeRewrite = valPrincipalState.BeforeMutate
 	}
-	copy(valPrincipalStateClone.Guard, valPrincipalState.Guard)
-	copy(valPrincipalStateClone.Known, valPrincipalState.Known)
-	copy(valPrincipalStateClone.Wire, valPrincipalState.Wire)
-	copy(valPrincipalStateClone.KnownBy, valPrincipalState.KnownBy)
-	copy(valPrincipalStateClone.DeclaredAt, valPrincipalState.DeclaredAt)
-	copy(valPrincipalStateClone.Creator, valPrincipalState.Creator)
-	copy(valPrincipalStateClone.Sender, valPrincipalState.Sender)
-	copy(valPrincipalStateClone.Rewritten, valPrincipalState.Rewritten)
-	if purify {
-		copy(valPrincipalStateClone.BeforeRewrite, valPrincipalState.BeforeMutate)
-	} else {
-		copy(valPrincipalStateClone.BeforeRewrite, valPrincipalState.BeforeRewrite)
-	}
-	copy(valPrincipalStateClone.Mutated, valPrincipalState.Mutated)
-	copy(valPrincipalStateClone.MutatableTo, valPrincipalState.MutatableTo)
-	copy(valPrincipalStateClone.BeforeMutate, valPrincipalState.BeforeMutate)
-	copy(valPrincipalStateClone.Phase, valPrincipalState.Phase)
 	return &valPrincipalStateClone
 }
+
+// principalStateWritable gives valPrincipalState its own copy of each of the
+// given columns that it still shares with the PrincipalState it was cloned from.
+// It must be called before writing to any of the elements of those columns.
+func principalStateWritable(valPrincipalState *PrincipalState, columns principalStateColumn) {
+	shared := valPrincipalState.shared & columns
+	if shared&principalStateColumnAssigned != 0 {
+		valPrincipalState.Assigned = constructValuesCopy(valPrincipalState.Assigned)
+	}
+	if shared&principalStateColumnCreator != 0 {
+		valPrincipalState.Creator = constructPrincipalsCopy(valPrincipalState.Creator)
+	}
+	if shared&principalStateColumnSender != 0 {
+		valPrincipalState.Sender = constructPrincipalsCopy(valPrincipalState.Sender)
+	}
+	if shared&principalStateColumnRewritten != 0 {
+		valPrincipalState.Rewritten = constructBoolsCopy(valPrincipalState.Rewritten)
+	}
+	if shared&principalStateColumnBeforeRewrite != 0 {
+		valPrincipalState.BeforeRewrite = constructValuesCopy(valPrincipalState.BeforeRewrite)
+	}
+	if shared&principalStateColumnMutated != 0 {
+		valPrincipalState.Mutated = constructBoolsCopy(valPrincipalState.Mutated)
+	}
+	if shared&principalStateColumnBeforeMutate != 0 {
+		valPrincipalState.BeforeMutate = constructValuesCopy(valPrincipalState.BeforeMutate)
+	}
+	valPrincipalState.shared = valPrincipalState.shared &^ shared
+}
+
+func constructValuesCopy(a []*Value) []*Value {
+	c := make([]*Value, len(a))
+	copy(c, a)
+	return c
+}
+
+func constructPrincipalsCopy(a []principalEnum) []principalEnum {
+	c := make([]principalEnum, len(a))
+	copy(c, a)
+	return c
+}
+
+func constructBoolsCopy(a []bool) []bool {
+	c := make([]bool, len(a))
+	copy(c, a)
+	return c
+}
@@ -0,0 +1,38 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import "testing"
+
+func TestPrincipalStateCloneCopyOnWrite(t *testing.T) {
+	s := NewSessionWithOptions(Options{})
+	m, err := s.Parse("../../examples/test/hmac_unguarded_bob.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, valPrincipalStates, err := s.Sanity(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := valPrincipalStates[0]
+	before := parent.Assigned[0]
+	clone := constructPrincipalStateClone(parent, false)
+	if &clone.Assigned[0] != &parent.Assigned[0] || &clone.Constants[0] != &parent.Constants[0] {
+		t.Fatalf("expected clone to share columns with its parent")
+	}
+	principalStateWritable(clone, principalStateColumnAssigned|principalStateColumnMutated)
+	clone.Assigned[0] = valueNil
+	clone.Mutated[0] = true
+	if parent.Assigned[0] != before || parent.Mutated[0] {
+		t.Fatalf("expected writes to a clone not to affect its parent")
+	}
+	if &clone.Sender[0] != &parent.Sender[0] {
+		t.Fatalf("expected unwritten columns to remain shared")
+	}
+	grandchild := constructPrincipalStateClone(clone, true)
+	if &grandchild.Assigned[0] != &clone.BeforeMutate[0] {
+		t.Fatalf("expected purified clone to share its parent's BeforeMutate column")
+	}
+}
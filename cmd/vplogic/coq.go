@@ -13,15 +13,16 @@ import (
 // Coq translates a Verifpal model into a representation that fits
 // into the Coq model of the Verifpal verification methodology.
 func Coq(modelFile string) error {
-	m, err := libpegParseModel(modelFile, false)
+	s := NewSession()
+	m, err := s.libpegParseModel(modelFile, false)
 	if err != nil {
 		return err
 	}
-	valKnowledgeMap, _, err := sanity(m)
+	valKnowledgeMap, _, err := s.sanity(m)
 	if err != nil {
 		return err
 	}
-	cm, err := coqModel(m, valKnowledgeMap)
+	cm, err := s.coqModel(m, valKnowledgeMap)
 	if err != nil {
 		return err
 	}
@@ -29,7 +30,7 @@ func Coq(modelFile string) error {
 	return err
 }
 
-func coqModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
+func (s *Session) coqModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
 	var err error
 	declaredPrincipals, _, err := sanityDeclaredPrincipals(m)
 	if err != nil {
@@ -38,6 +39,7 @@ func coqModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
 	output := []string{}
 	output = append(output, libcoq)
 	output = append(output, fmt.Sprintf("\n(* Protocol: %s *)", m.FileName))
+	output = append(output, coqComments(m.Comments.Leading)...)
 	output = append(output, coqPrincipalNames(declaredPrincipals))
 	output = append(output, "Definition depth := 10000.")
 	blocksByPhase := [][]Block{}
@@ -60,7 +62,9 @@ func coqModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
 		output = append(output, fmt.Sprintf(
 			"Definition attacker_%d := init_attacker phase_%d.",
 			i, i))
+		output = append(output, coqComments(m.QueriesComments.Leading)...)
 		for _, q := range m.Queries {
+			output = append(output, coqComments(q.Comments.Leading)...)
 			switch q.Kind {
 			case typesEnumConfidentiality:
 				for _, qc := range q.Constants {
@@ -74,11 +78,14 @@ func coqModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
 						"(gather_principals names phase_%d)) depth) depth."}, ""),
 						crc, i, i))
 				}
+				output = append(output, coqComments(q.Comments.Trailing)...)
 			default:
-				return "", fmt.Errorf("unsupported query: %s", prettyQuery(q))
+				return "", fmt.Errorf("unsupported query: %s", s.prettyQuery(q))
 			}
 		}
+		output = append(output, coqComments(m.QueriesComments.Trailing)...)
 	}
+	output = append(output, coqComments(m.Comments.Trailing)...)
 	return strings.Join(output, "\n"), nil
 }
 
@@ -93,19 +100,25 @@ func coqBlockByPhase(valKnowledgeMap *KnowledgeMap, phase []Block, output []stri
 			if err != nil {
 				return []string{}, err
 			}
+			output = append(output, coqBlockComments(block.Comments.Leading)...)
 			output = append(output, fmt.Sprintf(
-				"\t\tpblock (PRINCIPAL\"%s\"[%s]);",
-				block.Principal.Name, cpb,
+				"\t\tpblock (PRINCIPAL\"%s\"[%s])%s;",
+				block.Principal.Name, cpb, coqTrailingComments(block.Comments),
 			))
 		case "message":
-			for _, x := range block.Message.Constants {
+			output = append(output, coqBlockComments(block.Comments.Leading)...)
+			for i, x := range block.Message.Constants {
 				crc, err = coqResolveConstant(x, valKnowledgeMap)
 				if err != nil {
 					return []string{}, err
 				}
+				trailing := ""
+				if i == len(block.Message.Constants)-1 {
+					trailing = coqTrailingComments(block.Comments)
+				}
 				output = append(output, fmt.Sprintf(
-					"\t\tmblock(MSG %s (%s));",
-					coqGuard(x.Guard), crc,
+					"\t\tmblock(MSG %s (%s))%s;",
+					coqGuard(x.Guard), crc, trailing,
 				))
 			}
 		default:
@@ -115,6 +128,30 @@ func coqBlockByPhase(valKnowledgeMap *KnowledgeMap, phase []Block, output []stri
 	return output, nil
 }
 
+func coqComments(comments []Comment) []string {
+	output := []string{}
+	for _, comment := range comments {
+		output = append(output, prettyMLComment(comment))
+	}
+	return output
+}
+
+func coqBlockComments(comments []Comment) []string {
+	output := []string{}
+	for _, comment := range comments {
+		output = append(output, fmt.Sprintf("\t\t%s", prettyMLComment(comment)))
+	}
+	return output
+}
+
+func coqTrailingComments(trivia Trivia) string {
+	output := ""
+	for _, comment := range trivia.Trailing {
+		output = fmt.Sprintf("%s %s", output, prettyMLComment(comment))
+	}
+	return output
+}
+
 func coqPrincipalNames(principals []string) string {
 	output := "Definition names := ["
 	for i, pname := range principals {
@@ -131,6 +168,7 @@ func coqPrincipal(block Block, valKnowledgeMap *KnowledgeMap) (string, error) {
 	var err error
 	expressions := []string{""}
 	for i, expression := range block.Principal.Expressions {
+		expressions = append(expressions, coqComments(expression.Comments.Leading)...)
 		switch expression.Kind {
 		case typesEnumKnows:
 			switch expression.Qualifier {
@@ -188,10 +226,10 @@ func coqPrincipal(block Block, valKnowledgeMap *KnowledgeMap) (string, error) {
 			cae, err = coqAssignmentExpression(expression, valKnowledgeMap)
 			expressions = append(expressions, cae...)
 		}
+		expressions = append(expressions, coqComments(expression.Comments.Trailing)...)
 		if len(block.Principal.Expressions) == i+1 {
-			expressions[len(expressions)-1] = strings.TrimSuffix(
-				expressions[len(expressions)-1], ";",
-			)
+			coqTrimLastExpression(expressions)
+			expressions = append(expressions, coqComments(block.Principal.Comments)...)
 			expressions = append(expressions, "")
 		}
 		if err != nil {
@@ -201,6 +239,18 @@ func coqPrincipal(block Block, valKnowledgeMap *KnowledgeMap) (string, error) {
 	return strings.Join(expressions, "\n\t\t\t\t"), nil
 }
 
+// coqTrimLastExpression removes the separator after the last expression of a principal,
+// which may be followed by comments.
+func coqTrimLastExpression(expressions []string) {
+	for i := len(expressions) - 1; i >= 0; i-- {
+		if strings.HasPrefix(expressions[i], "(*") && strings.HasSuffix(expressions[i], "*)") {
+			continue
+		}
+		expressions[i] = strings.TrimSuffix(expressions[i], ";")
+		return
+	}
+}
+
 func coqAssignmentExpression(expression Expression, valKnowledgeMap *KnowledgeMap) ([]string, error) {
 	expressions := []string{}
 	switch expression.Assigned.Kind {
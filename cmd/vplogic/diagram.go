@@ -0,0 +1,311 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DiagramFormats lists the formats in which Diagram renders models.
+var DiagramFormats = []string{"mermaid", "plantuml", "dot", "seqdiag"}
+
+const diagramMutatedNote = "mutated by Attacker"
+
+// DiagramPrint renders a Verifpal model loaded from a file as a sequence diagram
+// in the given format, as done by Diagram, and prints it.
+func DiagramPrint(modelFile string, format string, report *Report) error {
+	s := NewSession()
+	m, err := s.libpegParseModel(modelFile, false)
+	if err != nil {
+		return err
+	}
+	diagram, err := s.Diagram(m, format, report)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stdout, diagram)
+	return nil
+}
+
+// Diagram renders a Verifpal model as a sequence diagram in the given format, one of
+// DiagramFormats: mermaid (Mermaid), plantuml (PlantUML), dot (Graphviz) or seqdiag
+// (js-sequence-diagrams, as returned by PrettyDiagram). Principals are rendered as
+// lifelines, with a note holding the expressions of each principal block, and
+// guarded constants are shown in brackets within messages, as in models. Phases
+// are rendered as separators. If report is not nil, the values which the attacker
+// mutated in the attacks found against the model's queries are shown on the
+// messages carrying them.
+func (s *Session) Diagram(m Model, format string, report *Report) (string, error) {
+	_, _, err := s.sanity(m)
+	if err != nil {
+		return "", err
+	}
+	principals, steps := s.diagramSteps(m, diagramMutations(report))
+	switch format {
+	case "mermaid":
+		return diagramMermaid(principals, steps), nil
+	case "plantuml":
+		return diagramPlantUML(principals, steps), nil
+	case "dot":
+		return diagramDot(m.FileName, principals, steps), nil
+	case "seqdiag":
+		return diagramSeqdiag(principals, steps), nil
+	}
+	return "", fmt.Errorf("unsupported diagram format (%s)", format)
+}
+
+// diagramSteps returns the principals of a model, in the order in which they are
+// declared, and the steps of its diagram, one for each of its blocks.
+func (s *Session) diagramSteps(m Model, mutations map[string][]string) ([]string, []diagramStep) {
+	principals := []string{}
+	steps := []diagramStep{}
+	for _, block := range m.Blocks {
+		step := diagramStep{Kind: block.Kind}
+		switch block.Kind {
+		case "principal":
+			step.Principal = block.Principal.Name
+			if !strInSlice(block.Principal.Name, principals) {
+				principals = append(principals, block.Principal.Name)
+			}
+			for _, expression := range block.Principal.Expressions {
+				step.Expressions = append(step.Expressions, prettyExpression(expression))
+			}
+		case "message":
+			step.Sender = s.principalGetNameFromID(block.Message.Sender)
+			step.Recipient = s.principalGetNameFromID(block.Message.Recipient)
+			step.Constants = prettyConstants(block.Message.Constants)
+			for _, c := range block.Message.Constants {
+				step.Mutations = append(step.Mutations, mutations[c.Name]...)
+			}
+		case "phase":
+			step.Phase = block.Phase.Number
+		}
+		steps = append(steps, step)
+	}
+	return principals, steps
+}
+
+// diagramMutations returns, for each constant which the attacker mutated in the
+// attacks found in report, the values to which it was mutated, as "c → value".
+func diagramMutations(report *Report) map[string][]string {
+	mutations := map[string][]string{}
+	if report == nil {
+		return mutations
+	}
+	for _, q := range report.Queries {
+		if q.Status != VerifyStatusAttackFound {
+			continue
+		}
+		for _, step := range q.Trace {
+			if !strings.HasPrefix(step.Note, diagramMutatedNote) {
+				continue
+			}
+			name := strings.Trim(step.Constant, "[]")
+			mutation := fmt.Sprintf("%s → %s", name, step.Value)
+			if !strInSlice(mutation, mutations[name]) {
+				mutations[name] = append(mutations[name], mutation)
+			}
+		}
+	}
+	return mutations
+}
+
+func diagramMermaid(principals []string, steps []diagramStep) string {
+	output := "sequenceDiagram\n"
+	for _, principal := range principals {
+		output = fmt.Sprintf("%s\tparticipant %s\n", output, principal)
+	}
+	for _, step := range steps {
+		switch step.Kind {
+		case "principal":
+			if len(step.Expressions) == 0 {
+				continue
+			}
+			output = fmt.Sprintf(
+				"%s\tNote over %s: %s\n",
+				output, step.Principal, strings.Join(step.Expressions, "<br/>"),
+			)
+		case "message":
+			output = fmt.Sprintf(
+				"%s\t%s->>%s: %s\n",
+				output, step.Sender, step.Recipient, step.Constants,
+			)
+			if len(step.Mutations) > 0 {
+				output = fmt.Sprintf(
+					"%s\tNote over %s,%s: %s:<br/>%s\n",
+					output, step.Sender, step.Recipient,
+					diagramMutatedNote, strings.Join(step.Mutations, "<br/>"),
+				)
+			}
+		case "phase":
+			output = fmt.Sprintf(
+				"%s\tNote over %s: phase %d\n",
+				output, diagramSpan(principals), step.Phase,
+			)
+		}
+	}
+	return output
+}
+
+func diagramPlantUML(principals []string, steps []diagramStep) string {
+	output := "@startuml\n"
+	for _, principal := range principals {
+		output = fmt.Sprintf("%sparticipant %s\n", output, principal)
+	}
+	for _, step := range steps {
+		switch step.Kind {
+		case "principal":
+			if len(step.Expressions) == 0 {
+				continue
+			}
+			output = fmt.Sprintf(
+				"%snote over %s\n%s\nend note\n",
+				output, step.Principal, strings.Join(step.Expressions, "\n"),
+			)
+		case "message":
+			if len(step.Mutations) == 0 {
+				output = fmt.Sprintf(
+					"%s%s -> %s: %s\n",
+					output, step.Sender, step.Recipient, step.Constants,
+				)
+				continue
+			}
+			output = fmt.Sprintf(
+				"%s%s -[#red]> %s: %s\nnote right #FFDDDD\n%s:\n%s\nend note\n",
+				output, step.Sender, step.Recipient, step.Constants,
+				diagramMutatedNote, strings.Join(step.Mutations, "\n"),
+			)
+		case "phase":
+			output = fmt.Sprintf("%s== phase %d ==\n", output, step.Phase)
+		}
+	}
+	return output + "@enduml\n"
+}
+
+// diagramDot renders a sequence diagram as a Graphviz graph, in which each
+// principal's lifeline is a column of points, one for each step, and each step
+// is a row holding its message, note or phase separator.
+func diagramDot(fileName string, principals []string, steps []diagramStep) string {
+	output := fmt.Sprintf(
+		"digraph %s {\n\tnewrank=true;\n\tnode [shape=box];\n\tedge [arrowhead=none, style=dashed];\n",
+		diagramDotQuote(fileName),
+	)
+	column := map[string]int{}
+	row := []string{}
+	for i, principal := range principals {
+		column[principal] = i
+		row = append(row, fmt.Sprintf("p%d", i))
+		output = fmt.Sprintf("%s\tp%d [label=%s];\n", output, i, diagramDotQuote(principal))
+	}
+	output = fmt.Sprintf("%s\t{ rank=same; %s; }\n", output, strings.Join(row, "; "))
+	for i, step := range steps {
+		row = []string{}
+		for j := range principals {
+			node := fmt.Sprintf("p%d_%d", j, i)
+			previous := fmt.Sprintf("p%d", j)
+			if i > 0 {
+				previous = fmt.Sprintf("p%d_%d", j, i-1)
+			}
+			row = append(row, node)
+			output = fmt.Sprintf(
+				"%s\t%s [shape=point, width=0.05];\n\t%s -> %s;\n",
+				output, node, previous, node,
+			)
+		}
+		switch step.Kind {
+		case "principal":
+			note := fmt.Sprintf("n%d", i)
+			row = append(row, note)
+			output = fmt.Sprintf(
+				"%s\t%s [shape=note, label=%s];\n\tp%d_%d -> %s [style=dotted];\n",
+				output, note, diagramDotLabel(strings.Join(step.Expressions, "\n")),
+				column[step.Principal], i, note,
+			)
+		case "message":
+			label := step.Constants
+			color := "black"
+			if len(step.Mutations) > 0 {
+				label = fmt.Sprintf("%s\n%s:\n%s", label, diagramMutatedNote, strings.Join(step.Mutations, "\n"))
+				color = "red"
+			}
+			output = fmt.Sprintf(
+				"%s\tp%d_%d -> p%d_%d [label=%s, style=solid, arrowhead=normal, color=%s, fontcolor=%s];\n",
+				output, column[step.Sender], i, column[step.Recipient], i,
+				diagramDotLabel(label), color, color,
+			)
+		case "phase":
+			separator := fmt.Sprintf("s%d", i)
+			row = append(row, separator)
+			output = fmt.Sprintf(
+				"%s\t%s [shape=plaintext, label=%s];\n",
+				output, separator, diagramDotQuote(fmt.Sprintf("phase %d", step.Phase)),
+			)
+		}
+		output = fmt.Sprintf("%s\t{ rank=same; %s; }\n", output, strings.Join(row, "; "))
+	}
+	return output + "}\n"
+}
+
+func diagramDotQuote(id string) string {
+	id = strings.ReplaceAll(id, "\\", "\\\\")
+	return fmt.Sprintf("\"%s\"", strings.ReplaceAll(id, "\"", "\\\""))
+}
+
+// diagramDotLabel quotes a label made of several lines, each aligned to the left.
+func diagramDotLabel(label string) string {
+	lines := strings.Split(label, "\n")
+	for i, line := range lines {
+		quoted := diagramDotQuote(line)
+		lines[i] = quoted[1 : len(quoted)-1]
+	}
+	return fmt.Sprintf("\"%s\\l\"", strings.Join(lines, "\\l"))
+}
+
+func diagramSeqdiag(principals []string, steps []diagramStep) string {
+	output := ""
+	for _, step := range steps {
+		switch step.Kind {
+		case "principal":
+			output = fmt.Sprintf("%sNote over %s: ", output, step.Principal)
+			for _, expression := range step.Expressions {
+				output = fmt.Sprintf("%s\t%s\\n", output, expression)
+			}
+			output = fmt.Sprintf("%s\n", output)
+		case "message":
+			output = fmt.Sprintf(
+				"%s%s -> %s: %s\n",
+				output, step.Sender, step.Recipient, step.Constants,
+			)
+			if len(step.Mutations) > 0 {
+				output = fmt.Sprintf(
+					"%sNote over %s,%s: %s:\\n",
+					output, step.Sender, step.Recipient, diagramMutatedNote,
+				)
+				for _, mutation := range step.Mutations {
+					output = fmt.Sprintf("%s\t%s\\n", output, mutation)
+				}
+				output = fmt.Sprintf("%s\n", output)
+			}
+		case "phase":
+			first := ""
+			if len(principals) > 0 {
+				first = principals[0]
+			}
+			output = fmt.Sprintf("%sNote left of %s:phase %d\n", output, first, step.Phase)
+		}
+	}
+	return output
+}
+
+// diagramSpan returns the range of lifelines covered by a note spanning
+// all principals.
+func diagramSpan(principals []string) string {
+	if len(principals) < 2 {
+		return strings.Join(principals, "")
+	}
+	return fmt.Sprintf("%s,%s", principals[0], principals[len(principals)-1])
+}
@@ -0,0 +1,72 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDiagram(t *testing.T) {
+	reports := NewReportCollector()
+	_, _, err := VerifyFile(context.Background(), "../../examples/test/ordering_a.vp", Options{
+		Observers: []Observer{reports},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := reports.Reports()[0]
+	s := NewSessionWithOptions(Options{})
+	m, err := s.Parse("../../examples/test/ordering_a.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, expected := range map[string][]string{
+		"mermaid": {
+			"sequenceDiagram\n\tparticipant Alice\n\tparticipant Bob\n",
+			"\tNote over Bob: knows private b<br/>gb = G^b\n",
+			"\tAlice->>Bob: [ga], gea1, e1, sgea1\n\tNote over Alice,Bob: mutated by Attacker:<br/>gea1 → G^nil",
+			"\tNote over Alice,Bob: phase 1\n",
+		},
+		"plantuml": {
+			"@startuml\nparticipant Alice\nparticipant Bob\n",
+			"note over Bob\nknows private b\ngb = G^b\nend note\n",
+			"Alice -[#red]> Bob: [ga], gea1, e1, sgea1\nnote right #FFDDDD\nmutated by Attacker:\ngea1 → G^nil",
+			"== phase 1 ==\n",
+		},
+		"dot": {
+			"digraph \"ordering_a.vp\" {",
+			"p1 [label=\"Bob\"];",
+			"label=\"[ga], gea1, e1, sgea1\\lmutated by Attacker:\\lgea1 → G^nil",
+			"label=\"phase 1\"];",
+		},
+		"seqdiag": {
+			"Note over Bob: \tknows private b\\n\tgb = G^b\\n\n",
+			"Alice -> Bob: [ga], gea1, e1, sgea1\nNote over Alice,Bob: mutated by Attacker:\\n\tgea1 → G^nil\\n",
+			"Note left of Alice:phase 1\n",
+		},
+	} {
+		diagram, err := s.Diagram(m, format, &report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range expected {
+			if !strings.Contains(diagram, e) {
+				t.Errorf("expected %s diagram to contain %q, got:\n%s", format, e, diagram)
+			}
+		}
+		diagram, err = s.Diagram(m, format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(diagram, "mutated by Attacker") {
+			t.Errorf("expected %s diagram without results to show no mutations, got:\n%s", format, diagram)
+		}
+	}
+	if _, err = s.Diagram(m, "svg", nil); err == nil {
+		t.Errorf("expected an error for an unsupported diagram format")
+	}
+}
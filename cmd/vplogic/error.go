@@ -0,0 +1,89 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error returns the error message, prefixed with the model's file name
+// and the line and column at which the error occurs, where known.
+func (e *ModelError) Error() string {
+	prefix := ""
+	if len(e.FileName) > 0 {
+		prefix = e.FileName + ":"
+	}
+	if e.Position.Line > 0 {
+		prefix = fmt.Sprintf("%s%d:%d:", prefix, e.Position.Line, e.Position.Column)
+	}
+	if len(prefix) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s %s", prefix, e.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (e *ModelError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the error message, naming the ResultsSink to which
+// the results could not be sent.
+func (e *SinkError) Error() string {
+	return fmt.Sprintf("cannot submit results to %s (%v)", e.Sink, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// Excerpt returns the line of the model source on which the error occurs,
+// followed by a line with a caret under the column at which it occurs.
+// It returns an empty string if the position of the error is unknown
+// or is not within source.
+func (e *ModelError) Excerpt(source []byte) string {
+	if e.Position.Line <= 0 || e.Position.Column <= 0 {
+		return ""
+	}
+	lines := strings.Split(string(source), "\n")
+	if e.Position.Line > len(lines) {
+		return ""
+	}
+	line := strings.TrimRight(lines[e.Position.Line-1], "\r")
+	runes := []rune(line)
+	if e.Position.Column > len(runes)+1 {
+		return ""
+	}
+	indent := make([]rune, e.Position.Column-1)
+	for i, r := range runes[:e.Position.Column-1] {
+		indent[i] = ' '
+		if r == '\t' {
+			indent[i] = '\t'
+		}
+	}
+	return fmt.Sprintf("%s\n%s^\n", line, string(indent))
+}
+
+// modelErrorAt attaches pos to err, unless err is nil or already
+// carries a more precise position.
+func modelErrorAt(pos Position, err error) error {
+	var modelErr *ModelError
+	if err == nil || errors.As(err, &modelErr) {
+		return err
+	}
+	return &ModelError{Position: pos, Err: err}
+}
+
+// modelErrorInFile attaches fileName to err if it is a ModelError without one.
+func modelErrorInFile(fileName string, err error) error {
+	var modelErr *ModelError
+	if errors.As(err, &modelErr) && len(modelErr.FileName) == 0 {
+		modelErr.FileName = fileName
+	}
+	return err
+}
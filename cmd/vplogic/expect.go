@@ -0,0 +1,89 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// VerifyFileExpect verifies a model loaded from a file and compares the outcome
+// of each of its annotated queries with the outcome it is expected to have.
+// Models without annotated queries are not verified, and yield no ExpectResults.
+func VerifyFileExpect(ctx context.Context, filePath string, opts Options) ([]ExpectResult, error) {
+	s := NewSessionWithOptions(opts)
+	m, err := s.libpegParseModel(filePath, true)
+	if err != nil {
+		return []ExpectResult{}, err
+	}
+	if !expectModelAnnotated(m) {
+		return []ExpectResult{}, nil
+	}
+	valVerifyResults, _, err := s.verifyModel(ctx, m)
+	if err != nil && ctx.Err() == nil {
+		return []ExpectResult{}, err
+	}
+	expectResults := []ExpectResult{}
+	for _, verifyResult := range valVerifyResults {
+		expected := VerifyStatusInconclusive
+		switch verifyResult.Query.Expect {
+		case typesEnumPass:
+			expected = VerifyStatusVerified
+		case typesEnumFail:
+			expected = VerifyStatusAttackFound
+		default:
+			continue
+		}
+		query := verifyResult.Query
+		query.Options = []QueryOption{}
+		expectResults = append(expectResults, ExpectResult{
+			Model:    filepath.Base(filePath),
+			Query:    s.prettyQuery(query),
+			Expected: expected,
+			Status:   verifyResult.Status,
+			Pass:     verifyResult.Status == expected,
+		})
+	}
+	return expectResults, nil
+}
+
+func expectModelAnnotated(m Model) bool {
+	for _, query := range m.Queries {
+		if query.Expect != typesEnumEmpty {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteExpectResults writes ExpectResults as a table, with one row per query.
+func WriteExpectResults(w io.Writer, expectResults []ExpectResult) error {
+	t := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(t, "RESULT\tMODEL\tQUERY\tEXPECTED\tGOT")
+	for _, expectResult := range expectResults {
+		result := "FAIL"
+		if expectResult.Pass {
+			result = "PASS"
+		}
+		fmt.Fprintf(t, "%s\t%s\t%s\t%s\t%s\n",
+			result, expectResult.Model, expectResult.Query,
+			expectOutcome(expectResult.Expected), expectOutcome(expectResult.Status),
+		)
+	}
+	return t.Flush()
+}
+
+func expectOutcome(status VerifyStatus) string {
+	switch status {
+	case VerifyStatusVerified:
+		return "pass"
+	case VerifyStatusAttackFound:
+		return "fail"
+	}
+	return "inconclusive"
+}
@@ -0,0 +1,41 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestVerifyFileExpect(t *testing.T) {
+	expectResults, err := VerifyFileExpect(
+		context.Background(), "../../examples/test/hmac_unguarded_bob.vp", Options{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expectResults) != 2 {
+		t.Fatalf("expected two annotated queries, got %d", len(expectResults))
+	}
+	if expectResults[0].Expected != VerifyStatusAttackFound || expectResults[1].Expected != VerifyStatusVerified {
+		t.Errorf("expected fail and pass annotations, got %s and %s",
+			expectResults[0].Expected, expectResults[1].Expected)
+	}
+	for _, expectResult := range expectResults {
+		if !expectResult.Pass {
+			t.Errorf("expected %s to have its expected outcome, got %s", expectResult.Query, expectResult.Status)
+		}
+	}
+	s := NewSessionWithOptions(Options{})
+	_, err = s.libpegParseBytes("expect.vp", []byte(strings.Join([]string{
+		"attacker[passive]",
+		"principal Alice[generates m]",
+		"queries[confidentiality? m // expect: maybe]",
+	}, "\n")))
+	if err == nil || !strings.Contains(err.Error(), "invalid query expectation (maybe)") {
+		t.Errorf("expected invalid expectation to be rejected, got %v", err)
+	}
+}
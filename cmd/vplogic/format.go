@@ -0,0 +1,138 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const formatDiffContext = 3
+
+// FormatFile formats a Verifpal model loaded from a file into the canonical form
+// produced by PrettyModel, keeping its comments.
+// It returns the source of the model along with its formatted version.
+func FormatFile(filePath string) ([]byte, []byte, error) {
+	s := NewSessionWithOptions(Options{})
+	original, err := os.ReadFile(filePath)
+	if err != nil {
+		return []byte{}, []byte{}, err
+	}
+	m, err := s.libpegParseModel(filePath, false)
+	if err != nil {
+		return original, []byte{}, err
+	}
+	formatted, err := s.PrettyModel(m)
+	if err != nil {
+		return original, []byte{}, err
+	}
+	return original, []byte(formatted), nil
+}
+
+// FormatDiff returns a unified diff turning the original source of a model into
+// its formatted version, or an empty string if both are identical.
+func FormatDiff(fileName string, original []byte, formatted []byte) string {
+	ops := formatDiffOps(formatDiffLines(string(original)), formatDiffLines(string(formatted)))
+	output := ""
+	for k := 0; k < len(ops); {
+		if ops[k].Kind == ' ' {
+			k++
+			continue
+		}
+		start := max(k-formatDiffContext, 0)
+		end := k
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].Kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*formatDiffContext {
+				break
+			}
+			end = run
+		}
+		stop := min(end+formatDiffContext, len(ops))
+		output = output + formatDiffHunk(ops[start:stop])
+		k = stop
+	}
+	if len(output) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("--- a/%s\n+++ b/%s\n%s", fileName, fileName, output)
+}
+
+func formatDiffLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// formatDiffOps computes the longest common subsequence of the lines
+// of a and b, and returns the edit operations turning a into b.
+func formatDiffOps(a []string, b []string) []formatDiffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	ops := []formatDiffOp{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, formatDiffOp{Kind: ' ', Line: a[i], A: i, B: j})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, formatDiffOp{Kind: '-', Line: a[i], A: i, B: j})
+			i = i + 1
+		default:
+			ops = append(ops, formatDiffOp{Kind: '+', Line: b[j], A: i, B: j})
+			j = j + 1
+		}
+	}
+	return ops
+}
+
+func formatDiffHunk(ops []formatDiffOp) string {
+	aCount, bCount := 0, 0
+	lines := ""
+	for _, op := range ops {
+		switch op.Kind {
+		case ' ':
+			aCount, bCount = aCount+1, bCount+1
+		case '-':
+			aCount = aCount + 1
+		case '+':
+			bCount = bCount + 1
+		}
+		lines = fmt.Sprintf("%s%c%s", lines, op.Kind, op.Line)
+		if !strings.HasSuffix(op.Line, "\n") {
+			lines = lines + "\n\\ No newline at end of file\n"
+		}
+	}
+	aStart, bStart := ops[0].A, ops[0].B
+	if aCount > 0 {
+		aStart = aStart + 1
+	}
+	if bCount > 0 {
+		bStart = bStart + 1
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n%s", aStart, aCount, bStart, bCount, lines)
+}
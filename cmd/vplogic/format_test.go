@@ -0,0 +1,148 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrettyModelComments(t *testing.T) {
+	model := strings.Join([]string{
+		"// header",
+		"",
+		"attacker[passive]",
+		"",
+		"// Alice's secrets",
+		"principal Alice[",
+		"	// the key",
+		"	knows private k // long-term",
+		"	generates m",
+		"	// nothing else",
+		"]",
+		"",
+		"Alice -> Bob: m // in the clear",
+		"",
+		"principal Bob[",
+		"	knows private k",
+		"	h = HASH(m)",
+		"]",
+		"",
+		"// queries follow",
+		"queries[",
+		"	// secrecy",
+		"	confidentiality? m // expect: fail",
+		"	authentication? Alice -> Bob: m[",
+		"		// only once sent",
+		"		precondition[Alice -> Bob: m] // trivially",
+		"	]",
+		"	// more to come",
+		"]",
+		"",
+		"// end",
+		"",
+	}, "\n")
+	s := NewSessionWithOptions(Options{})
+	m, err := s.ParseBytes("comments.vp", []byte(model))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pretty, err := s.PrettyModel(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pretty != model {
+		t.Errorf("expected comments to be kept in place, got:\n%s", pretty)
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	original := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk"
+	formatted := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	expected := strings.Join([]string{
+		"--- a/m.vp",
+		"+++ b/m.vp",
+		"@@ -1,5 +1,5 @@",
+		" a",
+		"-b",
+		"+B",
+		" c",
+		" d",
+		" e",
+		"@@ -8,4 +8,4 @@",
+		" h",
+		" i",
+		" j",
+		"-k",
+		"\\ No newline at end of file",
+		"+k",
+		"",
+	}, "\n")
+	diff := FormatDiff("m.vp", []byte(original), []byte(formatted))
+	if diff != expected {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+	if FormatDiff("m.vp", []byte(formatted), []byte(formatted)) != "" {
+		t.Error("expected no diff between identical sources")
+	}
+}
+
+func TestTranslateComments(t *testing.T) {
+	s := NewSessionWithOptions(Options{})
+	m, err := s.ParseBytes("comments.vp", []byte(strings.Join([]string{
+		"// header",
+		"attacker[passive]",
+		"principal Alice[",
+		"	// the key",
+		"	knows private k",
+		"	generates m // fresh *)",
+		"]",
+		"// send it",
+		"Alice -> Bob: m",
+		"principal Bob[knows private k]",
+		"queries[",
+		"	// (* nested (*)",
+		"	confidentiality? m // secret",
+		"]",
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valKnowledgeMap, _, err := s.sanity(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pv, err := s.pvModel(m, valKnowledgeMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coq, err := s.coqModel(m, valKnowledgeMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"(* header *)\nset expandIfTermsToTerms = true.",
+		"\t(* the key *)\n",
+		"\t(* fresh * ) *)\n",
+		"(* send it *)\nlet Alice_to_Bob_1() =",
+		"(* ( * nested ( * ) *)\nquery attacker(const_m). (* secret *)",
+	} {
+		if !strings.Contains(pv, expected) {
+			t.Errorf("expected ProVerif model to contain %q", expected)
+		}
+	}
+	for _, expected := range []string{
+		"(* Protocol: comments.vp *)\n(* header *)",
+		"(* the key *)\n\t\t\t\t(* knows k *)",
+		"unleaked\n\t\t\t\t(* fresh * ) *)\n\t\t\t\t])",
+		"\t\t(* send it *)\n\t\tmblock(MSG unguarded",
+		"(* ( * nested ( * ) *)\nCompute analysis",
+		"depth) depth.\n(* secret *)",
+	} {
+		if !strings.Contains(coq, expected) {
+			t.Errorf("expected Coq model to contain %q", expected)
+		}
+	}
+}
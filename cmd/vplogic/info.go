@@ -6,6 +6,7 @@ package vplogic
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -13,12 +14,9 @@ import (
 )
 
 // InfoMessage prints a Verifpal status message either in color or non-color format,
-// depending on what is supported by the terminal.
-func InfoMessage(m string, t string, showAnalysis bool) {
-	analysisCount := 0
-	if showAnalysis {
-		analysisCount = verifyAnalysisCountGet()
-	}
+// depending on what is supported by the terminal. A positive analysisCount
+// is displayed alongside the message.
+func InfoMessage(m string, t string, analysisCount int) {
 	if colorOutputSupport() {
 		InfoMessageColor(m, t, analysisCount)
 	} else {
@@ -26,35 +24,51 @@ func InfoMessage(m string, t string, showAnalysis bool) {
 	}
 }
 
+func (s *Session) infoMessage(m string, t string, showAnalysis bool) {
+	analysisCount := 0
+	if showAnalysis {
+		analysisCount = s.verifyAnalysisCountGet()
+	}
+	s.observe(EventMessage{
+		Type:          t,
+		Message:       m,
+		AnalysisCount: analysisCount,
+	})
+}
+
 // InfoMessageRegular prints a Verifpal status message in non-color format.
 func InfoMessageRegular(m string, t string, analysisCount int) {
+	infoMessageRegular(os.Stdout, m, t, analysisCount)
+}
+
+func infoMessageRegular(w io.Writer, m string, t string, analysisCount int) {
 	infoString := ""
 	if analysisCount > 0 {
 		infoString = fmt.Sprintf("(Analysis %d)", analysisCount)
 	}
 	switch t {
 	case "verifpal":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			" Verifpal • %s %s\n", m, infoString,
 		)
 	case "info":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"     Info • %s %s\n", m, infoString,
 		)
 	case "analysis":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			" Analysis • %s %s\n", m, infoString,
 		)
 	case "deduction":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"Deduction • %s %s\n", m, infoString,
 		)
 	case "result":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"   Result • %s %s\n", m, infoString,
 		)
 	case "warning":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"  Warning • %s %s\n", m, infoString,
 		)
 	}
@@ -62,6 +76,10 @@ func InfoMessageRegular(m string, t string, analysisCount int) {
 
 // InfoMessageColor prints a Verifpal status message in color format.
 func InfoMessageColor(m string, t string, analysisCount int) {
+	infoMessageColor(os.Stdout, m, t, analysisCount)
+}
+
+func infoMessageColor(w io.Writer, m string, t string, analysisCount int) {
 	infoString := ""
 	if analysisCount > 0 {
 		infoString = aurora.Faint(fmt.Sprintf(
@@ -70,39 +88,39 @@ func InfoMessageColor(m string, t string, analysisCount int) {
 	}
 	switch t {
 	case "verifpal":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"%s%s%s %s %s\n",
 			" ", aurora.Green("Verifpal").Bold(), " •", m, infoString,
 		)
 	case "info":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"%s%s%s %s %s\n",
 			"     ", aurora.Blue("Info").Bold(), " •", m, infoString,
 		)
 	case "analysis":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"%s%s%s %s %s\n",
 			" ", aurora.Blue("Analysis").Bold(), " •", m, infoString,
 		)
 	case "deduction":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"%s%s%s %s %s\n",
 			"", aurora.Magenta("Deduction").Bold(), " •", m, infoString,
 		)
 	case "result":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"%s%s%s %s %s\n",
 			"   ", aurora.Red("Result").Bold(), " •", m, infoString,
 		)
 	case "warning":
-		fmt.Fprintf(os.Stdout,
+		fmt.Fprintf(w,
 			"%s%s%s %s %s\n",
 			"  ", aurora.Red("Warning").Bold(), " •", m, infoString,
 		)
 	}
 }
 
-func infoVerifyResultSummary(
+func (s *Session) infoVerifyResultSummary(
 	mutatedInfo string, summary string, oResults []QueryOptionResult,
 ) string {
 	intro := ""
@@ -125,7 +143,7 @@ func infoVerifyResultSummary(
 	if len(mutatedInfo) > 0 {
 		intro = "When:"
 	}
-	if colorOutputSupport() {
+	if s.options.Color {
 		return fmt.Sprintf("%s%s\n            %s\n%s",
 			aurora.Italic(intro).String(), mutatedInfo,
 			aurora.BgRed(summary).White().Italic().Bold().String(),
@@ -137,47 +155,29 @@ func infoVerifyResultSummary(
 	)
 }
 
-func infoAnalysis(stage int) {
-	a := ""
-	s := ""
-	analysisCount := verifyAnalysisCountGet()
-	switch {
-	case analysisCount > 100000:
-		if analysisCount%10000 != 0 {
-			return
-		}
-	case analysisCount > 10000:
-		if analysisCount%1000 != 0 {
-			return
-		}
-	case analysisCount > 1000:
-		if analysisCount%100 != 0 {
-			return
-		}
-	case analysisCount > 100:
-		if analysisCount%10 != 0 {
-			return
-		}
-	}
-	switch {
-	case stage == 1:
-		s = "1"
-	case stage == 2 || stage == 3:
-		s = "2-3"
-	case stage == 4 || stage == 5:
-		s = "4-5"
-	default:
-		s = fmt.Sprintf("%d", stage)
-	}
-	if colorOutputSupport() {
-		a = aurora.Faint(fmt.Sprintf(
-			" Stage %s, Analysis %d...", s, analysisCount,
-		)).Italic().String()
-	} else {
-		a = fmt.Sprintf(" Stage %s, Analysis %d...", s, analysisCount)
-	}
-	fmt.Fprint(os.Stdout, a)
-	fmt.Fprint(os.Stdout, "\r\r\r\r")
+func (s *Session) infoAnalysis(stage int) {
+	s.observe(EventAnalysis{
+		Stage:         stage,
+		AnalysisCount: s.verifyAnalysisCountGet(),
+	})
+}
+
+func (s *Session) infoDeduction(revealed *Value, rule string, m string) {
+	s.observe(EventAttackerLearned{
+		Value:         prettyValue(revealed),
+		Rule:          rule,
+		Message:       m,
+		AnalysisCount: s.verifyAnalysisCountGet(),
+	})
+}
+
+func (s *Session) infoQueryResolved(result VerifyResult) {
+	result.Status = VerifyStatusAttackFound
+	s.observe(EventQueryResolved{
+		Query:         s.prettyQuery(result.Query),
+		Result:        result,
+		AnalysisCount: s.verifyAnalysisCountGet(),
+	})
 }
 
 func infoLiteralNumber(n int, titleCase bool) string {
@@ -228,11 +228,12 @@ func infoOutputText(revealed *Value) string {
 	}
 }
 
-func infoQueryMutatedValues(
+func (s *Session) infoQueryMutatedValues(
 	valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
 	valAttackerState AttackerState, targetValue *Value, infoDepth int,
-) string {
+) (string, []VerifyTraceStep) {
 	mutated := []*Value{}
+	trace := []VerifyTraceStep{}
 	targetInfo := "In another session:"
 	mutatedInfo := ""
 	relevant := false
@@ -243,9 +244,9 @@ func infoQueryMutatedValues(
 		isTargetValue := valueEquivalentValues(
 			targetValue, valPrincipalState.Assigned[i], false,
 		)
-		attackerKnows := valueEquivalentValueInValues(targetValue, valAttackerState.Known) >= 0
+		attackerKnows := attackerStateKnownIndex(valAttackerState, targetValue) >= 0
 		if isTargetValue && attackerKnows {
-			if colorOutputSupport() {
+			if s.options.Color {
 				targetInfo = fmt.Sprintf(
 					"%s %s",
 					aurora.Italic(prettyValue(targetValue)).String(),
@@ -261,26 +262,27 @@ func infoQueryMutatedValues(
 				mutated = append(mutated, valPrincipalState.Assigned[i])
 			}
 		}
-		mInfo, mRelevant := infoQueryMutatedValue(
+		mInfo, mRelevant, mStep := s.infoQueryMutatedValue(
 			valKnowledgeMap, valPrincipalState, i, isTargetValue, attackerKnows,
 		)
 		if mRelevant {
 			relevant = true
 		}
 		mutatedInfo = fmt.Sprintf("%s\n            %s", mutatedInfo, mInfo)
+		trace = append(trace, mStep)
 	}
 	if !relevant {
-		return ""
+		return "", []VerifyTraceStep{}
 	}
 	if infoDepth >= 2 {
-		return mutatedInfo
+		return mutatedInfo, trace
 	}
 	for _, m := range mutated {
-		ai := valueEquivalentValueInValues(m, valAttackerState.Known)
+		ai := attackerStateKnownIndex(valAttackerState, m)
 		if ai < 0 {
 			continue
 		}
-		mmInfo := infoQueryMutatedValues(
+		mmInfo, mmTrace := s.infoQueryMutatedValues(
 			valKnowledgeMap, valAttackerState.PrincipalState[ai],
 			valAttackerState, m, infoDepth+1,
 		)
@@ -289,20 +291,26 @@ func infoQueryMutatedValues(
 				"%s\n\n            %s%s",
 				mmInfo, targetInfo, mutatedInfo,
 			)
+			trace = append(mmTrace, trace...)
 		}
 	}
-	return mutatedInfo
+	return mutatedInfo, trace
 }
 
-func infoQueryMutatedValue(
+func (s *Session) infoQueryMutatedValue(
 	valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
 	index int, isTargetValue bool, attackerKnows bool,
-) (string, bool) {
+) (string, bool, VerifyTraceStep) {
 	pc := prettyConstant(valPrincipalState.Constants[index])
 	pa := prettyValue(valPrincipalState.Assigned[index])
+	step := VerifyTraceStep{
+		Constant: valPrincipalState.Constants[index],
+		Value:    pa,
+		Note:     "",
+	}
 	relevant := false
 	pn := make([]string, 4)
-	if colorOutputSupport() {
+	if s.options.Color {
 		pn[0] = aurora.BrightYellow(pc).Italic().String()
 		pn[1] = aurora.BrightYellow(" → ").Italic().String()
 		pn[2] = aurora.BrightYellow(pa).Italic().String()
@@ -313,13 +321,13 @@ func infoQueryMutatedValue(
 			pn[1] = aurora.BrightYellow(" → ").Italic().Underline().String()
 			pn[2] = aurora.BrightYellow(pa).Italic().Underline().String()
 			pn[3] = aurora.Red(" ← obtained by Attacker").Italic().String()
+			step.Note = "obtained by Attacker"
 		} else if valPrincipalState.Mutated[index] {
 			relevant = true
-			pn[3] = aurora.Red(
-				fmt.Sprintf(" ← mutated by Attacker (originally %s)",
-					prettyValue(valKnowledgeMap.Assigned[index]),
-				),
-			).Italic().String()
+			step.Note = fmt.Sprintf("mutated by Attacker (originally %s)",
+				prettyValue(valKnowledgeMap.Assigned[index]),
+			)
+			pn[3] = aurora.Red(" ← " + step.Note).Italic().String()
 		}
 	} else {
 		pn[0] = pc
@@ -328,13 +336,15 @@ func infoQueryMutatedValue(
 		pn[3] = ""
 		if isTargetValue && !valPrincipalState.Mutated[index] {
 			relevant = true
-			pn[3] = " ← obtained by Attacker"
+			step.Note = "obtained by Attacker"
+			pn[3] = " ← " + step.Note
 		} else if valPrincipalState.Mutated[index] {
 			relevant = true
-			pn[3] = fmt.Sprintf(" ← mutated by Attacker (originally %s)",
+			step.Note = fmt.Sprintf("mutated by Attacker (originally %s)",
 				prettyValue(valKnowledgeMap.Assigned[index]),
 			)
+			pn[3] = " ← " + step.Note
 		}
 	}
-	return strings.Join(pn, ""), relevant
+	return strings.Join(pn, ""), relevant, step
 }
@@ -8,14 +8,14 @@ import (
 	"fmt"
 )
 
-func inject(
+func (s *Session) inject(
 	p *Primitive, injectDepth int,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState, stage int,
 ) []*Value {
-	if verifyResultsAllResolved() {
+	if s.verifyResultsAllResolved() {
 		return []*Value{}
 	}
-	return injectPrimitive(
+	return s.injectPrimitive(
 		p, valPrincipalState, valAttackerState, injectDepth, stage,
 	)
 }
@@ -148,7 +148,7 @@ func injectMatchSkeletons(p *Primitive, skeleton *Primitive) bool {
 	return valueEquivalentValues(&pv, &sv, true)
 }
 
-func injectMissingSkeletons(p *Primitive, valPrincipalState *PrincipalState, valAttackerState AttackerState) {
+func (s *Session) injectMissingSkeletons(p *Primitive, valPrincipalState *PrincipalState, valAttackerState AttackerState) {
 	skeleton, _ := injectPrimitiveSkeleton(p, 0)
 	matchingSkeleton := false
 SkeletonSearch:
@@ -166,8 +166,8 @@ SkeletonSearch:
 			Kind: typesEnumPrimitive,
 			Data: skeleton,
 		}
-		if attackerStatePutWrite(known, valPrincipalState) {
-			InfoMessage(fmt.Sprintf(
+		if s.attackerStatePutWrite(known, valPrincipalState) {
+			s.infoMessage(fmt.Sprintf(
 				"Constructed skeleton %s based on %s.",
 				prettyPrimitive(skeleton), prettyPrimitive(p),
 			), "analysis", true)
@@ -176,12 +176,12 @@ SkeletonSearch:
 	for _, a := range p.Arguments {
 		switch a.Kind {
 		case typesEnumPrimitive:
-			injectMissingSkeletons(a.Data.(*Primitive), valPrincipalState, valAttackerState)
+			s.injectMissingSkeletons(a.Data.(*Primitive), valPrincipalState, valAttackerState)
 		}
 	}
 }
 
-func injectPrimitive(
+func (s *Session) injectPrimitive(
 	p *Primitive, valPrincipalState *PrincipalState, valAttackerState AttackerState,
 	injectDepth int, stage int,
 ) []*Value {
@@ -211,7 +211,7 @@ func injectPrimitive(
 					kinjectants[arg] = append(kinjectants[arg], k)
 				}
 				if stage >= 5 && injectDepth <= stage-5 {
-					uinjectants, kinjectants = injectPrimitiveRecursively(
+					uinjectants, kinjectants = s.injectPrimitiveRecursively(
 						k, arg, uinjectants, kinjectants,
 						valPrincipalState, valAttackerState, injectDepth, stage,
 					)
@@ -224,15 +224,15 @@ func injectPrimitive(
 			}
 		}
 	}
-	return injectLoopN(p, kinjectants)
+	return s.injectLoopN(p, kinjectants)
 }
 
-func injectPrimitiveRecursively(
+func (s *Session) injectPrimitiveRecursively(
 	k *Value, arg int, uinjectants [][]*Value, kinjectants [][]*Value,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState,
 	injectDepth int, stage int,
 ) ([][]*Value, [][]*Value) {
-	kp := inject(
+	kp := s.inject(
 		k.Data.(*Primitive), injectDepth+1,
 		valPrincipalState, valAttackerState, stage,
 	)
@@ -245,13 +245,13 @@ func injectPrimitiveRecursively(
 	return uinjectants, kinjectants
 }
 
-func injectLoopN(p *Primitive, kinjectants [][]*Value) []*Value {
-	if verifyResultsAllResolved() {
+func (s *Session) injectLoopN(p *Primitive, kinjectants [][]*Value) []*Value {
+	if s.verifyResultsAllResolved() {
 		return []*Value{}
 	}
 	switch len(p.Arguments) {
 	case 1:
-		return injectLoop1(p, kinjectants)
+		return s.injectLoop1(p, kinjectants)
 	case 2:
 		return injectLoop2(p, kinjectants)
 	case 3:
@@ -264,9 +264,9 @@ func injectLoopN(p *Primitive, kinjectants [][]*Value) []*Value {
 	return []*Value{}
 }
 
-func injectLoop1(p *Primitive, kinjectants [][]*Value) []*Value {
+func (s *Session) injectLoop1(p *Primitive, kinjectants [][]*Value) []*Value {
 	injectants := []*Value{}
-	if verifyResultsAllResolved() {
+	if s.verifyResultsAllResolved() {
 		return []*Value{}
 	}
 	for i := range kinjectants[0] {
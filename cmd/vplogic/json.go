@@ -8,13 +8,18 @@ package vplogic
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 )
 
 // JSON processes JSON requests made to Verifpal via the Visual Studio Code extension.
+// The "rpc" request starts a long-running JSON-RPC server instead (see JSONRPC).
 func JSON(request string) error {
+	if request == "rpc" {
+		return JSONRPC(os.Stdin, os.Stdout)
+	}
 	reader := bufio.NewReader(os.Stdin)
 	inputString, _ := reader.ReadString(byte(0x04))
 	inputString = inputString[:len(inputString)-1]
@@ -40,11 +45,7 @@ func JSON(request string) error {
 
 // JSONKnowledgeMap returns the KnowledgeMap struct for a given model in JSON format.
 func JSONKnowledgeMap(inputString string) (*KnowledgeMap, error) {
-	m, err := Parse("model.vp", []byte(inputString))
-	if err != nil {
-		return &KnowledgeMap{}, err
-	}
-	valKnowledgeMap, _, err := sanity(m.(Model))
+	valKnowledgeMap, err := jsonKnowledgeMap(NewSession(), inputString)
 	if err != nil {
 		return &KnowledgeMap{}, err
 	}
@@ -55,11 +56,7 @@ func JSONKnowledgeMap(inputString string) (*KnowledgeMap, error) {
 
 // JSONPrincipalStates returns the KnowledgeMap struct for a given model in JSON format.
 func JSONPrincipalStates(inputString string) error {
-	m, err := Parse("model.vp", []byte(inputString))
-	if err != nil {
-		return err
-	}
-	_, valPrincipalStates, err := sanity(m.(Model))
+	valPrincipalStates, err := jsonPrincipalStates(NewSession(), inputString)
 	if err != nil {
 		return err
 	}
@@ -70,33 +67,27 @@ func JSONPrincipalStates(inputString string) error {
 
 // JSONPrettyValue pretty-prints a Verifpal value expression and returns the result in JSON format.
 func JSONPrettyValue(inputString string) error {
-	a := &Value{}
-	err := json.Unmarshal([]byte(inputString), a)
+	pretty, err := jsonPrettyValue(inputString)
 	if err != nil {
 		return err
 	}
-	fmt.Fprint(os.Stdout, prettyValue(a))
+	fmt.Fprint(os.Stdout, pretty)
 	return nil
 }
 
 // JSONPrettyQuery pretty-prints a Verifpal query expression and returns the result in JSON format.
 func JSONPrettyQuery(inputString string) error {
-	q := Query{}
-	err := json.Unmarshal([]byte(inputString), &q)
+	pretty, err := jsonPrettyQuery(NewSession(), inputString)
 	if err != nil {
 		return err
 	}
-	fmt.Fprint(os.Stdout, prettyQuery(q))
+	fmt.Fprint(os.Stdout, pretty)
 	return nil
 }
 
 // JSONPrettyPrint pretty-prints a Verifpal model and returns the result in JSON format.
 func JSONPrettyPrint(inputString string) error {
-	m, err := Parse("model.vp", []byte(inputString))
-	if err != nil {
-		return err
-	}
-	pretty, err := PrettyModel(m.(Model))
+	pretty, err := jsonPrettyPrint(NewSession(), inputString)
 	if err != nil {
 		return err
 	}
@@ -106,11 +97,7 @@ func JSONPrettyPrint(inputString string) error {
 
 // JSONPrettyDiagram formats a Verifpal model into a sequence diagram and returns the result in JSON format.
 func JSONPrettyDiagram(inputString string) error {
-	m, err := Parse("model.vp", []byte(inputString))
-	if err != nil {
-		return err
-	}
-	pretty, err := PrettyDiagram(m.(Model))
+	pretty, err := jsonPrettyDiagram(NewSession(), inputString)
 	if err != nil {
 		return err
 	}
@@ -120,11 +107,7 @@ func JSONPrettyDiagram(inputString string) error {
 
 // JSONVerify returns the verification result of a Verifpal model in JSON format.
 func JSONVerify(inputString string) error {
-	m, err := Parse("model.vp", []byte(inputString))
-	if err != nil {
-		return err
-	}
-	valVerifyResults, _, err := verifyModel(m.(Model))
+	valVerifyResults, err := jsonVerify(context.Background(), NewSession(), inputString)
 	if err != nil {
 		return err
 	}
@@ -132,3 +115,64 @@ func JSONVerify(inputString string) error {
 	fmt.Fprint(os.Stdout, string(j))
 	return nil
 }
+
+func jsonKnowledgeMap(s *Session, inputString string) (*KnowledgeMap, error) {
+	m, err := s.libpegParse("model.vp", []byte(inputString))
+	if err != nil {
+		return &KnowledgeMap{}, err
+	}
+	valKnowledgeMap, _, err := s.sanity(m)
+	return valKnowledgeMap, err
+}
+
+func jsonPrincipalStates(s *Session, inputString string) ([]*PrincipalState, error) {
+	m, err := s.libpegParse("model.vp", []byte(inputString))
+	if err != nil {
+		return []*PrincipalState{}, err
+	}
+	_, valPrincipalStates, err := s.sanity(m)
+	return valPrincipalStates, err
+}
+
+func jsonPrettyValue(inputString string) (string, error) {
+	a := &Value{}
+	err := json.Unmarshal([]byte(inputString), a)
+	if err != nil {
+		return "", err
+	}
+	return prettyValue(a), nil
+}
+
+func jsonPrettyQuery(s *Session, inputString string) (string, error) {
+	q := Query{}
+	err := json.Unmarshal([]byte(inputString), &q)
+	if err != nil {
+		return "", err
+	}
+	return s.prettyQuery(q), nil
+}
+
+func jsonPrettyPrint(s *Session, inputString string) (string, error) {
+	m, err := s.libpegParse("model.vp", []byte(inputString))
+	if err != nil {
+		return "", err
+	}
+	return s.PrettyModel(m)
+}
+
+func jsonPrettyDiagram(s *Session, inputString string) (string, error) {
+	m, err := s.libpegParse("model.vp", []byte(inputString))
+	if err != nil {
+		return "", err
+	}
+	return s.PrettyDiagram(m)
+}
+
+func jsonVerify(ctx context.Context, s *Session, inputString string) ([]VerifyResult, error) {
+	m, err := s.libpegParse("model.vp", []byte(inputString))
+	if err != nil {
+		return []VerifyResult{}, err
+	}
+	valVerifyResults, _, err := s.verifyModel(ctx, m)
+	return valVerifyResults, err
+}
@@ -0,0 +1,259 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	jsonRPCErrorParse            = -32700
+	jsonRPCErrorMethodNotFound   = -32601
+	jsonRPCErrorInvalidParams    = -32602
+	jsonRPCErrorInternal         = -32603
+	jsonRPCErrorServer           = -32000
+	jsonRPCErrorRequestCancelled = -32800
+	jsonRPCProgressInterval      = 250 * time.Millisecond
+)
+
+// JSONRPC runs a JSON-RPC 2.0 server for editor integrations, reading requests
+// from r and writing responses and notifications to w, until the client sends an
+// exit notification or r is closed. Each message is preceded by a Content-Length
+// header, as in the Language Server Protocol.
+// The methods supported are those of JSON: knowledgeMap, principalStates,
+// prettyPrint, prettyDiagram and verify, which take the model's source as their
+// model parameter, as well as prettyValue and prettyQuery, which take a Value as
+// their value parameter and a Query as their query parameter respectively.
+// Requests are processed concurrently, and can be cancelled with a $/cancelRequest
+// notification. The events emitted while processing a verify request are sent as
+// verify/progress notifications.
+func JSONRPC(r io.Reader, w io.Writer) error {
+	server := &jsonRPCServer{
+		Reader:  bufio.NewReader(r),
+		Writer:  w,
+		Cancels: map[string]context.CancelFunc{},
+	}
+	defer server.Pending.Wait()
+	defer server.cancelAll()
+	for {
+		body, err := jsonRPCRead(server.Reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg := jsonRPCMessage{}
+		err = json.Unmarshal(body, &msg)
+		if err != nil {
+			err = server.respond(nil, nil, &jsonRPCError{Code: jsonRPCErrorParse, Message: err.Error()})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		switch {
+		case msg.Method == "exit":
+			return nil
+		case msg.Method == "$/cancelRequest":
+			params := jsonRPCCancelParams{}
+			if json.Unmarshal(msg.Params, &params) == nil {
+				server.cancel(string(params.ID))
+			}
+		case msg.ID != nil:
+			server.start(msg)
+		}
+	}
+}
+
+// jsonRPCRead reads the body of the next message, skipping
+// the headers preceding it.
+func jsonRPCRead(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return []byte{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return []byte{}, fmt.Errorf("invalid content length (%s)", strings.TrimSpace(value))
+			}
+		}
+	}
+	if length < 0 {
+		return []byte{}, fmt.Errorf("message has no content length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(reader, body)
+	return body, err
+}
+
+func jsonRPCWrite(w io.Writer, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func jsonRPCWriteResponse(w io.Writer, id *json.RawMessage, result interface{}, rpcErr *jsonRPCError) error {
+	response := jsonRPCResponse{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		r, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		raw := json.RawMessage(r)
+		response.Result = &raw
+	}
+	return jsonRPCWrite(w, response)
+}
+
+func jsonRPCWriteNotification(w io.Writer, method string, params interface{}) error {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return jsonRPCWrite(w, jsonRPCMessage{JSONRPC: "2.0", Method: method, Params: p})
+}
+
+func (server *jsonRPCServer) respond(id *json.RawMessage, result interface{}, rpcErr *jsonRPCError) error {
+	server.WriteMutex.Lock()
+	defer server.WriteMutex.Unlock()
+	return jsonRPCWriteResponse(server.Writer, id, result, rpcErr)
+}
+
+func (server *jsonRPCServer) notify(method string, params interface{}) error {
+	server.WriteMutex.Lock()
+	defer server.WriteMutex.Unlock()
+	return jsonRPCWriteNotification(server.Writer, method, params)
+}
+
+// start processes a request in the background, until it completes or is cancelled.
+func (server *jsonRPCServer) start(msg jsonRPCMessage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	key := string(*msg.ID)
+	server.CancelsMutex.Lock()
+	server.Cancels[key] = cancel
+	server.CancelsMutex.Unlock()
+	server.Pending.Add(1)
+	go func() {
+		defer server.Pending.Done()
+		result, rpcErr := server.handle(ctx, msg)
+		server.CancelsMutex.Lock()
+		delete(server.Cancels, key)
+		server.CancelsMutex.Unlock()
+		cancel()
+		_ = server.respond(msg.ID, result, rpcErr)
+	}()
+}
+
+func (server *jsonRPCServer) cancel(key string) {
+	server.CancelsMutex.Lock()
+	cancel, ok := server.Cancels[key]
+	server.CancelsMutex.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (server *jsonRPCServer) cancelAll() {
+	server.CancelsMutex.Lock()
+	for _, cancel := range server.Cancels {
+		cancel()
+	}
+	server.CancelsMutex.Unlock()
+}
+
+func (server *jsonRPCServer) handle(ctx context.Context, msg jsonRPCMessage) (result interface{}, rpcErr *jsonRPCError) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, rpcErr = nil, &jsonRPCError{
+				Code: jsonRPCErrorInternal, Message: fmt.Sprintf("%s failed (%v)", msg.Method, r),
+			}
+		}
+	}()
+	s := NewSessionWithOptions(Options{})
+	var err error
+	switch msg.Method {
+	case "prettyValue":
+		params := jsonRPCValueParams{}
+		if err = json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &jsonRPCError{Code: jsonRPCErrorInvalidParams, Message: err.Error()}
+		}
+		result, err = jsonPrettyValue(string(params.Value))
+	case "prettyQuery":
+		params := jsonRPCQueryParams{}
+		if err = json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &jsonRPCError{Code: jsonRPCErrorInvalidParams, Message: err.Error()}
+		}
+		result, err = jsonPrettyQuery(s, string(params.Query))
+	case "knowledgeMap", "principalStates", "prettyPrint", "prettyDiagram", "verify":
+		params := jsonRPCModelParams{}
+		if err = json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &jsonRPCError{Code: jsonRPCErrorInvalidParams, Message: err.Error()}
+		}
+		switch msg.Method {
+		case "knowledgeMap":
+			result, err = jsonKnowledgeMap(s, params.Model)
+		case "principalStates":
+			result, err = jsonPrincipalStates(s, params.Model)
+		case "prettyPrint":
+			result, err = jsonPrettyPrint(s, params.Model)
+		case "prettyDiagram":
+			result, err = jsonPrettyDiagram(s, params.Model)
+		case "verify":
+			s = NewSessionWithOptions(Options{
+				Observers: []Observer{&jsonRPCProgress{Server: server, ID: msg.ID}},
+			})
+			result, err = jsonVerify(ctx, s, params.Model)
+		}
+	default:
+		return nil, &jsonRPCError{
+			Code:    jsonRPCErrorMethodNotFound,
+			Message: fmt.Sprintf("unsupported method (%s)", msg.Method),
+		}
+	}
+	switch {
+	case ctx.Err() != nil:
+		return nil, &jsonRPCError{Code: jsonRPCErrorRequestCancelled, Message: "request cancelled"}
+	case err != nil:
+		return nil, &jsonRPCError{Code: jsonRPCErrorServer, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// Observe sends an event as a verify/progress notification.
+func (o *jsonRPCProgress) Observe(event Event) {
+	switch event.(type) {
+	case EventAnalysis:
+		o.mutex.Lock()
+		if time.Since(o.lastAnalysis) < jsonRPCProgressInterval {
+			o.mutex.Unlock()
+			return
+		}
+		o.lastAnalysis = time.Now()
+		o.mutex.Unlock()
+	}
+	_ = o.Server.notify("verify/progress", jsonRPCProgressParams{
+		ID: o.ID, Kind: event.Kind(), Event: event,
+	})
+}
@@ -0,0 +1,95 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJSONRPC(t *testing.T) {
+	fast, err := os.ReadFile("../../examples/test/hmac_ok.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, err := os.ReadFile("../../examples/test/signal_small_leaks.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- JSONRPC(inputReader, outputWriter)
+		outputWriter.Close()
+	}()
+	send := func(message map[string]interface{}) {
+		message["jsonrpc"] = "2.0"
+		body, _ := json.Marshal(message)
+		fmt.Fprintf(inputWriter, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	go func() {
+		send(map[string]interface{}{"id": 1, "method": "prettyPrint", "params": map[string]string{"model": string(fast)}})
+		send(map[string]interface{}{"id": 2, "method": "knowledgeMap", "params": map[string]string{"model": string(fast)}})
+		send(map[string]interface{}{"id": 3, "method": "verify", "params": map[string]string{"model": string(fast)}})
+		send(map[string]interface{}{"id": "slow", "method": "verify", "params": map[string]string{"model": string(slow)}})
+		send(map[string]interface{}{"method": "$/cancelRequest", "params": map[string]string{"id": "slow"}})
+		send(map[string]interface{}{"id": 5, "method": "prettyDiagram", "params": map[string]string{"model": "attacker[active]\n["}})
+		send(map[string]interface{}{"id": 6, "method": "unknown", "params": map[string]string{}})
+	}()
+	responses := map[string]jsonRPCResponse{}
+	progress := 0
+	reader := bufio.NewReader(outputReader)
+	for len(responses) < 6 {
+		body, err := jsonRPCRead(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := jsonRPCResponse{}
+		_ = json.Unmarshal(body, &response)
+		if response.ID == nil {
+			notification := jsonRPCMessage{}
+			_ = json.Unmarshal(body, &notification)
+			if notification.Method == "verify/progress" && strings.Contains(string(notification.Params), `"id":3`) {
+				progress = progress + 1
+			}
+			continue
+		}
+		responses[string(*response.ID)] = response
+	}
+	send(map[string]interface{}{"method": "exit"})
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	for id, expected := range map[string]string{
+		"1":      `attacker[active]\n\nprincipal Alice[\n\tknows private a`,
+		"2":      `"Constants":[`,
+		"3":      `"Resolved":false`,
+		`"slow"`: `request cancelled`,
+		"5":      `model.vp:2:1:`,
+		"6":      `unsupported method (unknown)`,
+	} {
+		response, ok := responses[id]
+		if !ok {
+			t.Errorf("expected a response to request %s", id)
+			continue
+		}
+		if response.Result != nil && !strings.Contains(string(*response.Result), expected) ||
+			response.Error != nil && !strings.Contains(response.Error.Message, expected) {
+			t.Errorf("expected the response to request %s to contain %q, got %s, %v", id, expected, *response.Result, response.Error)
+		}
+	}
+	if responses[`"slow"`].Error == nil || responses[`"slow"`].Error.Code != jsonRPCErrorRequestCancelled {
+		t.Errorf("expected the slow verification to be cancelled")
+	}
+	if progress == 0 {
+		t.Error("expected progress notifications during verification")
+	}
+}
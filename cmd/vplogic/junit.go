@@ -0,0 +1,73 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteReportsJUnit writes Reports as a JUnit XML document, with one test suite
+// per model and one test case per query. Failed queries are reported as failures
+// and inconclusive queries as skipped.
+func WriteReportsJUnit(w io.Writer, reports []Report) error {
+	suites := junitTestSuites{TestSuites: []junitTestSuite{}}
+	seconds := 0.0
+	for _, r := range reports {
+		suite := junitReportSuite(r)
+		suites.Tests = suites.Tests + suite.Tests
+		suites.Failures = suites.Failures + suite.Failures
+		suites.Skipped = suites.Skipped + suite.Skipped
+		suites.TestSuites = append(suites.TestSuites, suite)
+		seconds = seconds + r.Seconds
+	}
+	suites.Time = junitSeconds(seconds)
+	x, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, x)
+	return err
+}
+
+func junitReportSuite(r Report) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      r.Model,
+		Tests:     len(r.Queries),
+		Time:      junitSeconds(r.Seconds),
+		Timestamp: r.Started.Format(time.RFC3339),
+		TestCases: []junitTestCase{},
+	}
+	for _, q := range r.Queries {
+		testCase := junitTestCase{
+			Name:      q.Query,
+			ClassName: r.Model,
+		}
+		switch q.Status {
+		case VerifyStatusAttackFound:
+			suite.Failures = suite.Failures + 1
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%s query fails.", q.Kind),
+				Type:    q.Kind,
+				Body:    q.Summary,
+			}
+		case VerifyStatusInconclusive:
+			suite.Skipped = suite.Skipped + 1
+			testCase.Skipped = &junitSkipped{
+				Message: fmt.Sprintf(
+					"Inconclusive: no attack was found before analysis stopped at stage %d.", q.Stage,
+				),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	return suite
+}
+
+func junitSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
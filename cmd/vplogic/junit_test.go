@@ -0,0 +1,52 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestVerifyReportJUnit(t *testing.T) {
+	reports := NewReportCollector()
+	_, _, err := VerifyFile(
+		context.Background(), "../../examples/test/hmac_unguarded_bob.vp",
+		Options{Color: true, Observers: []Observer{reports}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x bytes.Buffer
+	err = WriteReportsJUnit(&x, reports.Reports())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded junitTestSuites
+	err = xml.Unmarshal(x.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.TestSuites) != 1 || decoded.Tests != 2 || decoded.Failures != 1 {
+		t.Fatalf("expected one suite with two tests and one failure, got %v", decoded)
+	}
+	suite := decoded.TestSuites[0]
+	if suite.Name != "hmac_unguarded_bob.vp" || suite.Time == "" {
+		t.Errorf("unexpected suite: %s, %s", suite.Name, suite.Time)
+	}
+	failed := suite.TestCases[0]
+	if failed.Name != "confidentiality? plaintext" || failed.Failure == nil {
+		t.Fatalf("expected the confidentiality query to fail, got %v", failed)
+	}
+	if !strings.Contains(failed.Failure.Body, "is obtained by Attacker") ||
+		strings.Contains(failed.Failure.Body, "\x1b[") {
+		t.Errorf("expected the attack summary without terminal colors, got %q", failed.Failure.Body)
+	}
+	if suite.TestCases[1].Failure != nil || suite.TestCases[1].Skipped != nil {
+		t.Errorf("expected the authentication query to pass, got %v", suite.TestCases[1])
+	}
+}
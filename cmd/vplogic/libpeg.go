@@ -39,7 +39,10 @@ var libpegReserved = []string{
 	"shamir_join", "concat", "split", "unnamed",
 }
 
-var libpegUnnamedCounter = 0
+const (
+	libpegSessionKey   = "session"
+	libpegSourceMapKey = "sourceMap"
+)
 
 func libpegCheckIfReserved(s string) error {
 	found := false
@@ -57,7 +60,7 @@ func libpegCheckIfReserved(s string) error {
 	return nil
 }
 
-func libpegParseModel(filePath string, verbose bool) (Model, error) {
+func (s *Session) libpegParseModel(filePath string, verbose bool) (Model, error) {
 	fileName := filepath.Base(filePath)
 	if len(fileName) > 64 {
 		return Model{}, fmt.Errorf("model file name must be 64 characters or less")
@@ -66,7 +69,7 @@ func libpegParseModel(filePath string, verbose bool) (Model, error) {
 		return Model{}, fmt.Errorf("model file name must have a '.vp' extension")
 	}
 	if verbose {
-		InfoMessage(fmt.Sprintf(
+		s.infoMessage(fmt.Sprintf(
 			"Parsing model '%s'...", fileName,
 		), "verifpal", false)
 	}
@@ -74,32 +77,121 @@ func libpegParseModel(filePath string, verbose bool) (Model, error) {
 	if err != nil {
 		return Model{}, err
 	}
-	processed, err := preprocessModel(raw)
+	return s.libpegParseBytes(filePath, raw)
+}
+
+func (s *Session) libpegParseBytes(filePath string, raw []byte) (Model, error) {
+	processed, sm, err := preprocessModel(raw)
 	if err != nil {
-		return Model{}, err
+		return Model{}, modelErrorInFile(filepath.Base(filePath), err)
 	}
-	parsed, err := Parse(filePath, processed)
+	m, err := s.libpegParseMapped(filePath, processed, sm)
 	if err != nil {
 		return Model{}, err
 	}
-	m := parsed.(Model)
-	m.FileName = fileName
+	m.FileName = filepath.Base(filePath)
 	return m, nil
 }
 
-func preprocessModel(data []byte) ([]byte, error) {
+func (s *Session) libpegParse(filePath string, b []byte) (Model, error) {
+	return s.libpegParseMapped(filePath, b, nil)
+}
+
+// libpegParseMapped parses a preprocessed model, using sm to give positions
+// in the model's source rather than in the preprocessed model.
+func (s *Session) libpegParseMapped(filePath string, b []byte, sm *sourceMap) (Model, error) {
+	parsed, err := Parse(
+		filePath, b,
+		GlobalStore(libpegSessionKey, s),
+		GlobalStore(libpegSourceMapKey, sm),
+	)
+	if err != nil {
+		return Model{}, libpegError(filePath, err, sm)
+	}
+	return parsed.(Model), nil
+}
+
+func libpegError(filePath string, err error, sm *sourceMap) error {
+	errs, ok := err.(errList)
+	if !ok || len(errs) == 0 {
+		return err
+	}
+	pe, ok := errs[0].(*parserError)
+	if !ok {
+		return err
+	}
+	return &ModelError{
+		FileName: filepath.Base(filePath),
+		Position: sm.position(Position{
+			Line:   pe.pos.line,
+			Column: pe.pos.col,
+			Offset: pe.pos.offset,
+		}),
+		Err: pe.Inner,
+	}
+}
+
+func libpegSession(c *current) *Session {
+	s, ok := c.globalStore[libpegSessionKey].(*Session)
+	if !ok {
+		s = NewSession()
+		c.globalStore[libpegSessionKey] = s
+	}
+	return s
+}
+
+func libpegPosition(c *current) Position {
+	sm, _ := c.globalStore[libpegSourceMapKey].(*sourceMap)
+	return sm.position(Position{
+		Line:   c.pos.line,
+		Column: c.pos.col,
+		Offset: c.pos.offset,
+	})
+}
+
+func libpegComments(v interface{}) []Comment {
+	comments := []Comment{}
+	switch v := v.(type) {
+	case Comment:
+		comments = append(comments, v)
+	case []interface{}:
+		for _, c := range v {
+			comments = append(comments, c.(Comment))
+		}
+	}
+	return comments
+}
+
+// preprocessModel rewrites group additions and unary minuses as GROUPADD and
+// SCALARNEG primitives, and returns the rewritten model along with a sourceMap
+// from its positions to those of the model's source.
+func preprocessModel(data []byte) ([]byte, *sourceMap, error) {
 	lines := strings.Split(string(data), "\n")
+	sm := &sourceMap{lines: append([]string{}, lines...)}
+	offset := 0
+	processedOffset := 0
 	for i := range lines {
-		processed, err := preprocessLine(lines[i])
+		processed, offsets, err := preprocessLine(lines[i])
 		if err != nil {
-			return nil, err
+			return nil, nil, &ModelError{
+				Position: Position{Line: i + 1, Column: 1, Offset: offset},
+				Err:      err,
+			}
 		}
+		sm.starts = append(sm.starts, offset)
+		sm.processedStarts = append(sm.processedStarts, processedOffset)
+		sm.offsets = append(sm.offsets, offsets)
+		offset = offset + len(lines[i]) + 1
+		processedOffset = processedOffset + len(processed) + 1
 		lines[i] = processed
 	}
-	return []byte(strings.Join(lines, "\n")), nil
+	return []byte(strings.Join(lines, "\n")), sm, nil
 }
 
-func preprocessLine(line string) (string, error) {
+// preprocessLine rewrites a line of a model, and returns the rewritten line
+// along with the offset in the original line of each of its bytes, followed
+// by the length of the original line.
+func preprocessLine(line string) (string, []int, error) {
 	commentIndex := strings.Index(line, "//")
 	code := line
 	comment := ""
@@ -107,23 +199,29 @@ func preprocessLine(line string) (string, error) {
 		code = line[:commentIndex]
 		comment = line[commentIndex:]
 	}
-	code = transformUnaryMinus(code)
-	transformed, err := transformAdditions(code)
+	code, offsets := transformUnaryMinus(code)
+	transformed, offsets, err := transformAdditions(code, offsets)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	for i := range comment {
+		offsets = append(offsets, len(code)+i)
 	}
-	return transformed + comment, nil
+	offsets = append(offsets, len(line))
+	return transformed + comment, offsets, nil
 }
 
-func transformUnaryMinus(s string) string {
+func transformUnaryMinus(s string) (string, []int) {
 	var b strings.Builder
 	b.Grow(len(s))
+	offsets := make([]int, 0, len(s))
 	i := 0
 	for i < len(s) {
 		ch := s[i]
 		if ch == '-' {
 			if i+1 < len(s) && s[i+1] == '>' {
 				b.WriteByte('-')
+				offsets = append(offsets, i)
 				i++
 				continue
 			}
@@ -152,30 +250,78 @@ func transformUnaryMinus(s string) string {
 					b.WriteString("SCALARNEG(")
 					b.WriteString(operand)
 					b.WriteByte(')')
+					offsets = append(offsets, preprocessOffsets(i, len("SCALARNEG("))...)
+					for k := start; k < j; k++ {
+						offsets = append(offsets, k)
+					}
+					offsets = append(offsets, j)
 					i = j
 					continue
 				}
 			}
 		}
 		b.WriteByte(ch)
+		offsets = append(offsets, i)
 		i++
 	}
-	return b.String()
+	return b.String(), offsets
 }
 
-func transformAdditions(s string) (string, error) {
+// transformAdditions rewrites the group additions in s, where offsets holds the
+// offset in the original line of each byte of s, and returns the rewritten s
+// along with the offset in the original line of each of its bytes.
+func transformAdditions(s string, offsets []int) (string, []int, error) {
 	for {
 		idx := strings.Index(s, "+")
 		if idx < 0 {
-			return s, nil
+			return s, offsets, nil
 		}
 		leftStart, left := extractLeftOperand(s, idx)
 		rightEnd, right := extractRightOperand(s, idx)
 		if strings.TrimSpace(left) == "" || strings.TrimSpace(right) == "" {
-			return "", fmt.Errorf("invalid group addition around '%s'", s)
+			return "", nil, fmt.Errorf("invalid group addition around '%s'", s)
 		}
-		replacement := fmt.Sprintf("GROUPADD(%s, %s)", strings.TrimSpace(left), strings.TrimSpace(right))
+		leftIndex := leftStart + strings.Index(s[leftStart:idx], left)
+		rightIndex := idx + 1 + strings.Index(s[idx+1:rightEnd], right)
+		replacement := fmt.Sprintf("GROUPADD(%s, %s)", left, right)
+		replaced := append([]int{}, offsets[:leftStart]...)
+		replaced = append(replaced, preprocessOffsets(offsets[leftIndex], len("GROUPADD("))...)
+		replaced = append(replaced, offsets[leftIndex:leftIndex+len(left)]...)
+		replaced = append(replaced, preprocessOffsets(offsets[idx], len(", "))...)
+		replaced = append(replaced, offsets[rightIndex:rightIndex+len(right)]...)
+		replaced = append(replaced, offsets[rightIndex+len(right)-1]+1)
 		s = s[:leftStart] + replacement + s[rightEnd:]
+		offsets = append(replaced, offsets[rightEnd:]...)
+	}
+}
+
+// preprocessOffsets returns the offsets of n bytes inserted in place of
+// the byte at offset.
+func preprocessOffsets(offset int, n int) []int {
+	offsets := make([]int, n)
+	for i := range offsets {
+		offsets[i] = offset
+	}
+	return offsets
+}
+
+// position returns the position in the model's source of pos, a position in
+// the preprocessed model. A nil sourceMap returns pos unchanged.
+func (sm *sourceMap) position(pos Position) Position {
+	if sm == nil || pos.Line < 1 || pos.Line > len(sm.lines) {
+		return pos
+	}
+	i := pos.Line - 1
+	offsets := sm.offsets[i]
+	index := pos.Offset - sm.processedStarts[i]
+	if index < 0 || index >= len(offsets) {
+		return pos
+	}
+	offset := offsets[index]
+	return Position{
+		Line:   pos.Line,
+		Column: utf8.RuneCountInString(sm.lines[i][:offset]) + 1,
+		Offset: sm.starts[i] + offset,
 	}
 }
 
@@ -254,73 +400,81 @@ var g = &grammar{
 	rules: []*rule{
 		{
 			name: "Model",
-			pos:  position{line: 79, col: 1, offset: 1779},
+			pos:  position{line: 316, col: 1, offset: 6925},
 			expr: &actionExpr{
-				pos: position{line: 79, col: 10, offset: 1788},
+				pos: position{line: 316, col: 10, offset: 6934},
 				run: (*parser).callonModel1,
 				expr: &seqExpr{
-					pos: position{line: 79, col: 10, offset: 1788},
+					pos: position{line: 316, col: 10, offset: 6934},
 					exprs: []any{
 						&ruleRefExpr{
-							pos:  position{line: 79, col: 10, offset: 1788},
+							pos:  position{line: 316, col: 10, offset: 6934},
 							name: "_",
 						},
-						&zeroOrMoreExpr{
-							pos: position{line: 79, col: 12, offset: 1790},
-							expr: &ruleRefExpr{
-								pos:  position{line: 79, col: 12, offset: 1790},
-								name: "Comment",
+						&labeledExpr{
+							pos:   position{line: 316, col: 12, offset: 6936},
+							label: "Leading",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 316, col: 20, offset: 6944},
+								expr: &ruleRefExpr{
+									pos:  position{line: 316, col: 20, offset: 6944},
+									name: "Comment",
+								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 79, col: 21, offset: 1799},
+							pos:   position{line: 316, col: 29, offset: 6953},
 							label: "Attacker",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 79, col: 30, offset: 1808},
+								pos: position{line: 316, col: 38, offset: 6962},
 								expr: &ruleRefExpr{
-									pos:  position{line: 79, col: 30, offset: 1808},
+									pos:  position{line: 316, col: 38, offset: 6962},
 									name: "Attacker",
 								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 79, col: 40, offset: 1818},
+							pos:   position{line: 316, col: 48, offset: 6972},
 							label: "Blocks",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 79, col: 47, offset: 1825},
+								pos: position{line: 316, col: 55, offset: 6979},
 								expr: &oneOrMoreExpr{
-									pos: position{line: 79, col: 48, offset: 1826},
+									pos: position{line: 316, col: 56, offset: 6980},
 									expr: &ruleRefExpr{
-										pos:  position{line: 79, col: 48, offset: 1826},
+										pos:  position{line: 316, col: 56, offset: 6980},
 										name: "Block",
 									},
 								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 79, col: 57, offset: 1835},
+							pos:   position{line: 316, col: 65, offset: 6989},
 							label: "Queries",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 79, col: 65, offset: 1843},
+								pos: position{line: 316, col: 73, offset: 6997},
 								expr: &ruleRefExpr{
-									pos:  position{line: 79, col: 65, offset: 1843},
+									pos:  position{line: 316, col: 73, offset: 6997},
 									name: "Queries",
 								},
 							},
 						},
-						&zeroOrMoreExpr{
-							pos: position{line: 79, col: 74, offset: 1852},
-							expr: &ruleRefExpr{
-								pos:  position{line: 79, col: 74, offset: 1852},
-								name: "Comment",
+						&labeledExpr{
+							pos:   position{line: 316, col: 82, offset: 7006},
+							label: "Trailing",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 316, col: 91, offset: 7015},
+								expr: &ruleRefExpr{
+									pos:  position{line: 316, col: 91, offset: 7015},
+									name: "Comment",
+								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 79, col: 83, offset: 1861},
+							pos:  position{line: 316, col: 100, offset: 7024},
 							name: "_",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 79, col: 85, offset: 1863},
+							pos:  position{line: 316, col: 102, offset: 7026},
 							name: "EOF",
 						},
 					},
@@ -329,56 +483,56 @@ var g = &grammar{
 		},
 		{
 			name: "Attacker",
-			pos:  position{line: 101, col: 1, offset: 2415},
+			pos:  position{line: 341, col: 1, offset: 7645},
 			expr: &actionExpr{
-				pos: position{line: 101, col: 13, offset: 2427},
+				pos: position{line: 341, col: 13, offset: 7657},
 				run: (*parser).callonAttacker1,
 				expr: &seqExpr{
-					pos: position{line: 101, col: 13, offset: 2427},
+					pos: position{line: 341, col: 13, offset: 7657},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 101, col: 13, offset: 2427},
+							pos:        position{line: 341, col: 13, offset: 7657},
 							val:        "attacker",
 							ignoreCase: false,
 							want:       "\"attacker\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 101, col: 24, offset: 2438},
+							pos:  position{line: 341, col: 24, offset: 7668},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 101, col: 26, offset: 2440},
+							pos:        position{line: 341, col: 26, offset: 7670},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 101, col: 30, offset: 2444},
+							pos:  position{line: 341, col: 30, offset: 7674},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 101, col: 32, offset: 2446},
+							pos:   position{line: 341, col: 32, offset: 7676},
 							label: "Type",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 101, col: 37, offset: 2451},
+								pos: position{line: 341, col: 37, offset: 7681},
 								expr: &ruleRefExpr{
-									pos:  position{line: 101, col: 37, offset: 2451},
+									pos:  position{line: 341, col: 37, offset: 7681},
 									name: "AttackerType",
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 101, col: 51, offset: 2465},
+							pos:  position{line: 341, col: 51, offset: 7695},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 101, col: 53, offset: 2467},
+							pos:        position{line: 341, col: 53, offset: 7697},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 101, col: 57, offset: 2471},
+							pos:  position{line: 341, col: 57, offset: 7701},
 							name: "_",
 						},
 					},
@@ -387,21 +541,21 @@ var g = &grammar{
 		},
 		{
 			name: "AttackerType",
-			pos:  position{line: 108, col: 1, offset: 2595},
+			pos:  position{line: 348, col: 1, offset: 7825},
 			expr: &actionExpr{
-				pos: position{line: 108, col: 17, offset: 2611},
+				pos: position{line: 348, col: 17, offset: 7841},
 				run: (*parser).callonAttackerType1,
 				expr: &choiceExpr{
-					pos: position{line: 108, col: 18, offset: 2612},
+					pos: position{line: 348, col: 18, offset: 7842},
 					alternatives: []any{
 						&litMatcher{
-							pos:        position{line: 108, col: 18, offset: 2612},
+							pos:        position{line: 348, col: 18, offset: 7842},
 							val:        "active",
 							ignoreCase: false,
 							want:       "\"active\"",
 						},
 						&litMatcher{
-							pos:        position{line: 108, col: 27, offset: 2621},
+							pos:        position{line: 348, col: 27, offset: 7851},
 							val:        "passive",
 							ignoreCase: false,
 							want:       "\"passive\"",
@@ -412,151 +566,152 @@ var g = &grammar{
 		},
 		{
 			name: "Block",
-			pos:  position{line: 112, col: 1, offset: 2665},
+			pos:  position{line: 352, col: 1, offset: 7895},
 			expr: &actionExpr{
-				pos: position{line: 112, col: 10, offset: 2674},
+				pos: position{line: 352, col: 10, offset: 7904},
 				run: (*parser).callonBlock1,
 				expr: &seqExpr{
-					pos: position{line: 112, col: 10, offset: 2674},
+					pos: position{line: 352, col: 10, offset: 7904},
 					exprs: []any{
-						&zeroOrMoreExpr{
-							pos: position{line: 112, col: 10, offset: 2674},
-							expr: &ruleRefExpr{
-								pos:  position{line: 112, col: 10, offset: 2674},
-								name: "Comment",
+						&labeledExpr{
+							pos:   position{line: 352, col: 10, offset: 7904},
+							label: "Leading",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 352, col: 18, offset: 7912},
+								expr: &ruleRefExpr{
+									pos:  position{line: 352, col: 18, offset: 7912},
+									name: "Comment",
+								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 112, col: 19, offset: 2683},
-							label: "Block",
+							pos:   position{line: 352, col: 27, offset: 7921},
+							label: "B",
 							expr: &choiceExpr{
-								pos: position{line: 112, col: 26, offset: 2690},
+								pos: position{line: 352, col: 30, offset: 7924},
 								alternatives: []any{
 									&ruleRefExpr{
-										pos:  position{line: 112, col: 26, offset: 2690},
+										pos:  position{line: 352, col: 30, offset: 7924},
 										name: "Phase",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 112, col: 32, offset: 2696},
+										pos:  position{line: 352, col: 36, offset: 7930},
 										name: "Principal",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 112, col: 42, offset: 2706},
+										pos:  position{line: 352, col: 46, offset: 7940},
 										name: "Message",
 									},
 								},
 							},
 						},
+						&labeledExpr{
+							pos:   position{line: 352, col: 55, offset: 7949},
+							label: "Trailing",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 352, col: 64, offset: 7958},
+								expr: &ruleRefExpr{
+									pos:  position{line: 352, col: 64, offset: 7958},
+									name: "InlineComment",
+								},
+							},
+						},
 						&ruleRefExpr{
-							pos:  position{line: 112, col: 51, offset: 2715},
+							pos:  position{line: 352, col: 79, offset: 7973},
 							name: "_",
 						},
-						&zeroOrMoreExpr{
-							pos: position{line: 112, col: 53, offset: 2717},
-							expr: &ruleRefExpr{
-								pos:  position{line: 112, col: 53, offset: 2717},
-								name: "Comment",
-							},
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "Principal",
-			pos:  position{line: 116, col: 1, offset: 2750},
+			pos:  position{line: 361, col: 1, offset: 8110},
 			expr: &actionExpr{
-				pos: position{line: 116, col: 14, offset: 2763},
+				pos: position{line: 361, col: 14, offset: 8123},
 				run: (*parser).callonPrincipal1,
 				expr: &seqExpr{
-					pos: position{line: 116, col: 14, offset: 2763},
+					pos: position{line: 361, col: 14, offset: 8123},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 116, col: 14, offset: 2763},
+							pos:        position{line: 361, col: 14, offset: 8123},
 							val:        "principal",
 							ignoreCase: false,
 							want:       "\"principal\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 116, col: 26, offset: 2775},
+							pos:  position{line: 361, col: 26, offset: 8135},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 116, col: 28, offset: 2777},
+							pos:   position{line: 361, col: 28, offset: 8137},
 							label: "Name",
 							expr: &ruleRefExpr{
-								pos:  position{line: 116, col: 33, offset: 2782},
+								pos:  position{line: 361, col: 33, offset: 8142},
 								name: "PrincipalName",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 116, col: 47, offset: 2796},
+							pos:  position{line: 361, col: 47, offset: 8156},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 116, col: 49, offset: 2798},
+							pos:        position{line: 361, col: 49, offset: 8158},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 116, col: 53, offset: 2802},
+							pos:  position{line: 361, col: 53, offset: 8162},
 							name: "_",
 						},
-						&zeroOrMoreExpr{
-							pos: position{line: 116, col: 55, offset: 2804},
-							expr: &ruleRefExpr{
-								pos:  position{line: 116, col: 55, offset: 2804},
-								name: "Comment",
-							},
-						},
 						&labeledExpr{
-							pos:   position{line: 116, col: 64, offset: 2813},
+							pos:   position{line: 361, col: 55, offset: 8164},
 							label: "Expressions",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 116, col: 77, offset: 2826},
+								pos: position{line: 361, col: 68, offset: 8177},
 								expr: &ruleRefExpr{
-									pos:  position{line: 116, col: 77, offset: 2826},
+									pos:  position{line: 361, col: 68, offset: 8177},
 									name: "Expression",
 								},
 							},
 						},
-						&zeroOrMoreExpr{
-							pos: position{line: 116, col: 90, offset: 2839},
-							expr: &ruleRefExpr{
-								pos:  position{line: 116, col: 90, offset: 2839},
-								name: "Comment",
+						&labeledExpr{
+							pos:   position{line: 361, col: 81, offset: 8190},
+							label: "Closing",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 361, col: 89, offset: 8198},
+								expr: &ruleRefExpr{
+									pos:  position{line: 361, col: 89, offset: 8198},
+									name: "Comment",
+								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 116, col: 99, offset: 2848},
+							pos:  position{line: 361, col: 98, offset: 8207},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 116, col: 101, offset: 2850},
+							pos:        position{line: 361, col: 100, offset: 8209},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
 						},
-						&ruleRefExpr{
-							pos:  position{line: 116, col: 105, offset: 2854},
-							name: "_",
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "PrincipalName",
-			pos:  position{line: 131, col: 1, offset: 3149},
+			pos:  position{line: 379, col: 1, offset: 8600},
 			expr: &actionExpr{
-				pos: position{line: 131, col: 18, offset: 3166},
+				pos: position{line: 379, col: 18, offset: 8617},
 				run: (*parser).callonPrincipalName1,
 				expr: &labeledExpr{
-					pos:   position{line: 131, col: 18, offset: 3166},
+					pos:   position{line: 379, col: 18, offset: 8617},
 					label: "Name",
 					expr: &ruleRefExpr{
-						pos:  position{line: 131, col: 23, offset: 3171},
+						pos:  position{line: 379, col: 23, offset: 8622},
 						name: "Identifier",
 					},
 				},
@@ -564,27 +719,27 @@ var g = &grammar{
 		},
 		{
 			name: "Qualifier",
-			pos:  position{line: 136, col: 1, offset: 3274},
+			pos:  position{line: 384, col: 1, offset: 8725},
 			expr: &actionExpr{
-				pos: position{line: 136, col: 14, offset: 3287},
+				pos: position{line: 384, col: 14, offset: 8738},
 				run: (*parser).callonQualifier1,
 				expr: &choiceExpr{
-					pos: position{line: 136, col: 15, offset: 3288},
+					pos: position{line: 384, col: 15, offset: 8739},
 					alternatives: []any{
 						&litMatcher{
-							pos:        position{line: 136, col: 15, offset: 3288},
+							pos:        position{line: 384, col: 15, offset: 8739},
 							val:        "private",
 							ignoreCase: false,
 							want:       "\"private\"",
 						},
 						&litMatcher{
-							pos:        position{line: 136, col: 25, offset: 3298},
+							pos:        position{line: 384, col: 25, offset: 8749},
 							val:        "public",
 							ignoreCase: false,
 							want:       "\"public\"",
 						},
 						&litMatcher{
-							pos:        position{line: 136, col: 34, offset: 3307},
+							pos:        position{line: 384, col: 34, offset: 8758},
 							val:        "password",
 							ignoreCase: false,
 							want:       "\"password\"",
@@ -595,39 +750,39 @@ var g = &grammar{
 		},
 		{
 			name: "Message",
-			pos:  position{line: 147, col: 1, offset: 3495},
+			pos:  position{line: 395, col: 1, offset: 8946},
 			expr: &actionExpr{
-				pos: position{line: 147, col: 12, offset: 3506},
+				pos: position{line: 395, col: 12, offset: 8957},
 				run: (*parser).callonMessage1,
 				expr: &seqExpr{
-					pos: position{line: 147, col: 12, offset: 3506},
+					pos: position{line: 395, col: 12, offset: 8957},
 					exprs: []any{
 						&labeledExpr{
-							pos:   position{line: 147, col: 12, offset: 3506},
+							pos:   position{line: 395, col: 12, offset: 8957},
 							label: "Sender",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 147, col: 19, offset: 3513},
+								pos: position{line: 395, col: 19, offset: 8964},
 								expr: &ruleRefExpr{
-									pos:  position{line: 147, col: 19, offset: 3513},
+									pos:  position{line: 395, col: 19, offset: 8964},
 									name: "PrincipalName",
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 147, col: 34, offset: 3528},
+							pos:  position{line: 395, col: 34, offset: 8979},
 							name: "_",
 						},
 						&choiceExpr{
-							pos: position{line: 147, col: 37, offset: 3531},
+							pos: position{line: 395, col: 37, offset: 8982},
 							alternatives: []any{
 								&litMatcher{
-									pos:        position{line: 147, col: 37, offset: 3531},
+									pos:        position{line: 395, col: 37, offset: 8982},
 									val:        "->",
 									ignoreCase: false,
 									want:       "\"->\"",
 								},
 								&litMatcher{
-									pos:        position{line: 147, col: 42, offset: 3536},
+									pos:        position{line: 395, col: 42, offset: 8987},
 									val:        "→",
 									ignoreCase: false,
 									want:       "\"→\"",
@@ -635,41 +790,41 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 147, col: 47, offset: 3543},
+							pos:  position{line: 395, col: 47, offset: 8994},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 147, col: 49, offset: 3545},
+							pos:   position{line: 395, col: 49, offset: 8996},
 							label: "Recipient",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 147, col: 59, offset: 3555},
+								pos: position{line: 395, col: 59, offset: 9006},
 								expr: &ruleRefExpr{
-									pos:  position{line: 147, col: 59, offset: 3555},
+									pos:  position{line: 395, col: 59, offset: 9006},
 									name: "PrincipalName",
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 147, col: 74, offset: 3570},
+							pos:  position{line: 395, col: 74, offset: 9021},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 147, col: 76, offset: 3572},
+							pos:        position{line: 395, col: 76, offset: 9023},
 							val:        ":",
 							ignoreCase: false,
 							want:       "\":\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 147, col: 80, offset: 3576},
+							pos:  position{line: 395, col: 80, offset: 9027},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 147, col: 82, offset: 3578},
+							pos:   position{line: 395, col: 82, offset: 9029},
 							label: "Constants",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 147, col: 92, offset: 3588},
+								pos: position{line: 395, col: 92, offset: 9039},
 								expr: &ruleRefExpr{
-									pos:  position{line: 147, col: 92, offset: 3588},
+									pos:  position{line: 395, col: 92, offset: 9039},
 									name: "MessageConstants",
 								},
 							},
@@ -680,24 +835,24 @@ var g = &grammar{
 		},
 		{
 			name: "MessageConstants",
-			pos:  position{line: 168, col: 1, offset: 4142},
+			pos:  position{line: 418, col: 1, offset: 9651},
 			expr: &actionExpr{
-				pos: position{line: 168, col: 21, offset: 4162},
+				pos: position{line: 418, col: 21, offset: 9671},
 				run: (*parser).callonMessageConstants1,
 				expr: &labeledExpr{
-					pos:   position{line: 168, col: 21, offset: 4162},
+					pos:   position{line: 418, col: 21, offset: 9671},
 					label: "MessageConstants",
 					expr: &oneOrMoreExpr{
-						pos: position{line: 168, col: 38, offset: 4179},
+						pos: position{line: 418, col: 38, offset: 9688},
 						expr: &choiceExpr{
-							pos: position{line: 168, col: 39, offset: 4180},
+							pos: position{line: 418, col: 39, offset: 9689},
 							alternatives: []any{
 								&ruleRefExpr{
-									pos:  position{line: 168, col: 39, offset: 4180},
+									pos:  position{line: 418, col: 39, offset: 9689},
 									name: "GuardedConstant",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 168, col: 55, offset: 4196},
+									pos:  position{line: 418, col: 55, offset: 9705},
 									name: "Constant",
 								},
 							},
@@ -708,101 +863,109 @@ var g = &grammar{
 		},
 		{
 			name: "Expression",
-			pos:  position{line: 178, col: 1, offset: 4370},
+			pos:  position{line: 428, col: 1, offset: 9879},
 			expr: &actionExpr{
-				pos: position{line: 178, col: 15, offset: 4384},
+				pos: position{line: 428, col: 15, offset: 9893},
 				run: (*parser).callonExpression1,
 				expr: &seqExpr{
-					pos: position{line: 178, col: 15, offset: 4384},
+					pos: position{line: 428, col: 15, offset: 9893},
 					exprs: []any{
-						&zeroOrMoreExpr{
-							pos: position{line: 178, col: 15, offset: 4384},
-							expr: &ruleRefExpr{
-								pos:  position{line: 178, col: 15, offset: 4384},
-								name: "Comment",
+						&labeledExpr{
+							pos:   position{line: 428, col: 15, offset: 9893},
+							label: "Leading",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 428, col: 23, offset: 9901},
+								expr: &ruleRefExpr{
+									pos:  position{line: 428, col: 23, offset: 9901},
+									name: "Comment",
+								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 178, col: 24, offset: 4393},
-							label: "Expression",
+							pos:   position{line: 428, col: 32, offset: 9910},
+							label: "E",
 							expr: &choiceExpr{
-								pos: position{line: 178, col: 36, offset: 4405},
+								pos: position{line: 428, col: 35, offset: 9913},
 								alternatives: []any{
 									&ruleRefExpr{
-										pos:  position{line: 178, col: 36, offset: 4405},
+										pos:  position{line: 428, col: 35, offset: 9913},
 										name: "Knows",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 178, col: 42, offset: 4411},
+										pos:  position{line: 428, col: 41, offset: 9919},
 										name: "Generates",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 178, col: 52, offset: 4421},
+										pos:  position{line: 428, col: 51, offset: 9929},
 										name: "Leaks",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 178, col: 58, offset: 4427},
+										pos:  position{line: 428, col: 57, offset: 9935},
 										name: "Assignment",
 									},
 								},
 							},
 						},
+						&labeledExpr{
+							pos:   position{line: 428, col: 69, offset: 9947},
+							label: "Trailing",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 428, col: 78, offset: 9956},
+								expr: &ruleRefExpr{
+									pos:  position{line: 428, col: 78, offset: 9956},
+									name: "InlineComment",
+								},
+							},
+						},
 						&ruleRefExpr{
-							pos:  position{line: 178, col: 70, offset: 4439},
+							pos:  position{line: 428, col: 93, offset: 9971},
 							name: "_",
 						},
-						&zeroOrMoreExpr{
-							pos: position{line: 178, col: 72, offset: 4441},
-							expr: &ruleRefExpr{
-								pos:  position{line: 178, col: 72, offset: 4441},
-								name: "Comment",
-							},
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "Knows",
-			pos:  position{line: 182, col: 1, offset: 4479},
+			pos:  position{line: 437, col: 1, offset: 10113},
 			expr: &actionExpr{
-				pos: position{line: 182, col: 10, offset: 4488},
+				pos: position{line: 437, col: 10, offset: 10122},
 				run: (*parser).callonKnows1,
 				expr: &seqExpr{
-					pos: position{line: 182, col: 10, offset: 4488},
+					pos: position{line: 437, col: 10, offset: 10122},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 182, col: 10, offset: 4488},
+							pos:        position{line: 437, col: 10, offset: 10122},
 							val:        "knows",
 							ignoreCase: false,
 							want:       "\"knows\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 182, col: 18, offset: 4496},
+							pos:  position{line: 437, col: 18, offset: 10130},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 182, col: 20, offset: 4498},
+							pos:   position{line: 437, col: 20, offset: 10132},
 							label: "Qualifier",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 182, col: 30, offset: 4508},
+								pos: position{line: 437, col: 30, offset: 10142},
 								expr: &ruleRefExpr{
-									pos:  position{line: 182, col: 30, offset: 4508},
+									pos:  position{line: 437, col: 30, offset: 10142},
 									name: "Qualifier",
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 182, col: 41, offset: 4519},
+							pos:  position{line: 437, col: 41, offset: 10153},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 182, col: 43, offset: 4521},
+							pos:   position{line: 437, col: 43, offset: 10155},
 							label: "Constants",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 182, col: 53, offset: 4531},
+								pos: position{line: 437, col: 53, offset: 10165},
 								expr: &ruleRefExpr{
-									pos:  position{line: 182, col: 53, offset: 4531},
+									pos:  position{line: 437, col: 53, offset: 10165},
 									name: "Constants",
 								},
 							},
@@ -813,30 +976,30 @@ var g = &grammar{
 		},
 		{
 			name: "Generates",
-			pos:  position{line: 196, col: 1, offset: 4883},
+			pos:  position{line: 452, col: 1, offset: 10548},
 			expr: &actionExpr{
-				pos: position{line: 196, col: 14, offset: 4896},
+				pos: position{line: 452, col: 14, offset: 10561},
 				run: (*parser).callonGenerates1,
 				expr: &seqExpr{
-					pos: position{line: 196, col: 14, offset: 4896},
+					pos: position{line: 452, col: 14, offset: 10561},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 196, col: 14, offset: 4896},
+							pos:        position{line: 452, col: 14, offset: 10561},
 							val:        "generates",
 							ignoreCase: false,
 							want:       "\"generates\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 196, col: 26, offset: 4908},
+							pos:  position{line: 452, col: 26, offset: 10573},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 196, col: 28, offset: 4910},
+							pos:   position{line: 452, col: 28, offset: 10575},
 							label: "Constants",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 196, col: 38, offset: 4920},
+								pos: position{line: 452, col: 38, offset: 10585},
 								expr: &ruleRefExpr{
-									pos:  position{line: 196, col: 38, offset: 4920},
+									pos:  position{line: 452, col: 38, offset: 10585},
 									name: "Constants",
 								},
 							},
@@ -847,30 +1010,30 @@ var g = &grammar{
 		},
 		{
 			name: "Leaks",
-			pos:  position{line: 207, col: 1, offset: 5165},
+			pos:  position{line: 464, col: 1, offset: 10861},
 			expr: &actionExpr{
-				pos: position{line: 207, col: 10, offset: 5174},
+				pos: position{line: 464, col: 10, offset: 10870},
 				run: (*parser).callonLeaks1,
 				expr: &seqExpr{
-					pos: position{line: 207, col: 10, offset: 5174},
+					pos: position{line: 464, col: 10, offset: 10870},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 207, col: 10, offset: 5174},
+							pos:        position{line: 464, col: 10, offset: 10870},
 							val:        "leaks",
 							ignoreCase: false,
 							want:       "\"leaks\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 207, col: 18, offset: 5182},
+							pos:  position{line: 464, col: 18, offset: 10878},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 207, col: 20, offset: 5184},
+							pos:   position{line: 464, col: 20, offset: 10880},
 							label: "Constants",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 207, col: 30, offset: 5194},
+								pos: position{line: 464, col: 30, offset: 10890},
 								expr: &ruleRefExpr{
-									pos:  position{line: 207, col: 30, offset: 5194},
+									pos:  position{line: 464, col: 30, offset: 10890},
 									name: "Constants",
 								},
 							},
@@ -881,45 +1044,45 @@ var g = &grammar{
 		},
 		{
 			name: "Assignment",
-			pos:  position{line: 218, col: 1, offset: 5431},
+			pos:  position{line: 476, col: 1, offset: 11158},
 			expr: &actionExpr{
-				pos: position{line: 218, col: 15, offset: 5445},
+				pos: position{line: 476, col: 15, offset: 11172},
 				run: (*parser).callonAssignment1,
 				expr: &seqExpr{
-					pos: position{line: 218, col: 15, offset: 5445},
+					pos: position{line: 476, col: 15, offset: 11172},
 					exprs: []any{
 						&labeledExpr{
-							pos:   position{line: 218, col: 15, offset: 5445},
+							pos:   position{line: 476, col: 15, offset: 11172},
 							label: "Left",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 218, col: 20, offset: 5450},
+								pos: position{line: 476, col: 20, offset: 11177},
 								expr: &ruleRefExpr{
-									pos:  position{line: 218, col: 20, offset: 5450},
+									pos:  position{line: 476, col: 20, offset: 11177},
 									name: "Constants",
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 218, col: 31, offset: 5461},
+							pos:  position{line: 476, col: 31, offset: 11188},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 218, col: 33, offset: 5463},
+							pos:        position{line: 476, col: 33, offset: 11190},
 							val:        "=",
 							ignoreCase: false,
 							want:       "\"=\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 218, col: 37, offset: 5467},
+							pos:  position{line: 476, col: 37, offset: 11194},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 218, col: 39, offset: 5469},
+							pos:   position{line: 476, col: 39, offset: 11196},
 							label: "Right",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 218, col: 45, offset: 5475},
+								pos: position{line: 476, col: 45, offset: 11202},
 								expr: &ruleRefExpr{
-									pos:  position{line: 218, col: 45, offset: 5475},
+									pos:  position{line: 476, col: 45, offset: 11202},
 									name: "Value",
 								},
 							},
@@ -930,38 +1093,38 @@ var g = &grammar{
 		},
 		{
 			name: "Constant",
-			pos:  position{line: 234, col: 1, offset: 5824},
+			pos:  position{line: 493, col: 1, offset: 11582},
 			expr: &actionExpr{
-				pos: position{line: 234, col: 13, offset: 5836},
+				pos: position{line: 493, col: 13, offset: 11594},
 				run: (*parser).callonConstant1,
 				expr: &seqExpr{
-					pos: position{line: 234, col: 13, offset: 5836},
+					pos: position{line: 493, col: 13, offset: 11594},
 					exprs: []any{
 						&labeledExpr{
-							pos:   position{line: 234, col: 13, offset: 5836},
+							pos:   position{line: 493, col: 13, offset: 11594},
 							label: "Const",
 							expr: &ruleRefExpr{
-								pos:  position{line: 234, col: 19, offset: 5842},
+								pos:  position{line: 493, col: 19, offset: 11600},
 								name: "Identifier",
 							},
 						},
 						&zeroOrOneExpr{
-							pos: position{line: 234, col: 30, offset: 5853},
+							pos: position{line: 493, col: 30, offset: 11611},
 							expr: &seqExpr{
-								pos: position{line: 234, col: 31, offset: 5854},
+								pos: position{line: 493, col: 31, offset: 11612},
 								exprs: []any{
 									&ruleRefExpr{
-										pos:  position{line: 234, col: 31, offset: 5854},
+										pos:  position{line: 493, col: 31, offset: 11612},
 										name: "_",
 									},
 									&litMatcher{
-										pos:        position{line: 234, col: 33, offset: 5856},
+										pos:        position{line: 493, col: 33, offset: 11614},
 										val:        ",",
 										ignoreCase: false,
 										want:       "\",\"",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 234, col: 37, offset: 5860},
+										pos:  position{line: 493, col: 37, offset: 11618},
 										name: "_",
 									},
 								},
@@ -973,17 +1136,17 @@ var g = &grammar{
 		},
 		{
 			name: "Constants",
-			pos:  position{line: 256, col: 1, offset: 6254},
+			pos:  position{line: 517, col: 1, offset: 12075},
 			expr: &actionExpr{
-				pos: position{line: 256, col: 14, offset: 6267},
+				pos: position{line: 517, col: 14, offset: 12088},
 				run: (*parser).callonConstants1,
 				expr: &labeledExpr{
-					pos:   position{line: 256, col: 14, offset: 6267},
+					pos:   position{line: 517, col: 14, offset: 12088},
 					label: "Constants",
 					expr: &oneOrMoreExpr{
-						pos: position{line: 256, col: 24, offset: 6277},
+						pos: position{line: 517, col: 24, offset: 12098},
 						expr: &ruleRefExpr{
-							pos:  position{line: 256, col: 24, offset: 6277},
+							pos:  position{line: 517, col: 24, offset: 12098},
 							name: "Constant",
 						},
 					},
@@ -992,40 +1155,40 @@ var g = &grammar{
 		},
 		{
 			name: "Phase",
-			pos:  position{line: 265, col: 1, offset: 6434},
+			pos:  position{line: 526, col: 1, offset: 12255},
 			expr: &actionExpr{
-				pos: position{line: 265, col: 10, offset: 6443},
+				pos: position{line: 526, col: 10, offset: 12264},
 				run: (*parser).callonPhase1,
 				expr: &seqExpr{
-					pos: position{line: 265, col: 10, offset: 6443},
+					pos: position{line: 526, col: 10, offset: 12264},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 265, col: 10, offset: 6443},
+							pos:        position{line: 526, col: 10, offset: 12264},
 							val:        "phase",
 							ignoreCase: false,
 							want:       "\"phase\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 265, col: 18, offset: 6451},
+							pos:  position{line: 526, col: 18, offset: 12272},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 265, col: 20, offset: 6453},
+							pos:        position{line: 526, col: 20, offset: 12274},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 265, col: 24, offset: 6457},
+							pos:  position{line: 526, col: 24, offset: 12278},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 265, col: 26, offset: 6459},
+							pos:   position{line: 526, col: 26, offset: 12280},
 							label: "Number",
 							expr: &oneOrMoreExpr{
-								pos: position{line: 265, col: 33, offset: 6466},
+								pos: position{line: 526, col: 33, offset: 12287},
 								expr: &charClassMatcher{
-									pos:        position{line: 265, col: 33, offset: 6466},
+									pos:        position{line: 526, col: 33, offset: 12287},
 									val:        "[0-9]",
 									ranges:     []rune{'0', '9'},
 									ignoreCase: false,
@@ -1034,69 +1197,65 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 265, col: 40, offset: 6473},
+							pos:  position{line: 526, col: 40, offset: 12294},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 265, col: 42, offset: 6475},
+							pos:        position{line: 526, col: 42, offset: 12296},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
 						},
-						&ruleRefExpr{
-							pos:  position{line: 265, col: 46, offset: 6479},
-							name: "_",
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "GuardedConstant",
-			pos:  position{line: 278, col: 1, offset: 6701},
+			pos:  position{line: 540, col: 1, offset: 12551},
 			expr: &actionExpr{
-				pos: position{line: 278, col: 20, offset: 6720},
+				pos: position{line: 540, col: 20, offset: 12570},
 				run: (*parser).callonGuardedConstant1,
 				expr: &seqExpr{
-					pos: position{line: 278, col: 20, offset: 6720},
+					pos: position{line: 540, col: 20, offset: 12570},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 278, col: 20, offset: 6720},
+							pos:        position{line: 540, col: 20, offset: 12570},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 278, col: 24, offset: 6724},
+							pos:   position{line: 540, col: 24, offset: 12574},
 							label: "Guarded",
 							expr: &ruleRefExpr{
-								pos:  position{line: 278, col: 32, offset: 6732},
+								pos:  position{line: 540, col: 32, offset: 12582},
 								name: "Constant",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 278, col: 41, offset: 6741},
+							pos:        position{line: 540, col: 41, offset: 12591},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
 						},
 						&zeroOrOneExpr{
-							pos: position{line: 278, col: 45, offset: 6745},
+							pos: position{line: 540, col: 45, offset: 12595},
 							expr: &seqExpr{
-								pos: position{line: 278, col: 46, offset: 6746},
+								pos: position{line: 540, col: 46, offset: 12596},
 								exprs: []any{
 									&ruleRefExpr{
-										pos:  position{line: 278, col: 46, offset: 6746},
+										pos:  position{line: 540, col: 46, offset: 12596},
 										name: "_",
 									},
 									&litMatcher{
-										pos:        position{line: 278, col: 48, offset: 6748},
+										pos:        position{line: 540, col: 48, offset: 12598},
 										val:        ",",
 										ignoreCase: false,
 										want:       "\",\"",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 278, col: 52, offset: 6752},
+										pos:  position{line: 540, col: 52, offset: 12602},
 										name: "_",
 									},
 								},
@@ -1108,59 +1267,59 @@ var g = &grammar{
 		},
 		{
 			name: "Primitive",
-			pos:  position{line: 291, col: 1, offset: 6994},
+			pos:  position{line: 554, col: 1, offset: 12886},
 			expr: &actionExpr{
-				pos: position{line: 291, col: 14, offset: 7007},
+				pos: position{line: 554, col: 14, offset: 12899},
 				run: (*parser).callonPrimitive1,
 				expr: &seqExpr{
-					pos: position{line: 291, col: 14, offset: 7007},
+					pos: position{line: 554, col: 14, offset: 12899},
 					exprs: []any{
 						&labeledExpr{
-							pos:   position{line: 291, col: 14, offset: 7007},
+							pos:   position{line: 554, col: 14, offset: 12899},
 							label: "Name",
 							expr: &ruleRefExpr{
-								pos:  position{line: 291, col: 19, offset: 7012},
+								pos:  position{line: 554, col: 19, offset: 12904},
 								name: "PrimitiveName",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 291, col: 33, offset: 7026},
+							pos:        position{line: 554, col: 33, offset: 12918},
 							val:        "(",
 							ignoreCase: false,
 							want:       "\"(\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 291, col: 37, offset: 7030},
+							pos:  position{line: 554, col: 37, offset: 12922},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 291, col: 39, offset: 7032},
+							pos:   position{line: 554, col: 39, offset: 12924},
 							label: "Arguments",
 							expr: &oneOrMoreExpr{
-								pos: position{line: 291, col: 49, offset: 7042},
+								pos: position{line: 554, col: 49, offset: 12934},
 								expr: &ruleRefExpr{
-									pos:  position{line: 291, col: 49, offset: 7042},
+									pos:  position{line: 554, col: 49, offset: 12934},
 									name: "Value",
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 291, col: 56, offset: 7049},
+							pos:  position{line: 554, col: 56, offset: 12941},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 291, col: 58, offset: 7051},
+							pos:        position{line: 554, col: 58, offset: 12943},
 							val:        ")",
 							ignoreCase: false,
 							want:       "\")\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 291, col: 62, offset: 7055},
+							pos:   position{line: 554, col: 62, offset: 12947},
 							label: "Check",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 291, col: 68, offset: 7061},
+								pos: position{line: 554, col: 68, offset: 12953},
 								expr: &litMatcher{
-									pos:        position{line: 291, col: 68, offset: 7061},
+									pos:        position{line: 554, col: 68, offset: 12953},
 									val:        "?",
 									ignoreCase: false,
 									want:       "\"?\"",
@@ -1168,22 +1327,22 @@ var g = &grammar{
 							},
 						},
 						&zeroOrOneExpr{
-							pos: position{line: 291, col: 73, offset: 7066},
+							pos: position{line: 554, col: 73, offset: 12958},
 							expr: &seqExpr{
-								pos: position{line: 291, col: 74, offset: 7067},
+								pos: position{line: 554, col: 74, offset: 12959},
 								exprs: []any{
 									&ruleRefExpr{
-										pos:  position{line: 291, col: 74, offset: 7067},
+										pos:  position{line: 554, col: 74, offset: 12959},
 										name: "_",
 									},
 									&litMatcher{
-										pos:        position{line: 291, col: 76, offset: 7069},
+										pos:        position{line: 554, col: 76, offset: 12961},
 										val:        ",",
 										ignoreCase: false,
 										want:       "\",\"",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 291, col: 80, offset: 7073},
+										pos:  position{line: 554, col: 80, offset: 12965},
 										name: "_",
 									},
 								},
@@ -1195,15 +1354,15 @@ var g = &grammar{
 		},
 		{
 			name: "PrimitiveName",
-			pos:  position{line: 308, col: 1, offset: 7388},
+			pos:  position{line: 572, col: 1, offset: 13312},
 			expr: &actionExpr{
-				pos: position{line: 308, col: 18, offset: 7405},
+				pos: position{line: 572, col: 18, offset: 13329},
 				run: (*parser).callonPrimitiveName1,
 				expr: &labeledExpr{
-					pos:   position{line: 308, col: 18, offset: 7405},
+					pos:   position{line: 572, col: 18, offset: 13329},
 					label: "Name",
 					expr: &ruleRefExpr{
-						pos:  position{line: 308, col: 23, offset: 7410},
+						pos:  position{line: 572, col: 23, offset: 13334},
 						name: "Identifier",
 					},
 				},
@@ -1211,45 +1370,45 @@ var g = &grammar{
 		},
 		{
 			name: "Equation",
-			pos:  position{line: 312, col: 1, offset: 7470},
+			pos:  position{line: 576, col: 1, offset: 13394},
 			expr: &actionExpr{
-				pos: position{line: 312, col: 13, offset: 7482},
+				pos: position{line: 576, col: 13, offset: 13406},
 				run: (*parser).callonEquation1,
 				expr: &seqExpr{
-					pos: position{line: 312, col: 13, offset: 7482},
+					pos: position{line: 576, col: 13, offset: 13406},
 					exprs: []any{
 						&labeledExpr{
-							pos:   position{line: 312, col: 13, offset: 7482},
+							pos:   position{line: 576, col: 13, offset: 13406},
 							label: "First",
 							expr: &ruleRefExpr{
-								pos:  position{line: 312, col: 19, offset: 7488},
+								pos:  position{line: 576, col: 19, offset: 13412},
 								name: "Constant",
 							},
 						},
 						&seqExpr{
-							pos: position{line: 312, col: 29, offset: 7498},
+							pos: position{line: 576, col: 29, offset: 13422},
 							exprs: []any{
 								&ruleRefExpr{
-									pos:  position{line: 312, col: 29, offset: 7498},
+									pos:  position{line: 576, col: 29, offset: 13422},
 									name: "_",
 								},
 								&litMatcher{
-									pos:        position{line: 312, col: 31, offset: 7500},
+									pos:        position{line: 576, col: 31, offset: 13424},
 									val:        "^",
 									ignoreCase: false,
 									want:       "\"^\"",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 312, col: 35, offset: 7504},
+									pos:  position{line: 576, col: 35, offset: 13428},
 									name: "_",
 								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 312, col: 38, offset: 7507},
+							pos:   position{line: 576, col: 38, offset: 13431},
 							label: "Second",
 							expr: &ruleRefExpr{
-								pos:  position{line: 312, col: 45, offset: 7514},
+								pos:  position{line: 576, col: 45, offset: 13438},
 								name: "Constant",
 							},
 						},
@@ -1259,20 +1418,20 @@ var g = &grammar{
 		},
 		{
 			name: "Value",
-			pos:  position{line: 324, col: 1, offset: 7671},
+			pos:  position{line: 588, col: 1, offset: 13595},
 			expr: &choiceExpr{
-				pos: position{line: 324, col: 10, offset: 7680},
+				pos: position{line: 588, col: 10, offset: 13604},
 				alternatives: []any{
 					&ruleRefExpr{
-						pos:  position{line: 324, col: 10, offset: 7680},
+						pos:  position{line: 588, col: 10, offset: 13604},
 						name: "Primitive",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 324, col: 20, offset: 7690},
+						pos:  position{line: 588, col: 20, offset: 13614},
 						name: "Equation",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 324, col: 29, offset: 7699},
+						pos:  position{line: 588, col: 29, offset: 13623},
 						name: "Constant",
 					},
 				},
@@ -1280,52 +1439,78 @@ var g = &grammar{
 		},
 		{
 			name: "Queries",
-			pos:  position{line: 326, col: 1, offset: 7709},
+			pos:  position{line: 590, col: 1, offset: 13633},
 			expr: &actionExpr{
-				pos: position{line: 326, col: 12, offset: 7720},
+				pos: position{line: 590, col: 12, offset: 13644},
 				run: (*parser).callonQueries1,
 				expr: &seqExpr{
-					pos: position{line: 326, col: 12, offset: 7720},
+					pos: position{line: 590, col: 12, offset: 13644},
 					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 590, col: 12, offset: 13644},
+							label: "Leading",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 590, col: 20, offset: 13652},
+								expr: &ruleRefExpr{
+									pos:  position{line: 590, col: 20, offset: 13652},
+									name: "Comment",
+								},
+							},
+						},
 						&litMatcher{
-							pos:        position{line: 326, col: 12, offset: 7720},
+							pos:        position{line: 590, col: 29, offset: 13661},
 							val:        "queries",
 							ignoreCase: false,
 							want:       "\"queries\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 326, col: 22, offset: 7730},
+							pos:  position{line: 590, col: 39, offset: 13671},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 326, col: 24, offset: 7732},
+							pos:        position{line: 590, col: 41, offset: 13673},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 326, col: 28, offset: 7736},
+							pos:  position{line: 590, col: 45, offset: 13677},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 326, col: 30, offset: 7738},
+							pos:   position{line: 590, col: 47, offset: 13679},
 							label: "Queries",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 326, col: 39, offset: 7747},
+								pos: position{line: 590, col: 56, offset: 13688},
 								expr: &ruleRefExpr{
-									pos:  position{line: 326, col: 39, offset: 7747},
+									pos:  position{line: 590, col: 56, offset: 13688},
 									name: "Query",
 								},
 							},
 						},
+						&labeledExpr{
+							pos:   position{line: 590, col: 64, offset: 13696},
+							label: "Closing",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 590, col: 72, offset: 13704},
+								expr: &ruleRefExpr{
+									pos:  position{line: 590, col: 72, offset: 13704},
+									name: "Comment",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 590, col: 81, offset: 13713},
+							name: "_",
+						},
 						&litMatcher{
-							pos:        position{line: 326, col: 47, offset: 7755},
+							pos:        position{line: 590, col: 83, offset: 13715},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 326, col: 51, offset: 7759},
+							pos:  position{line: 590, col: 87, offset: 13719},
 							name: "_",
 						},
 					},
@@ -1334,54 +1519,150 @@ var g = &grammar{
 		},
 		{
 			name: "Query",
-			pos:  position{line: 330, col: 1, offset: 7787},
+			pos:  position{line: 603, col: 1, offset: 13974},
 			expr: &actionExpr{
-				pos: position{line: 330, col: 10, offset: 7796},
+				pos: position{line: 603, col: 10, offset: 13983},
 				run: (*parser).callonQuery1,
 				expr: &seqExpr{
-					pos: position{line: 330, col: 10, offset: 7796},
+					pos: position{line: 603, col: 10, offset: 13983},
 					exprs: []any{
-						&zeroOrMoreExpr{
-							pos: position{line: 330, col: 10, offset: 7796},
-							expr: &ruleRefExpr{
-								pos:  position{line: 330, col: 10, offset: 7796},
-								name: "Comment",
+						&labeledExpr{
+							pos:   position{line: 603, col: 10, offset: 13983},
+							label: "Leading",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 603, col: 18, offset: 13991},
+								expr: &ruleRefExpr{
+									pos:  position{line: 603, col: 18, offset: 13991},
+									name: "Comment",
+								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 330, col: 19, offset: 7805},
-							label: "Query",
+							pos:   position{line: 603, col: 27, offset: 14000},
+							label: "Q",
 							expr: &choiceExpr{
-								pos: position{line: 330, col: 26, offset: 7812},
+								pos: position{line: 603, col: 30, offset: 14003},
 								alternatives: []any{
 									&ruleRefExpr{
-										pos:  position{line: 330, col: 26, offset: 7812},
+										pos:  position{line: 603, col: 30, offset: 14003},
 										name: "QueryConfidentiality",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 330, col: 47, offset: 7833},
+										pos:  position{line: 603, col: 51, offset: 14024},
 										name: "QueryAuthentication",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 330, col: 67, offset: 7853},
+										pos:  position{line: 603, col: 71, offset: 14044},
 										name: "QueryFreshness",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 330, col: 82, offset: 7868},
+										pos:  position{line: 603, col: 86, offset: 14059},
 										name: "QueryUnlinkability",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 330, col: 101, offset: 7887},
+										pos:  position{line: 603, col: 105, offset: 14078},
 										name: "QueryEquivalence",
 									},
 								},
 							},
 						},
+						&labeledExpr{
+							pos:   position{line: 603, col: 123, offset: 14096},
+							label: "Expect",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 603, col: 130, offset: 14103},
+								expr: &ruleRefExpr{
+									pos:  position{line: 603, col: 130, offset: 14103},
+									name: "QueryExpect",
+								},
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 603, col: 143, offset: 14116},
+							label: "Trailing",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 603, col: 152, offset: 14125},
+								expr: &ruleRefExpr{
+									pos:  position{line: 603, col: 152, offset: 14125},
+									name: "InlineComment",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 603, col: 167, offset: 14140},
+							name: "_",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "QueryExpect",
+			pos:  position{line: 615, col: 1, offset: 14332},
+			expr: &actionExpr{
+				pos: position{line: 615, col: 16, offset: 14347},
+				run: (*parser).callonQueryExpect1,
+				expr: &seqExpr{
+					pos: position{line: 615, col: 16, offset: 14347},
+					exprs: []any{
+						&zeroOrMoreExpr{
+							pos: position{line: 615, col: 16, offset: 14347},
+							expr: &charClassMatcher{
+								pos:        position{line: 615, col: 16, offset: 14347},
+								val:        "[ \\t]",
+								chars:      []rune{' ', '\t'},
+								ignoreCase: false,
+								inverted:   false,
+							},
+						},
+						&litMatcher{
+							pos:        position{line: 615, col: 23, offset: 14354},
+							val:        "//",
+							ignoreCase: false,
+							want:       "\"//\"",
+						},
+						&zeroOrMoreExpr{
+							pos: position{line: 615, col: 28, offset: 14359},
+							expr: &charClassMatcher{
+								pos:        position{line: 615, col: 28, offset: 14359},
+								val:        "[ \\t]",
+								chars:      []rune{' ', '\t'},
+								ignoreCase: false,
+								inverted:   false,
+							},
+						},
+						&litMatcher{
+							pos:        position{line: 615, col: 35, offset: 14366},
+							val:        "expect:",
+							ignoreCase: false,
+							want:       "\"expect:\"",
+						},
 						&zeroOrMoreExpr{
-							pos: position{line: 330, col: 119, offset: 7905},
+							pos: position{line: 615, col: 45, offset: 14376},
+							expr: &charClassMatcher{
+								pos:        position{line: 615, col: 45, offset: 14376},
+								val:        "[ \\t]",
+								chars:      []rune{' ', '\t'},
+								ignoreCase: false,
+								inverted:   false,
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 615, col: 52, offset: 14383},
+							label: "Expect",
 							expr: &ruleRefExpr{
-								pos:  position{line: 330, col: 119, offset: 7905},
-								name: "Comment",
+								pos:  position{line: 615, col: 59, offset: 14390},
+								name: "Identifier",
+							},
+						},
+						&zeroOrMoreExpr{
+							pos: position{line: 615, col: 70, offset: 14401},
+							expr: &charClassMatcher{
+								pos:        position{line: 615, col: 70, offset: 14401},
+								val:        "[^\\n]",
+								chars:      []rune{'\n'},
+								ignoreCase: false,
+								inverted:   true,
 							},
 						},
 					},
@@ -1390,364 +1671,346 @@ var g = &grammar{
 		},
 		{
 			name: "QueryConfidentiality",
-			pos:  position{line: 334, col: 1, offset: 7938},
+			pos:  position{line: 625, col: 1, offset: 14605},
 			expr: &actionExpr{
-				pos: position{line: 334, col: 25, offset: 7962},
+				pos: position{line: 625, col: 25, offset: 14629},
 				run: (*parser).callonQueryConfidentiality1,
 				expr: &seqExpr{
-					pos: position{line: 334, col: 25, offset: 7962},
+					pos: position{line: 625, col: 25, offset: 14629},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 334, col: 25, offset: 7962},
+							pos:        position{line: 625, col: 25, offset: 14629},
 							val:        "confidentiality?",
 							ignoreCase: false,
 							want:       "\"confidentiality?\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 334, col: 44, offset: 7981},
+							pos:  position{line: 625, col: 44, offset: 14648},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 334, col: 46, offset: 7983},
+							pos:   position{line: 625, col: 46, offset: 14650},
 							label: "Const",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 334, col: 52, offset: 7989},
+								pos: position{line: 625, col: 52, offset: 14656},
 								expr: &ruleRefExpr{
-									pos:  position{line: 334, col: 52, offset: 7989},
+									pos:  position{line: 625, col: 52, offset: 14656},
 									name: "Constant",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 334, col: 62, offset: 7999},
-							name: "_",
-						},
 						&labeledExpr{
-							pos:   position{line: 334, col: 64, offset: 8001},
+							pos:   position{line: 625, col: 62, offset: 14666},
 							label: "Options",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 334, col: 72, offset: 8009},
+								pos: position{line: 625, col: 70, offset: 14674},
 								expr: &ruleRefExpr{
-									pos:  position{line: 334, col: 72, offset: 8009},
+									pos:  position{line: 625, col: 70, offset: 14674},
 									name: "QueryOptions",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 334, col: 86, offset: 8023},
-							name: "_",
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "QueryAuthentication",
-			pos:  position{line: 349, col: 1, offset: 8363},
+			pos:  position{line: 641, col: 1, offset: 15057},
 			expr: &actionExpr{
-				pos: position{line: 349, col: 24, offset: 8386},
+				pos: position{line: 641, col: 24, offset: 15080},
 				run: (*parser).callonQueryAuthentication1,
 				expr: &seqExpr{
-					pos: position{line: 349, col: 24, offset: 8386},
+					pos: position{line: 641, col: 24, offset: 15080},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 349, col: 24, offset: 8386},
+							pos:        position{line: 641, col: 24, offset: 15080},
 							val:        "authentication?",
 							ignoreCase: false,
 							want:       "\"authentication?\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 349, col: 42, offset: 8404},
+							pos:  position{line: 641, col: 42, offset: 15098},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 349, col: 44, offset: 8406},
+							pos:   position{line: 641, col: 44, offset: 15100},
 							label: "Message",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 349, col: 52, offset: 8414},
+								pos: position{line: 641, col: 52, offset: 15108},
 								expr: &ruleRefExpr{
-									pos:  position{line: 349, col: 52, offset: 8414},
+									pos:  position{line: 641, col: 52, offset: 15108},
 									name: "Message",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 349, col: 61, offset: 8423},
-							name: "_",
-						},
 						&labeledExpr{
-							pos:   position{line: 349, col: 63, offset: 8425},
+							pos:   position{line: 641, col: 61, offset: 15117},
 							label: "Options",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 349, col: 71, offset: 8433},
+								pos: position{line: 641, col: 69, offset: 15125},
 								expr: &ruleRefExpr{
-									pos:  position{line: 349, col: 71, offset: 8433},
+									pos:  position{line: 641, col: 69, offset: 15125},
 									name: "QueryOptions",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 349, col: 85, offset: 8447},
-							name: "_",
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "QueryFreshness",
-			pos:  position{line: 364, col: 1, offset: 8771},
+			pos:  position{line: 657, col: 1, offset: 15492},
 			expr: &actionExpr{
-				pos: position{line: 364, col: 19, offset: 8789},
+				pos: position{line: 657, col: 19, offset: 15510},
 				run: (*parser).callonQueryFreshness1,
 				expr: &seqExpr{
-					pos: position{line: 364, col: 19, offset: 8789},
+					pos: position{line: 657, col: 19, offset: 15510},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 364, col: 19, offset: 8789},
+							pos:        position{line: 657, col: 19, offset: 15510},
 							val:        "freshness?",
 							ignoreCase: false,
 							want:       "\"freshness?\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 364, col: 32, offset: 8802},
+							pos:  position{line: 657, col: 32, offset: 15523},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 364, col: 34, offset: 8804},
+							pos:   position{line: 657, col: 34, offset: 15525},
 							label: "Const",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 364, col: 40, offset: 8810},
+								pos: position{line: 657, col: 40, offset: 15531},
 								expr: &ruleRefExpr{
-									pos:  position{line: 364, col: 40, offset: 8810},
+									pos:  position{line: 657, col: 40, offset: 15531},
 									name: "Constant",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 364, col: 50, offset: 8820},
-							name: "_",
-						},
 						&labeledExpr{
-							pos:   position{line: 364, col: 52, offset: 8822},
+							pos:   position{line: 657, col: 50, offset: 15541},
 							label: "Options",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 364, col: 60, offset: 8830},
+								pos: position{line: 657, col: 58, offset: 15549},
 								expr: &ruleRefExpr{
-									pos:  position{line: 364, col: 60, offset: 8830},
+									pos:  position{line: 657, col: 58, offset: 15549},
 									name: "QueryOptions",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 364, col: 74, offset: 8844},
-							name: "_",
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "QueryUnlinkability",
-			pos:  position{line: 379, col: 1, offset: 9172},
+			pos:  position{line: 673, col: 1, offset: 15920},
 			expr: &actionExpr{
-				pos: position{line: 379, col: 23, offset: 9194},
+				pos: position{line: 673, col: 23, offset: 15942},
 				run: (*parser).callonQueryUnlinkability1,
 				expr: &seqExpr{
-					pos: position{line: 379, col: 23, offset: 9194},
+					pos: position{line: 673, col: 23, offset: 15942},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 379, col: 23, offset: 9194},
+							pos:        position{line: 673, col: 23, offset: 15942},
 							val:        "unlinkability?",
 							ignoreCase: false,
 							want:       "\"unlinkability?\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 379, col: 40, offset: 9211},
+							pos:  position{line: 673, col: 40, offset: 15959},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 379, col: 42, offset: 9213},
+							pos:   position{line: 673, col: 42, offset: 15961},
 							label: "Consts",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 379, col: 49, offset: 9220},
+								pos: position{line: 673, col: 49, offset: 15968},
 								expr: &ruleRefExpr{
-									pos:  position{line: 379, col: 49, offset: 9220},
+									pos:  position{line: 673, col: 49, offset: 15968},
 									name: "Constants",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 379, col: 60, offset: 9231},
-							name: "_",
-						},
 						&labeledExpr{
-							pos:   position{line: 379, col: 62, offset: 9233},
+							pos:   position{line: 673, col: 60, offset: 15979},
 							label: "Options",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 379, col: 70, offset: 9241},
+								pos: position{line: 673, col: 68, offset: 15987},
 								expr: &ruleRefExpr{
-									pos:  position{line: 379, col: 70, offset: 9241},
+									pos:  position{line: 673, col: 68, offset: 15987},
 									name: "QueryOptions",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 379, col: 84, offset: 9255},
-							name: "_",
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "QueryEquivalence",
-			pos:  position{line: 394, col: 1, offset: 9569},
+			pos:  position{line: 689, col: 1, offset: 16344},
 			expr: &actionExpr{
-				pos: position{line: 394, col: 21, offset: 9589},
+				pos: position{line: 689, col: 21, offset: 16364},
 				run: (*parser).callonQueryEquivalence1,
 				expr: &seqExpr{
-					pos: position{line: 394, col: 21, offset: 9589},
+					pos: position{line: 689, col: 21, offset: 16364},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 394, col: 21, offset: 9589},
+							pos:        position{line: 689, col: 21, offset: 16364},
 							val:        "equivalence?",
 							ignoreCase: false,
 							want:       "\"equivalence?\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 394, col: 36, offset: 9604},
+							pos:  position{line: 689, col: 36, offset: 16379},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 394, col: 38, offset: 9606},
+							pos:   position{line: 689, col: 38, offset: 16381},
 							label: "Consts",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 394, col: 45, offset: 9613},
+								pos: position{line: 689, col: 45, offset: 16388},
 								expr: &ruleRefExpr{
-									pos:  position{line: 394, col: 45, offset: 9613},
+									pos:  position{line: 689, col: 45, offset: 16388},
 									name: "Constants",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 394, col: 56, offset: 9624},
-							name: "_",
-						},
 						&labeledExpr{
-							pos:   position{line: 394, col: 58, offset: 9626},
+							pos:   position{line: 689, col: 56, offset: 16399},
 							label: "Options",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 394, col: 66, offset: 9634},
+								pos: position{line: 689, col: 64, offset: 16407},
 								expr: &ruleRefExpr{
-									pos:  position{line: 394, col: 66, offset: 9634},
+									pos:  position{line: 689, col: 64, offset: 16407},
 									name: "QueryOptions",
 								},
 							},
 						},
-						&ruleRefExpr{
-							pos:  position{line: 394, col: 80, offset: 9648},
-							name: "_",
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "QueryOptions",
-			pos:  position{line: 409, col: 1, offset: 9958},
+			pos:  position{line: 705, col: 1, offset: 16760},
 			expr: &actionExpr{
-				pos: position{line: 409, col: 17, offset: 9974},
+				pos: position{line: 705, col: 17, offset: 16776},
 				run: (*parser).callonQueryOptions1,
 				expr: &seqExpr{
-					pos: position{line: 409, col: 17, offset: 9974},
+					pos: position{line: 705, col: 17, offset: 16776},
 					exprs: []any{
+						&ruleRefExpr{
+							pos:  position{line: 705, col: 17, offset: 16776},
+							name: "_",
+						},
 						&litMatcher{
-							pos:        position{line: 409, col: 17, offset: 9974},
+							pos:        position{line: 705, col: 19, offset: 16778},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 409, col: 21, offset: 9978},
+							pos:  position{line: 705, col: 23, offset: 16782},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 409, col: 23, offset: 9980},
+							pos:   position{line: 705, col: 25, offset: 16784},
 							label: "Options",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 409, col: 32, offset: 9989},
+								pos: position{line: 705, col: 34, offset: 16793},
 								expr: &ruleRefExpr{
-									pos:  position{line: 409, col: 32, offset: 9989},
+									pos:  position{line: 705, col: 34, offset: 16793},
 									name: "QueryOption",
 								},
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 409, col: 46, offset: 10003},
+							pos:        position{line: 705, col: 48, offset: 16807},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
 						},
-						&ruleRefExpr{
-							pos:  position{line: 409, col: 50, offset: 10007},
-							name: "_",
-						},
 					},
 				},
 			},
 		},
 		{
 			name: "QueryOption",
-			pos:  position{line: 416, col: 1, offset: 10144},
+			pos:  position{line: 712, col: 1, offset: 16946},
 			expr: &actionExpr{
-				pos: position{line: 416, col: 16, offset: 10159},
+				pos: position{line: 712, col: 16, offset: 16961},
 				run: (*parser).callonQueryOption1,
 				expr: &seqExpr{
-					pos: position{line: 416, col: 16, offset: 10159},
+					pos: position{line: 712, col: 16, offset: 16961},
 					exprs: []any{
 						&labeledExpr{
-							pos:   position{line: 416, col: 16, offset: 10159},
+							pos:   position{line: 712, col: 16, offset: 16961},
+							label: "Leading",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 712, col: 24, offset: 16969},
+								expr: &ruleRefExpr{
+									pos:  position{line: 712, col: 24, offset: 16969},
+									name: "Comment",
+								},
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 712, col: 33, offset: 16978},
 							label: "OptionName",
 							expr: &ruleRefExpr{
-								pos:  position{line: 416, col: 27, offset: 10170},
+								pos:  position{line: 712, col: 44, offset: 16989},
 								name: "Identifier",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 416, col: 38, offset: 10181},
+							pos:  position{line: 712, col: 55, offset: 17000},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 416, col: 40, offset: 10183},
+							pos:        position{line: 712, col: 57, offset: 17002},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 416, col: 44, offset: 10187},
+							pos:  position{line: 712, col: 61, offset: 17006},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 416, col: 46, offset: 10189},
+							pos:   position{line: 712, col: 63, offset: 17008},
 							label: "Message",
 							expr: &ruleRefExpr{
-								pos:  position{line: 416, col: 54, offset: 10197},
+								pos:  position{line: 712, col: 71, offset: 17016},
 								name: "Message",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 416, col: 62, offset: 10205},
+							pos:  position{line: 712, col: 79, offset: 17024},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 416, col: 64, offset: 10207},
+							pos:        position{line: 712, col: 81, offset: 17026},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
 						},
+						&labeledExpr{
+							pos:   position{line: 712, col: 85, offset: 17030},
+							label: "Trailing",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 712, col: 94, offset: 17039},
+								expr: &ruleRefExpr{
+									pos:  position{line: 712, col: 94, offset: 17039},
+									name: "InlineComment",
+								},
+							},
+						},
 						&ruleRefExpr{
-							pos:  position{line: 416, col: 68, offset: 10211},
+							pos:  position{line: 712, col: 109, offset: 17054},
 							name: "_",
 						},
 					},
@@ -1756,17 +2019,17 @@ var g = &grammar{
 		},
 		{
 			name: "Identifier",
-			pos:  position{line: 428, col: 1, offset: 10429},
+			pos:  position{line: 728, col: 1, offset: 17373},
 			expr: &actionExpr{
-				pos: position{line: 428, col: 15, offset: 10443},
+				pos: position{line: 728, col: 15, offset: 17387},
 				run: (*parser).callonIdentifier1,
 				expr: &labeledExpr{
-					pos:   position{line: 428, col: 15, offset: 10443},
+					pos:   position{line: 728, col: 15, offset: 17387},
 					label: "Identifier",
 					expr: &oneOrMoreExpr{
-						pos: position{line: 428, col: 26, offset: 10454},
+						pos: position{line: 728, col: 26, offset: 17398},
 						expr: &charClassMatcher{
-							pos:        position{line: 428, col: 26, offset: 10454},
+							pos:        position{line: 728, col: 26, offset: 17398},
 							val:        "[a-zA-Z0-9_]",
 							chars:      []rune{'_'},
 							ranges:     []rune{'a', 'z', 'A', 'Z', '0', '9'},
@@ -1779,33 +2042,89 @@ var g = &grammar{
 		},
 		{
 			name: "Comment",
-			pos:  position{line: 433, col: 1, offset: 10544},
-			expr: &seqExpr{
-				pos: position{line: 433, col: 12, offset: 10555},
-				exprs: []any{
-					&ruleRefExpr{
-						pos:  position{line: 433, col: 12, offset: 10555},
-						name: "_",
+			pos:  position{line: 733, col: 1, offset: 17488},
+			expr: &actionExpr{
+				pos: position{line: 733, col: 12, offset: 17499},
+				run: (*parser).callonComment1,
+				expr: &seqExpr{
+					pos: position{line: 733, col: 12, offset: 17499},
+					exprs: []any{
+						&ruleRefExpr{
+							pos:  position{line: 733, col: 12, offset: 17499},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 733, col: 14, offset: 17501},
+							label: "Text",
+							expr: &ruleRefExpr{
+								pos:  position{line: 733, col: 19, offset: 17506},
+								name: "CommentText",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 733, col: 31, offset: 17518},
+							name: "_",
+						},
 					},
-					&litMatcher{
-						pos:        position{line: 433, col: 14, offset: 10557},
-						val:        "//",
-						ignoreCase: false,
-						want:       "\"//\"",
+				},
+			},
+		},
+		{
+			name: "InlineComment",
+			pos:  position{line: 737, col: 1, offset: 17543},
+			expr: &actionExpr{
+				pos: position{line: 737, col: 18, offset: 17560},
+				run: (*parser).callonInlineComment1,
+				expr: &seqExpr{
+					pos: position{line: 737, col: 18, offset: 17560},
+					exprs: []any{
+						&zeroOrMoreExpr{
+							pos: position{line: 737, col: 18, offset: 17560},
+							expr: &charClassMatcher{
+								pos:        position{line: 737, col: 18, offset: 17560},
+								val:        "[ \\t]",
+								chars:      []rune{' ', '\t'},
+								ignoreCase: false,
+								inverted:   false,
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 737, col: 25, offset: 17567},
+							label: "Text",
+							expr: &ruleRefExpr{
+								pos:  position{line: 737, col: 30, offset: 17572},
+								name: "CommentText",
+							},
+						},
 					},
-					&zeroOrMoreExpr{
-						pos: position{line: 433, col: 19, offset: 10562},
-						expr: &charClassMatcher{
-							pos:        position{line: 433, col: 19, offset: 10562},
-							val:        "[^\\n]",
-							chars:      []rune{'\n'},
+				},
+			},
+		},
+		{
+			name: "CommentText",
+			pos:  position{line: 741, col: 1, offset: 17607},
+			expr: &actionExpr{
+				pos: position{line: 741, col: 16, offset: 17622},
+				run: (*parser).callonCommentText1,
+				expr: &seqExpr{
+					pos: position{line: 741, col: 16, offset: 17622},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 741, col: 16, offset: 17622},
+							val:        "//",
 							ignoreCase: false,
-							inverted:   true,
+							want:       "\"//\"",
+						},
+						&zeroOrMoreExpr{
+							pos: position{line: 741, col: 21, offset: 17627},
+							expr: &charClassMatcher{
+								pos:        position{line: 741, col: 21, offset: 17627},
+								val:        "[^\\n]",
+								chars:      []rune{'\n'},
+								ignoreCase: false,
+								inverted:   true,
+							},
 						},
-					},
-					&ruleRefExpr{
-						pos:  position{line: 433, col: 26, offset: 10569},
-						name: "_",
 					},
 				},
 			},
@@ -1813,11 +2132,11 @@ var g = &grammar{
 		{
 			name:        "_",
 			displayName: "\"whitespace\"",
-			pos:         position{line: 435, col: 1, offset: 10572},
+			pos:         position{line: 748, col: 1, offset: 17751},
 			expr: &zeroOrMoreExpr{
-				pos: position{line: 435, col: 19, offset: 10590},
+				pos: position{line: 748, col: 19, offset: 17769},
 				expr: &charClassMatcher{
-					pos:        position{line: 435, col: 19, offset: 10590},
+					pos:        position{line: 748, col: 19, offset: 17769},
 					val:        "[ \\t\\n\\r]",
 					chars:      []rune{' ', '\t', '\n', '\r'},
 					ignoreCase: false,
@@ -1827,18 +2146,18 @@ var g = &grammar{
 		},
 		{
 			name: "EOF",
-			pos:  position{line: 437, col: 1, offset: 10602},
+			pos:  position{line: 750, col: 1, offset: 17781},
 			expr: &notExpr{
-				pos: position{line: 437, col: 8, offset: 10609},
+				pos: position{line: 750, col: 8, offset: 17788},
 				expr: &anyMatcher{
-					line: 437, col: 9, offset: 10610,
+					line: 750, col: 9, offset: 17789,
 				},
 			},
 		},
 	},
 }
 
-func (c *current) onModel1(Attacker, Blocks, Queries any) (any, error) {
+func (c *current) onModel1(Leading, Attacker, Blocks, Queries, Trailing any) (any, error) {
 	switch {
 	case Attacker == nil:
 		return nil, errors.New("no `attacker` block defined")
@@ -1848,26 +2167,27 @@ func (c *current) onModel1(Attacker, Blocks, Queries any) (any, error) {
 		return nil, errors.New("no `queries` block defined")
 	}
 	b := Blocks.([]interface{})
-	q := Queries.([]interface{})
+	q := Queries.(libpegQueries)
 	db := make([]Block, len(b))
-	dq := make([]Query, len(q))
 	for i, v := range b {
 		db[i] = v.(Block)
 	}
-	for i, v := range q {
-		dq[i] = v.(Query)
-	}
 	return Model{
 		Attacker: Attacker.(string),
 		Blocks:   db,
-		Queries:  dq,
+		Queries:  q.Queries,
+		Comments: Trivia{
+			Leading:  libpegComments(Leading),
+			Trailing: libpegComments(Trailing),
+		},
+		QueriesComments: q.Comments,
 	}, nil
 }
 
 func (p *parser) callonModel1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onModel1(stack["Attacker"], stack["Blocks"], stack["Queries"])
+	return p.cur.onModel1(stack["Leading"], stack["Attacker"], stack["Blocks"], stack["Queries"], stack["Trailing"])
 }
 
 func (c *current) onAttacker1(Type any) (any, error) {
@@ -1893,29 +2213,37 @@ func (p *parser) callonAttackerType1() (any, error) {
 	return p.cur.onAttackerType1()
 }
 
-func (c *current) onBlock1(Block any) (any, error) {
-	return Block, nil
+func (c *current) onBlock1(Leading, B, Trailing any) (any, error) {
+	b := B.(Block)
+	b.Comments = Trivia{
+		Leading:  libpegComments(Leading),
+		Trailing: libpegComments(Trailing),
+	}
+	return b, nil
 }
 
 func (p *parser) callonBlock1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onBlock1(stack["Block"])
+	return p.cur.onBlock1(stack["Leading"], stack["B"], stack["Trailing"])
 }
 
-func (c *current) onPrincipal1(Name, Expressions any) (any, error) {
+func (c *current) onPrincipal1(Name, Expressions, Closing any) (any, error) {
 	e := Expressions.([]interface{})
 	de := make([]Expression, len(e))
 	for i, v := range e {
 		de[i] = v.(Expression)
 	}
-	id := principalNamesMapAdd(Name.(string))
+	s := libpegSession(c)
+	id := s.principalNamesMapAdd(Name.(string))
 	return Block{
-		Kind: "principal",
+		Kind:     "principal",
+		Position: libpegPosition(c),
 		Principal: Principal{
 			Name:        Name.(string),
 			ID:          id,
 			Expressions: de,
+			Comments:    libpegComments(Closing),
 		},
 	}, nil
 }
@@ -1923,7 +2251,7 @@ func (c *current) onPrincipal1(Name, Expressions any) (any, error) {
 func (p *parser) callonPrincipal1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onPrincipal1(stack["Name"], stack["Expressions"])
+	return p.cur.onPrincipal1(stack["Name"], stack["Expressions"], stack["Closing"])
 }
 
 func (c *current) onPrincipalName1(Name any) (any, error) {
@@ -1963,10 +2291,12 @@ func (c *current) onMessage1(Sender, Recipient, Constants any) (any, error) {
 	case Constants == nil:
 		return nil, errors.New("message constants are not defined")
 	}
-	senderID := principalNamesMapAdd(Sender.(string))
-	recipientID := principalNamesMapAdd(Recipient.(string))
+	s := libpegSession(c)
+	senderID := s.principalNamesMapAdd(Sender.(string))
+	recipientID := s.principalNamesMapAdd(Recipient.(string))
 	return Block{
-		Kind: "message",
+		Kind:     "message",
+		Position: libpegPosition(c),
 		Message: Message{
 			Sender:    senderID,
 			Recipient: recipientID,
@@ -1997,14 +2327,19 @@ func (p *parser) callonMessageConstants1() (any, error) {
 	return p.cur.onMessageConstants1(stack["MessageConstants"])
 }
 
-func (c *current) onExpression1(Expression any) (any, error) {
-	return Expression, nil
+func (c *current) onExpression1(Leading, E, Trailing any) (any, error) {
+	e := E.(Expression)
+	e.Comments = Trivia{
+		Leading:  libpegComments(Leading),
+		Trailing: libpegComments(Trailing),
+	}
+	return e, nil
 }
 
 func (p *parser) callonExpression1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onExpression1(stack["Expression"])
+	return p.cur.onExpression1(stack["Leading"], stack["E"], stack["Trailing"])
 }
 
 func (c *current) onKnows1(Qualifier, Constants any) (any, error) {
@@ -2016,6 +2351,7 @@ func (c *current) onKnows1(Qualifier, Constants any) (any, error) {
 	}
 	return Expression{
 		Kind:      typesEnumKnows,
+		Position:  libpegPosition(c),
 		Qualifier: Qualifier.(typesEnum),
 		Constants: Constants.([]*Constant),
 	}, nil
@@ -2033,6 +2369,7 @@ func (c *current) onGenerates1(Constants any) (any, error) {
 	}
 	return Expression{
 		Kind:      typesEnumGenerates,
+		Position:  libpegPosition(c),
 		Qualifier: typesEnumEmpty,
 		Constants: Constants.([]*Constant),
 	}, nil
@@ -2050,6 +2387,7 @@ func (c *current) onLeaks1(Constants any) (any, error) {
 	}
 	return Expression{
 		Kind:      typesEnumLeaks,
+		Position:  libpegPosition(c),
 		Qualifier: typesEnumEmpty,
 		Constants: Constants.([]*Constant),
 	}, nil
@@ -2072,6 +2410,7 @@ func (c *current) onAssignment1(Left, Right any) (any, error) {
 	}
 	return Expression{
 		Kind:      typesEnumAssignment,
+		Position:  libpegPosition(c),
 		Constants: Left.([]*Constant),
 		Assigned:  Right.(*Value),
 	}, nil
@@ -2090,17 +2429,19 @@ func (c *current) onConstant1(Const any) (any, error) {
 	if err != nil {
 		return &Value{}, err
 	}
+	s := libpegSession(c)
 	switch name {
 	case "_":
-		name = fmt.Sprintf("unnamed_%d", libpegUnnamedCounter)
-		libpegUnnamedCounter = libpegUnnamedCounter + 1
+		name = fmt.Sprintf("unnamed_%d", s.libpegUnnamedCounter)
+		s.libpegUnnamedCounter = s.libpegUnnamedCounter + 1
 	}
-	id := valueNamesMapAdd(name)
+	id := s.valueNamesMapAdd(name)
 	return &Value{
 		Kind: typesEnumConstant,
 		Data: &Constant{
-			Name: name,
-			ID:   id,
+			Name:     name,
+			ID:       id,
+			Position: libpegPosition(c),
 		},
 	}, err
 }
@@ -2134,7 +2475,8 @@ func (c *current) onPhase1(Number any) (any, error) {
 	}
 	n, err := strconv.Atoi(b2s(da))
 	return Block{
-		Kind: "phase",
+		Kind:     "phase",
+		Position: libpegPosition(c),
 		Phase: Phase{
 			Number: n,
 		},
@@ -2153,9 +2495,10 @@ func (c *current) onGuardedConstant1(Guarded any) (any, error) {
 	return &Value{
 		Kind: typesEnumConstant,
 		Data: &Constant{
-			Name:  g.Data.(*Constant).Name,
-			ID:    g.Data.(*Constant).ID,
-			Guard: true,
+			Name:     g.Data.(*Constant).Name,
+			ID:       g.Data.(*Constant).ID,
+			Guard:    true,
+			Position: g.Data.(*Constant).Position,
 		},
 	}, err
 }
@@ -2179,6 +2522,7 @@ func (c *current) onPrimitive1(Name, Arguments, Check any) (any, error) {
 			Arguments: args,
 			Output:    0,
 			Check:     Check != nil,
+			Position:  libpegPosition(c),
 		},
 	}, err
 }
@@ -2217,24 +2561,59 @@ func (p *parser) callonEquation1() (any, error) {
 	return p.cur.onEquation1(stack["First"], stack["Second"])
 }
 
-func (c *current) onQueries1(Queries any) (any, error) {
-	return Queries, nil
+func (c *current) onQueries1(Leading, Queries, Closing any) (any, error) {
+	q := Queries.([]interface{})
+	dq := make([]Query, len(q))
+	for i, v := range q {
+		dq[i] = v.(Query)
+	}
+	return libpegQueries{
+		Queries: dq,
+		Comments: Trivia{
+			Leading:  libpegComments(Leading),
+			Trailing: libpegComments(Closing),
+		},
+	}, nil
 }
 
 func (p *parser) callonQueries1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onQueries1(stack["Queries"])
+	return p.cur.onQueries1(stack["Leading"], stack["Queries"], stack["Closing"])
 }
 
-func (c *current) onQuery1(Query any) (any, error) {
-	return Query, nil
+func (c *current) onQuery1(Leading, Q, Expect, Trailing any) (any, error) {
+	q := Q.(Query)
+	if Expect != nil {
+		q.Expect = Expect.(typesEnum)
+	}
+	q.Comments = Trivia{
+		Leading:  libpegComments(Leading),
+		Trailing: libpegComments(Trailing),
+	}
+	return q, nil
 }
 
 func (p *parser) callonQuery1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onQuery1(stack["Query"])
+	return p.cur.onQuery1(stack["Leading"], stack["Q"], stack["Expect"], stack["Trailing"])
+}
+
+func (c *current) onQueryExpect1(Expect any) (any, error) {
+	switch Expect.(string) {
+	case "pass":
+		return typesEnumPass, nil
+	case "fail":
+		return typesEnumFail, nil
+	}
+	return nil, fmt.Errorf("invalid query expectation (%s)", Expect.(string))
+}
+
+func (p *parser) callonQueryExpect1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onQueryExpect1(stack["Expect"])
 }
 
 func (c *current) onQueryConfidentiality1(Const, Options any) (any, error) {
@@ -2246,6 +2625,7 @@ func (c *current) onQueryConfidentiality1(Const, Options any) (any, error) {
 	}
 	return Query{
 		Kind:      typesEnumConfidentiality,
+		Position:  libpegPosition(c),
 		Constants: []*Constant{Const.(*Value).Data.(*Constant)},
 		Message:   Message{},
 		Options:   Options.([]QueryOption),
@@ -2267,6 +2647,7 @@ func (c *current) onQueryAuthentication1(Message, Options any) (any, error) {
 	}
 	return Query{
 		Kind:      typesEnumAuthentication,
+		Position:  libpegPosition(c),
 		Constants: []*Constant{},
 		Message:   (Message.(Block)).Message,
 		Options:   Options.([]QueryOption),
@@ -2288,6 +2669,7 @@ func (c *current) onQueryFreshness1(Const, Options any) (any, error) {
 	}
 	return Query{
 		Kind:      typesEnumFreshness,
+		Position:  libpegPosition(c),
 		Constants: []*Constant{Const.(*Value).Data.(*Constant)},
 		Message:   Message{},
 		Options:   Options.([]QueryOption),
@@ -2309,6 +2691,7 @@ func (c *current) onQueryUnlinkability1(Consts, Options any) (any, error) {
 	}
 	return Query{
 		Kind:      typesEnumUnlinkability,
+		Position:  libpegPosition(c),
 		Constants: Consts.([]*Constant),
 		Message:   Message{},
 		Options:   Options.([]QueryOption),
@@ -2330,6 +2713,7 @@ func (c *current) onQueryEquivalence1(Consts, Options any) (any, error) {
 	}
 	return Query{
 		Kind:      typesEnumEquivalence,
+		Position:  libpegPosition(c),
 		Constants: Consts.([]*Constant),
 		Message:   Message{},
 		Options:   Options.([]QueryOption),
@@ -2357,7 +2741,7 @@ func (p *parser) callonQueryOptions1() (any, error) {
 	return p.cur.onQueryOptions1(stack["Options"])
 }
 
-func (c *current) onQueryOption1(OptionName, Message any) (any, error) {
+func (c *current) onQueryOption1(Leading, OptionName, Message, Trailing any) (any, error) {
 	optionEnum := typesEnumEmpty
 	switch OptionName.(string) {
 	case "precondition":
@@ -2366,13 +2750,17 @@ func (c *current) onQueryOption1(OptionName, Message any) (any, error) {
 	return QueryOption{
 		Kind:    optionEnum,
 		Message: (Message.(Block)).Message,
+		Comments: Trivia{
+			Leading:  libpegComments(Leading),
+			Trailing: libpegComments(Trailing),
+		},
 	}, nil
 }
 
 func (p *parser) callonQueryOption1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onQueryOption1(stack["OptionName"], stack["Message"])
+	return p.cur.onQueryOption1(stack["Leading"], stack["OptionName"], stack["Message"], stack["Trailing"])
 }
 
 func (c *current) onIdentifier1(Identifier any) (any, error) {
@@ -2386,6 +2774,39 @@ func (p *parser) callonIdentifier1() (any, error) {
 	return p.cur.onIdentifier1(stack["Identifier"])
 }
 
+func (c *current) onComment1(Text any) (any, error) {
+	return Text, nil
+}
+
+func (p *parser) callonComment1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onComment1(stack["Text"])
+}
+
+func (c *current) onInlineComment1(Text any) (any, error) {
+	return Text, nil
+}
+
+func (p *parser) callonInlineComment1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onInlineComment1(stack["Text"])
+}
+
+func (c *current) onCommentText1() (any, error) {
+	return Comment{
+		Text:     strings.TrimRight(string(c.text[2:]), " \t\r"),
+		Position: libpegPosition(c),
+	}, nil
+}
+
+func (p *parser) callonCommentText1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onCommentText1()
+}
+
 var (
 	// errNoRule is returned when the grammar to parse has no rule.
 	errNoRule = errors.New("grammar has no rule")
@@ -176,21 +176,33 @@ var libpv = PvTemplate{
 		}
 		return strings.Join(channels, "\n") + "\n"
 	},
-	Queries: func(valKnowledgeMap *KnowledgeMap, queries []Query) (string, error) {
+	Queries: func(s *Session, valKnowledgeMap *KnowledgeMap, queries []Query, comments Trivia) (string, error) {
 		output := []string{
 			"event SendMsg(principal, principal, stage, bitstring).",
 			"event RecvMsg(principal, principal, stage, bitstring).",
 		}
+		for _, comment := range comments.Leading {
+			output = append(output, prettyMLComment(comment))
+		}
 		for _, q := range queries {
-			pvq, err := pvQuery(valKnowledgeMap, q)
+			pvq, err := s.pvQuery(valKnowledgeMap, q)
 			if err != nil {
 				return "", err
 			}
+			for _, comment := range q.Comments.Leading {
+				output = append(output, prettyMLComment(comment))
+			}
+			for _, comment := range q.Comments.Trailing {
+				pvq = fmt.Sprintf("%s %s", pvq, prettyMLComment(comment))
+			}
 			output = append(output, pvq)
 		}
+		for _, comment := range comments.Trailing {
+			output = append(output, prettyMLComment(comment))
+		}
 		return strings.Join(output, "\n") + "\n", nil
 	},
-	TopLevel: func(blocks []Block) string {
+	TopLevel: func(s *Session, blocks []Block) string {
 		pc := 0
 		parallel := ""
 		for i, block := range blocks {
@@ -209,14 +221,14 @@ var libpv = PvTemplate{
 			case "message":
 				parallel = fmt.Sprintf(
 					"%s%s_to_%s_%d()%s",
-					parallel, principalGetNameFromID(block.Message.Sender),
-					principalGetNameFromID(block.Message.Recipient), pc, sep,
+					parallel, s.principalGetNameFromID(block.Message.Sender),
+					s.principalGetNameFromID(block.Message.Recipient), pc, sep,
 				)
 				pc = pc + 1
 				parallel = fmt.Sprintf(
 					"%s%s_from_%s_%d()%s",
-					parallel, principalGetNameFromID(block.Message.Recipient),
-					principalGetNameFromID(block.Message.Sender), pc, sep,
+					parallel, s.principalGetNameFromID(block.Message.Recipient),
+					s.principalGetNameFromID(block.Message.Sender), pc, sep,
 				)
 				pc = pc + 1
 			}
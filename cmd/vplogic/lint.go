@@ -0,0 +1,377 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Lint checks a Verifpal model loaded from a file for likely modelling mistakes,
+// such as values which are generated but never used, or checkable primitives whose
+// results are used without being checked. The model must first pass the same
+// sanity checks as it would before verification; failing those is an error.
+// It returns the warnings found, ordered by their position in the model.
+func Lint(filePath string) ([]LintWarning, error) {
+	s := NewSessionWithOptions(Options{})
+	m, err := s.libpegParseModel(filePath, false)
+	if err != nil {
+		return []LintWarning{}, err
+	}
+	return s.lint(m)
+}
+
+func (s *Session) lint(m Model) ([]LintWarning, error) {
+	valKnowledgeMap, valPrincipalStates, err := s.sanity(m)
+	if err != nil {
+		return []LintWarning{}, err
+	}
+	warnings := []LintWarning{}
+	warnings = append(warnings, s.lintUnusedGenerated(m, valKnowledgeMap)...)
+	warnings = append(warnings, s.lintUnusedReceived(m, valKnowledgeMap)...)
+	warnings = append(warnings, lintUncheckedPrimitives(valKnowledgeMap)...)
+	warnings = append(warnings, s.lintUncheckedGuards(m, valKnowledgeMap, valPrincipalStates)...)
+	warnings = append(warnings, lintUnsentQueries(m, valKnowledgeMap)...)
+	warnings = append(warnings, s.lintIdlePrincipals(m, valKnowledgeMap)...)
+	sort.SliceStable(warnings, func(i int, j int) bool {
+		if warnings[i].Position.Line != warnings[j].Position.Line {
+			return warnings[i].Position.Line < warnings[j].Position.Line
+		}
+		return warnings[i].Position.Column < warnings[j].Position.Column
+	})
+	return warnings, nil
+}
+
+func (s *Session) lintUnusedGenerated(m Model, valKnowledgeMap *KnowledgeMap) []LintWarning {
+	warnings := []LintWarning{}
+	for i, c := range valKnowledgeMap.Constants {
+		if c.Declaration != typesEnumGenerates {
+			continue
+		}
+		if s.lintConstantUsed(m, valKnowledgeMap, c, 0) {
+			continue
+		}
+		warnings = append(warnings, LintWarning{
+			Position: c.Position,
+			Rule:     "unused-generated",
+			Message: fmt.Sprintf(
+				"%s generates %s but never uses, sends or leaks it",
+				s.principalGetNameFromID(valKnowledgeMap.Creator[i]), c.Name,
+			),
+		})
+	}
+	return warnings
+}
+
+func (s *Session) lintUnusedReceived(m Model, valKnowledgeMap *KnowledgeMap) []LintWarning {
+	warnings := []LintWarning{}
+	reported := map[principalEnum][]*Constant{}
+	for _, blck := range m.Blocks {
+		if blck.Kind != "message" {
+			continue
+		}
+		recipient := blck.Message.Recipient
+		for _, c := range blck.Message.Constants {
+			if valueEquivalentConstantInConstants(c, reported[recipient]) >= 0 {
+				continue
+			}
+			if s.lintConstantUsed(m, valKnowledgeMap, c, recipient) {
+				continue
+			}
+			reported[recipient] = append(reported[recipient], c)
+			warnings = append(warnings, LintWarning{
+				Position: blck.Position,
+				Rule:     "unused-received",
+				Message: fmt.Sprintf(
+					"%s receives %s but never uses, sends or leaks it",
+					s.principalGetNameFromID(recipient), c.Name,
+				),
+			})
+		}
+	}
+	return warnings
+}
+
+func lintUncheckedPrimitives(valKnowledgeMap *KnowledgeMap) []LintWarning {
+	warnings := []LintWarning{}
+	reported := []*Value{}
+	for i, a := range valKnowledgeMap.Assigned {
+		if a.Kind != typesEnumPrimitive || valueEquivalentValueInValues(a, reported) >= 0 {
+			continue
+		}
+		reported = append(reported, a)
+		for _, p := range lintUncheckedPrimitivesInValue(a) {
+			warnings = append(warnings, LintWarning{
+				Position: valKnowledgeMap.Constants[i].Position,
+				Rule:     "unchecked-primitive",
+				Message: fmt.Sprintf(
+					"%s is used without being checked: %s (add '?' to check it)",
+					lintPrimitiveName(p.ID), prettyPrimitive(p),
+				),
+			})
+		}
+	}
+	return warnings
+}
+
+func lintUncheckedPrimitivesInValue(a *Value) []*Primitive {
+	unchecked := []*Primitive{}
+	switch a.Kind {
+	case typesEnumPrimitive:
+		p := a.Data.(*Primitive)
+		if lintPrimitiveCheckable(p.ID) && !p.Check {
+			unchecked = append(unchecked, p)
+		}
+		for _, aa := range p.Arguments {
+			unchecked = append(unchecked, lintUncheckedPrimitivesInValue(aa)...)
+		}
+	case typesEnumEquation:
+		for _, aa := range a.Data.(*Equation).Values {
+			unchecked = append(unchecked, lintUncheckedPrimitivesInValue(aa)...)
+		}
+	}
+	return unchecked
+}
+
+func (s *Session) lintUncheckedGuards(
+	m Model, valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState,
+) []LintWarning {
+	warnings := []LintWarning{}
+	for _, blck := range m.Blocks {
+		if blck.Kind != "message" {
+			continue
+		}
+		for _, c := range blck.Message.Constants {
+			if !c.Guard {
+				continue
+			}
+			if lintConstantChecked(valKnowledgeMap, valPrincipalStates, c, blck.Message.Recipient) {
+				continue
+			}
+			warnings = append(warnings, LintWarning{
+				Position: blck.Position,
+				Rule:     "unchecked-guard",
+				Message: fmt.Sprintf(
+					"%s receives %s as guarded but never checks a value derived from it",
+					s.principalGetNameFromID(blck.Message.Recipient), c.Name,
+				),
+			})
+		}
+	}
+	return warnings
+}
+
+func lintUnsentQueries(m Model, valKnowledgeMap *KnowledgeMap) []LintWarning {
+	warnings := []LintWarning{}
+	exposed := lintExposedConstants(m, valKnowledgeMap)
+	for _, query := range m.Queries {
+		switch query.Kind {
+		case typesEnumAuthentication, typesEnumEquivalence:
+			continue
+		}
+		for _, c := range query.Constants {
+			i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, c)
+			if i < 0 || valKnowledgeMap.Constants[i].Qualifier == typesEnumPublic {
+				continue
+			}
+			if valueEquivalentConstantInConstants(c, exposed) >= 0 {
+				continue
+			}
+			warnings = append(warnings, LintWarning{
+				Position: query.Position,
+				Rule:     "unsent-query",
+				Message: fmt.Sprintf(
+					"%s query on %s, which no principal sends or leaks",
+					reportQueryKind(query.Kind), c.Name,
+				),
+			})
+		}
+	}
+	return warnings
+}
+
+func (s *Session) lintIdlePrincipals(m Model, valKnowledgeMap *KnowledgeMap) []LintWarning {
+	warnings := []LintWarning{}
+	for i, id := range valKnowledgeMap.PrincipalIDs {
+		active := false
+		declared := Position{}
+		for _, blck := range m.Blocks {
+			switch blck.Kind {
+			case "principal":
+				if blck.Principal.ID == id && declared.Line == 0 {
+					declared = blck.Position
+				}
+			case "message":
+				if blck.Message.Sender == id || blck.Message.Recipient == id {
+					active = true
+				}
+			}
+		}
+		if active {
+			continue
+		}
+		warnings = append(warnings, LintWarning{
+			Position: declared,
+			Rule:     "idle-principal",
+			Message: fmt.Sprintf(
+				"%s never sends or receives a message", valKnowledgeMap.Principals[i],
+			),
+		})
+	}
+	return warnings
+}
+
+// lintConstantUsed returns whether c is used in an assignment, sent in a message or leaked.
+// If by is not the Attacker, only the uses of c by that principal are considered.
+func (s *Session) lintConstantUsed(m Model, valKnowledgeMap *KnowledgeMap, c *Constant, by principalEnum) bool {
+	for i, a := range valKnowledgeMap.Assigned {
+		if by != 0 && valKnowledgeMap.Creator[i] != by {
+			continue
+		}
+		if valueEquivalentConstants(valKnowledgeMap.Constants[i], c) {
+			continue
+		}
+		if valueEquivalentConstantInConstants(c, s.valueGetConstantsFromValue(a)) >= 0 {
+			return true
+		}
+	}
+	for _, blck := range m.Blocks {
+		switch blck.Kind {
+		case "principal":
+			if by != 0 && blck.Principal.ID != by {
+				continue
+			}
+			for _, expr := range blck.Principal.Expressions {
+				if expr.Kind != typesEnumLeaks {
+					continue
+				}
+				if valueEquivalentConstantInConstants(c, expr.Constants) >= 0 {
+					return true
+				}
+			}
+		case "message":
+			if by != 0 && blck.Message.Sender != by {
+				continue
+			}
+			if valueEquivalentConstantInConstants(c, blck.Message.Constants) >= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lintConstantChecked returns whether a checked primitive assigned by the recipient
+// depends on c, either directly or through the values assigned to its arguments.
+func lintConstantChecked(
+	valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState, c *Constant, recipient principalEnum,
+) bool {
+	for _, valPrincipalState := range valPrincipalStates {
+		if valPrincipalState.ID != recipient {
+			continue
+		}
+		for i, a := range valPrincipalState.Assigned {
+			if valPrincipalState.Creator[i] != recipient || !lintValueChecked(a) {
+				continue
+			}
+			_, v := valueResolveValueInternalValuesFromKnowledgeMap(a, valKnowledgeMap)
+			for _, vv := range v {
+				if vv.Kind == typesEnumConstant && valueEquivalentConstants(vv.Data.(*Constant), c) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func lintValueChecked(a *Value) bool {
+	switch a.Kind {
+	case typesEnumPrimitive:
+		if a.Data.(*Primitive).Check {
+			return true
+		}
+		for _, aa := range a.Data.(*Primitive).Arguments {
+			if lintValueChecked(aa) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lintExposedConstants returns every constant which is sent or leaked by some principal,
+// along with every constant from which the values of those constants are constructed.
+func lintExposedConstants(m Model, valKnowledgeMap *KnowledgeMap) []*Constant {
+	exposed := []*Constant{}
+	expose := func(constants []*Constant) {
+		for _, c := range constants {
+			if valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, c) < 0 {
+				continue
+			}
+			_, v := valueResolveValueInternalValuesFromKnowledgeMap(&Value{
+				Kind: typesEnumConstant,
+				Data: c,
+			}, valKnowledgeMap)
+			for _, vv := range v {
+				if vv.Kind != typesEnumConstant {
+					continue
+				}
+				if valueEquivalentConstantInConstants(vv.Data.(*Constant), exposed) < 0 {
+					exposed = append(exposed, vv.Data.(*Constant))
+				}
+			}
+		}
+	}
+	for _, blck := range m.Blocks {
+		switch blck.Kind {
+		case "principal":
+			for _, expr := range blck.Principal.Expressions {
+				if expr.Kind == typesEnumLeaks {
+					expose(expr.Constants)
+				}
+			}
+		case "message":
+			expose(blck.Message.Constants)
+		}
+	}
+	return exposed
+}
+
+// lintPrimitiveCheckable returns whether a primitive exists to verify something,
+// such that using its result without checking it is most likely a mistake.
+// SPLIT may also be checked, but is left out since it is routinely used unchecked.
+func lintPrimitiveCheckable(id primitiveEnum) bool {
+	switch id {
+	case primitiveEnumASSERT, primitiveEnumAEADDEC, primitiveEnumSIGNVERIF,
+		primitiveEnumRINGSIGNVERIF, primitiveEnumZK_VERIFY:
+		return true
+	}
+	return false
+}
+
+func lintPrimitiveName(id primitiveEnum) string {
+	if primitiveIsCorePrimitive(id) {
+		prim, _ := primitiveCoreGet(id)
+		return prim.Name
+	}
+	prim, _ := primitiveGet(id)
+	return prim.Name
+}
+
+// WriteLintWarnings writes lint warnings as lines of the form "file:line:column: rule: message".
+func WriteLintWarnings(w io.Writer, fileName string, warnings []LintWarning) error {
+	for _, warning := range warnings {
+		_, err := fmt.Fprintf(
+			w, "%s:%d:%d: %s: %s\n",
+			fileName, warning.Position.Line, warning.Position.Column, warning.Rule, warning.Message,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
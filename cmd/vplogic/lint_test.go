@@ -0,0 +1,72 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	s := NewSessionWithOptions(Options{})
+	m, err := s.ParseBytes("lint.vp", []byte(strings.Join([]string{
+		"attacker[active]",
+		"principal Alice[",
+		"	knows private k",
+		"	generates m, n",
+		"	knows public c",
+		"	e = AEAD_ENC(k, m, c)",
+		"	h = HASH(m)",
+		"]",
+		"principal Bob[",
+		"	knows private k",
+		"	generates b",
+		"	gb = G^b",
+		"]",
+		"principal Carol[knows private x]",
+		"Alice -> Bob: e, h",
+		"Bob -> Alice: [gb]",
+		"principal Bob[",
+		"	m_ = AEAD_DEC(k, e, c)",
+		"]",
+		"queries[",
+		"	confidentiality? m",
+		"	confidentiality? m_",
+		"]",
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings, err := s.Lint(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"4:15 unused-generated",
+		"14:1 idle-principal",
+		"15:1 unused-received",
+		"16:1 unused-received",
+		"16:1 unchecked-guard",
+		"18:2 unchecked-primitive",
+		"22:2 unsent-query",
+	}
+	got := []string{}
+	for _, warning := range warnings {
+		got = append(got, fmt.Sprintf("%d:%d %s", warning.Position.Line, warning.Position.Column, warning.Rule))
+	}
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected warnings %v, got %v", expected, got)
+	}
+	var output bytes.Buffer
+	err = WriteLintWarnings(&output, "lint.vp", warnings[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "lint.vp:4:15: unused-generated: Alice generates n but never uses, sends or leaks it\n" {
+		t.Errorf("unexpected lint output %q", output.String())
+	}
+}
@@ -0,0 +1,614 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	lspSeverityError      = 1
+	lspCompletionFunction = 3
+	lspSyncFull           = 1
+)
+
+var lspCapabilities = map[string]interface{}{
+	"capabilities": map[string]interface{}{
+		"textDocumentSync":           lspSyncFull,
+		"completionProvider":         map[string]interface{}{},
+		"hoverProvider":              true,
+		"definitionProvider":         true,
+		"referencesProvider":         true,
+		"documentFormattingProvider": true,
+	},
+	"serverInfo": map[string]interface{}{
+		"name": "verifpal",
+	},
+}
+
+// LSP runs a Language Server Protocol server, reading messages from r and writing
+// messages to w until the client asks the server to exit or r is closed.
+// Diagnostics are published for the errors found while parsing and checking each
+// model opened by the client. Completion of primitive names, hovering over constants,
+// going to the definition of and finding the references to constants and principals,
+// and document formatting are supported.
+func LSP(r io.Reader, w io.Writer) error {
+	server := &lspServer{
+		Reader:    bufio.NewReader(r),
+		Writer:    w,
+		Documents: map[string]*lspDocument{},
+	}
+	for {
+		msg, err := server.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !server.Shutdown {
+				return fmt.Errorf("exit requested before shutdown")
+			}
+			return nil
+		}
+		err = server.handle(msg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (server *lspServer) read() (jsonRPCMessage, error) {
+	body, err := jsonRPCRead(server.Reader)
+	if err != nil {
+		return jsonRPCMessage{}, err
+	}
+	msg := jsonRPCMessage{}
+	err = json.Unmarshal(body, &msg)
+	if err != nil {
+		return jsonRPCMessage{}, server.respond(nil, nil, &jsonRPCError{
+			Code: jsonRPCErrorParse, Message: err.Error(),
+		})
+	}
+	return msg, nil
+}
+
+func (server *lspServer) respond(id *json.RawMessage, result interface{}, rpcErr *jsonRPCError) error {
+	return jsonRPCWriteResponse(server.Writer, id, result, rpcErr)
+}
+
+func (server *lspServer) notify(method string, params interface{}) error {
+	return jsonRPCWriteNotification(server.Writer, method, params)
+}
+
+func (server *lspServer) handle(msg jsonRPCMessage) error {
+	if msg.ID == nil {
+		return server.handleNotification(msg)
+	}
+	result, err := server.handleRequest(msg)
+	if err != nil {
+		return server.respond(msg.ID, nil, err)
+	}
+	return server.respond(msg.ID, result, nil)
+}
+
+func (server *lspServer) handleNotification(msg jsonRPCMessage) error {
+	switch msg.Method {
+	case "textDocument/didOpen":
+		params := lspDidOpenParams{}
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return nil
+		}
+		return server.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		params := lspDidChangeParams{}
+		if json.Unmarshal(msg.Params, &params) != nil || len(params.ContentChanges) == 0 {
+			return nil
+		}
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return server.update(params.TextDocument.URI, text)
+	case "textDocument/didClose":
+		params := lspDidCloseParams{}
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return nil
+		}
+		delete(server.Documents, params.TextDocument.URI)
+		return server.notify("textDocument/publishDiagnostics", lspPublishDiagnosticsParams{
+			URI: params.TextDocument.URI, Diagnostics: []lspDiagnostic{},
+		})
+	}
+	return nil
+}
+
+func (server *lspServer) handleRequest(msg jsonRPCMessage) (interface{}, *jsonRPCError) {
+	switch msg.Method {
+	case "initialize":
+		return lspCapabilities, nil
+	case "shutdown":
+		server.Shutdown = true
+		return nil, nil
+	case "textDocument/completion":
+		return lspCompletion(), nil
+	case "textDocument/formatting":
+		params := lspFormattingParams{}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &jsonRPCError{Code: jsonRPCErrorInvalidParams, Message: err.Error()}
+		}
+		doc, ok := server.Documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return doc.formatting(), nil
+	case "textDocument/hover", "textDocument/definition", "textDocument/references":
+		params := lspPositionParams{}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &jsonRPCError{Code: jsonRPCErrorInvalidParams, Message: err.Error()}
+		}
+		doc, ok := server.Documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		switch msg.Method {
+		case "textDocument/hover":
+			return doc.hover(params.Position), nil
+		case "textDocument/definition":
+			return doc.definition(params.TextDocument.URI, params.Position), nil
+		default:
+			return doc.references(params.TextDocument.URI, params.Position, params.Context.IncludeDeclaration), nil
+		}
+	}
+	return nil, &jsonRPCError{
+		Code:    jsonRPCErrorMethodNotFound,
+		Message: fmt.Sprintf("unsupported method (%s)", msg.Method),
+	}
+}
+
+// update parses and checks the model at uri after the client opens or changes
+// it, and publishes the error found, if any, as a diagnostic.
+func (server *lspServer) update(uri string, text string) error {
+	doc := lspParse(uri, text)
+	server.Documents[uri] = doc
+	diagnostics := []lspDiagnostic{}
+	if doc.Err != nil {
+		diagnostics = append(diagnostics, doc.diagnostic())
+	}
+	return server.notify("textDocument/publishDiagnostics", lspPublishDiagnosticsParams{
+		URI: uri, Diagnostics: diagnostics,
+	})
+}
+
+func lspParse(uri string, text string) *lspDocument {
+	fileName := "model.vp"
+	u, err := url.Parse(uri)
+	if err == nil && len(path.Base(u.Path)) > 1 {
+		fileName = path.Base(u.Path)
+	}
+	doc := &lspDocument{
+		Text:    text,
+		Session: NewSessionWithOptions(Options{}),
+	}
+	doc.Model, doc.Err = doc.Session.ParseBytes(fileName, []byte(text))
+	if doc.Err != nil {
+		return doc
+	}
+	doc.Parsed = true
+	valKnowledgeMap, _, err := doc.Session.Sanity(doc.Model)
+	if err != nil {
+		doc.Err = err
+		return doc
+	}
+	doc.KnowledgeMap = valKnowledgeMap
+	return doc
+}
+
+func (doc *lspDocument) diagnostic() lspDiagnostic {
+	diagnostic := lspDiagnostic{
+		Severity: lspSeverityError,
+		Source:   "verifpal",
+		Message:  doc.Err.Error(),
+	}
+	var modelErr *ModelError
+	if !errors.As(doc.Err, &modelErr) || modelErr.Position.Line == 0 {
+		return diagnostic
+	}
+	diagnostic.Message = modelErr.Err.Error()
+	line, column := modelErr.Position.Line, modelErr.Position.Column
+	diagnostic.Range = lspRange{
+		Start: doc.position(line, column),
+		End:   doc.position(line, column+1),
+	}
+	for _, t := range lspTokens(doc.Text) {
+		if t.Line == line && t.Column == column {
+			diagnostic.Range = doc.tokenRange(t)
+			break
+		}
+	}
+	return diagnostic
+}
+
+func lspCompletion() []lspCompletionItem {
+	items := []lspCompletionItem{}
+	for _, p := range primitiveCoreSpecs {
+		items = append(items, lspCompletionItem{
+			Label:  p.Name,
+			Kind:   lspCompletionFunction,
+			Detail: lspCompletionDetail(p.Arity, p.Output, p.Check),
+		})
+	}
+	for _, p := range primitiveSpecs {
+		items = append(items, lspCompletionItem{
+			Label:  p.Name,
+			Kind:   lspCompletionFunction,
+			Detail: lspCompletionDetail(p.Arity, p.Output, p.Check),
+		})
+	}
+	return items
+}
+
+func lspCompletionDetail(arity []int, output []int, check bool) string {
+	detail := fmt.Sprintf("inputs: %s, outputs: %s", prettyArity(arity), prettyArity(output))
+	if check {
+		detail = detail + ", checkable with ?"
+	}
+	return detail
+}
+
+func (doc *lspDocument) hover(p lspPosition) interface{} {
+	t, principal, ok := doc.symbolAt(p)
+	if !ok || principal || doc.KnowledgeMap == nil {
+		return nil
+	}
+	name := strings.ToLower(t.Text)
+	i := -1
+	for ii, c := range doc.KnowledgeMap.Constants {
+		if c.Name == name {
+			i = ii
+			break
+		}
+	}
+	if i < 0 {
+		return nil
+	}
+	value := fmt.Sprintf("**%s**", name)
+	declaration, ok := doc.declaration(name)
+	if ok {
+		value = fmt.Sprintf("```\n%s\n```", strings.TrimSpace(prettyExpression(declaration)))
+	}
+	value = fmt.Sprintf(
+		"%s\n\nDeclared by %s.", value,
+		doc.Session.principalGetNameFromID(doc.KnowledgeMap.Creator[i]),
+	)
+	knownBy := []string{}
+	for _, m := range doc.KnowledgeMap.KnownBy[i] {
+		for knower, sender := range m {
+			if knower == sender {
+				knownBy = append(knownBy, doc.Session.principalGetNameFromID(knower))
+				continue
+			}
+			knownBy = append(knownBy, fmt.Sprintf(
+				"%s (from %s)",
+				doc.Session.principalGetNameFromID(knower),
+				doc.Session.principalGetNameFromID(sender),
+			))
+		}
+	}
+	if len(knownBy) > 0 {
+		value = fmt.Sprintf("%s\n\nAlso known by %s.", value, strings.Join(knownBy, ", "))
+	}
+	return lspHover{
+		Contents: lspMarkupContent{Kind: "markdown", Value: value},
+		Range:    doc.tokenRange(t),
+	}
+}
+
+func (doc *lspDocument) definition(uri string, p lspPosition) interface{} {
+	t, principal, ok := doc.symbolAt(p)
+	if !ok {
+		return nil
+	}
+	if principal {
+		for _, occurrence := range doc.principalOccurrences(t.Text) {
+			if occurrence.Declaration {
+				return lspLocation{URI: uri, Range: doc.tokenRange(occurrence.Token)}
+			}
+		}
+		return nil
+	}
+	declaration, ok := doc.declaration(strings.ToLower(t.Text))
+	if !ok {
+		return nil
+	}
+	for _, c := range declaration.Constants {
+		if c.Name == strings.ToLower(t.Text) {
+			return lspLocation{URI: uri, Range: doc.constantRange(c)}
+		}
+	}
+	return nil
+}
+
+func (doc *lspDocument) references(uri string, p lspPosition, includeDeclaration bool) interface{} {
+	t, principal, ok := doc.symbolAt(p)
+	if !ok {
+		return nil
+	}
+	locations := []lspLocation{}
+	if principal {
+		for _, occurrence := range doc.principalOccurrences(t.Text) {
+			if includeDeclaration || !occurrence.Declaration {
+				locations = append(locations, lspLocation{URI: uri, Range: doc.tokenRange(occurrence.Token)})
+			}
+		}
+		return locations
+	}
+	name := strings.ToLower(t.Text)
+	declared := Position{}
+	declaration, ok := doc.declaration(name)
+	if ok {
+		for _, c := range declaration.Constants {
+			if c.Name == name {
+				declared = c.Position
+			}
+		}
+	}
+	for _, c := range lspModelConstants(doc.Model) {
+		if c.Name != name || c.Position.Line == 0 {
+			continue
+		}
+		if includeDeclaration || c.Position != declared {
+			locations = append(locations, lspLocation{URI: uri, Range: doc.constantRange(c)})
+		}
+	}
+	return locations
+}
+
+func (doc *lspDocument) formatting() interface{} {
+	if !doc.Parsed {
+		return nil
+	}
+	formatted, err := doc.Session.PrettyModel(doc.Model)
+	if err != nil {
+		return nil
+	}
+	if formatted == doc.Text {
+		return []lspTextEdit{}
+	}
+	lines := strings.Split(doc.Text, "\n")
+	return []lspTextEdit{{
+		Range: lspRange{
+			Start: lspPosition{Line: 0, Character: 0},
+			End:   doc.position(len(lines), utf8.RuneCountInString(lines[len(lines)-1])+1),
+		},
+		NewText: formatted,
+	}}
+}
+
+// symbolAt returns the identifier at p, and whether it names a principal
+// rather than a constant.
+func (doc *lspDocument) symbolAt(p lspPosition) (lspToken, bool, bool) {
+	if !doc.Parsed {
+		return lspToken{}, false, false
+	}
+	tokens := lspTokens(doc.Text)
+	line, column := doc.column(p)
+	for k, t := range tokens {
+		if t.Line != line || column < t.Column || column > t.Column+utf8.RuneCountInString(t.Text) {
+			continue
+		}
+		if !lspIsIdentifier(t.Text) {
+			continue
+		}
+		return t, lspIsPrincipal(tokens, k), true
+	}
+	return lspToken{}, false, false
+}
+
+// declaration returns the first expression in which the constant called name is
+// declared, whether by being known, generated or assigned.
+func (doc *lspDocument) declaration(name string) (Expression, bool) {
+	for _, blck := range doc.Model.Blocks {
+		if blck.Kind != "principal" {
+			continue
+		}
+		for _, expr := range blck.Principal.Expressions {
+			if expr.Kind == typesEnumLeaks {
+				continue
+			}
+			for _, c := range expr.Constants {
+				if c.Name == name {
+					return expr, true
+				}
+			}
+		}
+	}
+	return Expression{}, false
+}
+
+// principalOccurrences returns the occurrences of the principal called name in
+// principal and message declarations, and in the messages of queries.
+func (doc *lspDocument) principalOccurrences(name string) []lspPrincipalOccurrence {
+	occurrences := []lspPrincipalOccurrence{}
+	declared := false
+	tokens := lspTokens(doc.Text)
+	for k, t := range tokens {
+		if !strings.EqualFold(t.Text, name) || !lspIsPrincipal(tokens, k) {
+			continue
+		}
+		declaration := !declared && k > 0 && strings.EqualFold(tokens[k-1].Text, "principal")
+		declared = declared || declaration
+		occurrences = append(occurrences, lspPrincipalOccurrence{Token: t, Declaration: declaration})
+	}
+	return occurrences
+}
+
+func (doc *lspDocument) constantRange(c *Constant) lspRange {
+	return lspRange{
+		Start: doc.position(c.Position.Line, c.Position.Column),
+		End:   doc.position(c.Position.Line, c.Position.Column+utf8.RuneCountInString(c.Name)),
+	}
+}
+
+func (doc *lspDocument) tokenRange(t lspToken) lspRange {
+	return lspRange{
+		Start: doc.position(t.Line, t.Column),
+		End:   doc.position(t.Line, t.Column+utf8.RuneCountInString(t.Text)),
+	}
+}
+
+// position converts a line and column of the model source into an LSP position,
+// whose character is counted in UTF-16 code units.
+func (doc *lspDocument) position(line int, column int) lspPosition {
+	lines := strings.Split(doc.Text, "\n")
+	if line < 1 || line > len(lines) {
+		return lspPosition{Line: max(line-1, 0), Character: 0}
+	}
+	character := 0
+	for i, r := range []rune(lines[line-1]) {
+		if i >= column-1 {
+			break
+		}
+		character = character + len(utf16.Encode([]rune{r}))
+	}
+	return lspPosition{Line: line - 1, Character: character}
+}
+
+// column converts an LSP position into a line and column of the model source.
+func (doc *lspDocument) column(p lspPosition) (int, int) {
+	lines := strings.Split(doc.Text, "\n")
+	if p.Line < 0 || p.Line >= len(lines) {
+		return p.Line + 1, 1
+	}
+	column, character := 1, 0
+	for _, r := range lines[p.Line] {
+		if character >= p.Character {
+			break
+		}
+		character = character + len(utf16.Encode([]rune{r}))
+		column = column + 1
+	}
+	return p.Line + 1, column
+}
+
+// lspTokens splits the source of a model into identifiers, arrows and
+// other symbols, leaving out whitespace and comments.
+func lspTokens(text string) []lspToken {
+	tokens := []lspToken{}
+	line, column := 1, 1
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\n':
+			line, column = line+1, 1
+			i = i + size
+			continue
+		case strings.HasPrefix(text[i:], "//"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			column = column + utf8.RuneCountInString(text[i:i+end])
+			i = i + end
+			continue
+		case r == ' ' || r == '\t' || r == '\r':
+			column, i = column+1, i+size
+			continue
+		}
+		end := i + size
+		switch {
+		case lspIsIdentifier(string(r)):
+			for end < len(text) && lspIsIdentifier(text[end:end+1]) {
+				end = end + 1
+			}
+		case strings.HasPrefix(text[i:], "->"):
+			end = i + 2
+		}
+		tokens = append(tokens, lspToken{Text: text[i:end], Offset: i, Line: line, Column: column})
+		column = column + utf8.RuneCountInString(text[i:end])
+		i = end
+	}
+	return tokens
+}
+
+func lspIsIdentifier(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// lspIsPrincipal reports whether the identifier tokens[k] names a principal, which
+// is the case when it follows the principal keyword or an arrow, or precedes an arrow.
+func lspIsPrincipal(tokens []lspToken, k int) bool {
+	if k > 0 {
+		switch strings.ToLower(tokens[k-1].Text) {
+		case "principal", "->", "→":
+			return true
+		}
+	}
+	if k+1 < len(tokens) {
+		switch tokens[k+1].Text {
+		case "->", "→":
+			return true
+		}
+	}
+	return false
+}
+
+// lspModelConstants returns every constant appearing in a model, in the order
+// in which they appear.
+func lspModelConstants(m Model) []*Constant {
+	constants := []*Constant{}
+	for _, blck := range m.Blocks {
+		switch blck.Kind {
+		case "principal":
+			for _, expr := range blck.Principal.Expressions {
+				constants = append(constants, expr.Constants...)
+				if expr.Assigned != nil {
+					constants = lspValueConstants(expr.Assigned, constants)
+				}
+			}
+		case "message":
+			constants = append(constants, blck.Message.Constants...)
+		}
+	}
+	for _, query := range m.Queries {
+		constants = append(constants, query.Constants...)
+		constants = append(constants, query.Message.Constants...)
+		for _, option := range query.Options {
+			constants = append(constants, option.Message.Constants...)
+		}
+	}
+	return constants
+}
+
+func lspValueConstants(a *Value, constants []*Constant) []*Constant {
+	switch a.Kind {
+	case typesEnumConstant:
+		constants = append(constants, a.Data.(*Constant))
+	case typesEnumPrimitive:
+		for _, v := range a.Data.(*Primitive).Arguments {
+			constants = lspValueConstants(v, constants)
+		}
+	case typesEnumEquation:
+		for _, v := range a.Data.(*Equation).Values {
+			constants = lspValueConstants(v, constants)
+		}
+	}
+	return constants
+}
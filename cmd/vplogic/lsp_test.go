@@ -0,0 +1,144 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLSP(t *testing.T) {
+	uri := "file:///models/lsp.vp"
+	lines := []string{
+		"attacker[active]",
+		"principal Alice[",
+		"	knows public ad",
+		"	knows private k",
+		"	generates m",
+		"	c = AEAD_ENC(k, m, ad)",
+		"]",
+		"Alice -> Bob: c",
+		"principal Bob[",
+		"	knows public ad",
+		"	knows private k",
+		"	m2 = AEAD_DEC(k, c, ad)?",
+		"]",
+		"queries[",
+		"	confidentiality? m",
+		"	authentication? Alice -> Bob: c",
+		"]",
+	}
+	text := strings.Join(lines, "\n")
+	at := func(line int, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri},
+			"position":     map[string]int{"line": line, "character": strings.LastIndex(lines[line], name)},
+			"context":      map[string]bool{"includeDeclaration": true},
+		}
+	}
+	var input bytes.Buffer
+	for i, msg := range []struct {
+		method string
+		params interface{}
+	}{
+		{"initialize", map[string]interface{}{}},
+		{"textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri, "languageId": "verifpal", "text": text},
+		}},
+		{"textDocument/hover", at(7, "c")},
+		{"textDocument/definition", at(11, "c,")},
+		{"textDocument/references", at(7, "c")},
+		{"textDocument/definition", at(15, "Bob")},
+		{"textDocument/references", at(7, "Bob")},
+		{"textDocument/completion", at(5, "AEAD_ENC")},
+		{"textDocument/formatting", map[string]interface{}{"textDocument": map[string]string{"uri": uri}}},
+		{"textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]string{"uri": uri},
+			"contentChanges": []map[string]string{{"text": strings.Replace(text, "generates m", "generates k", 1)}},
+		}},
+		{"shutdown", nil},
+		{"exit", nil},
+	} {
+		request := map[string]interface{}{"jsonrpc": "2.0", "method": msg.method, "params": msg.params}
+		if !strings.HasPrefix(msg.method, "textDocument/did") && msg.method != "exit" {
+			request["id"] = i
+		}
+		body, _ := json.Marshal(request)
+		fmt.Fprintf(&input, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var output bytes.Buffer
+	err := LSP(&input, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := map[float64]json.RawMessage{}
+	diagnostics := []lspPublishDiagnosticsParams{}
+	for _, message := range regexp.MustCompile(`Content-Length: \d+\r\n\r\n`).Split(output.String(), -1)[1:] {
+		decoded := struct {
+			ID     *float64
+			Result json.RawMessage
+			Params lspPublishDiagnosticsParams
+		}{}
+		err = json.Unmarshal([]byte(message), &decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.ID == nil {
+			diagnostics = append(diagnostics, decoded.Params)
+			continue
+		}
+		responses[*decoded.ID] = decoded.Result
+	}
+	if len(diagnostics) != 2 || len(diagnostics[0].Diagnostics) != 0 || len(diagnostics[1].Diagnostics) != 1 {
+		t.Fatalf("expected no diagnostics and then one, got %v", diagnostics)
+	}
+	diagnostic := diagnostics[1].Diagnostics[0]
+	if diagnostic.Message != "generated constant already exists (k)" ||
+		diagnostic.Range != (lspRange{Start: lspPosition{4, 11}, End: lspPosition{4, 12}}) {
+		t.Errorf("expected a diagnostic for the generated constant, got %v", diagnostic)
+	}
+	hover := lspHover{}
+	_ = json.Unmarshal(responses[2], &hover)
+	for _, expected := range []string{"c = AEAD_ENC(k, m, ad)", "Declared by Alice.", "Bob (from Alice)"} {
+		if !strings.Contains(hover.Contents.Value, expected) {
+			t.Errorf("expected hover to contain %q, got %q", expected, hover.Contents.Value)
+		}
+	}
+	for id, expected := range map[float64]string{
+		3: `{"uri":"file:///models/lsp.vp","range":{"start":{"line":5,"character":1},"end":{"line":5,"character":2}}}`,
+		5: `{"uri":"file:///models/lsp.vp","range":{"start":{"line":8,"character":10},"end":{"line":8,"character":13}}}`,
+	} {
+		if string(responses[id]) != expected {
+			t.Errorf("expected definition %s, got %s", expected, responses[id])
+		}
+	}
+	for id, expected := range map[float64][]int{4: {5, 7, 11, 15}, 6: {7, 8, 15}} {
+		references := []lspLocation{}
+		_ = json.Unmarshal(responses[id], &references)
+		found := []int{}
+		for _, reference := range references {
+			found = append(found, reference.Range.Start.Line)
+		}
+		if fmt.Sprint(found) != fmt.Sprint(expected) {
+			t.Errorf("expected references on lines %v, got %v", expected, found)
+		}
+	}
+	if !strings.Contains(string(responses[7]), `{"label":"AEAD_ENC","kind":3,"detail":"inputs: 3, outputs: 1"}`) {
+		t.Errorf("expected completion of AEAD_ENC, got %s", responses[7])
+	}
+	edits := []lspTextEdit{}
+	_ = json.Unmarshal(responses[8], &edits)
+	if len(edits) != 1 || !strings.HasPrefix(edits[0].NewText, "attacker[active]\n\nprincipal Alice[") ||
+		edits[0].Range.End != (lspPosition{16, 1}) {
+		t.Errorf("expected the model to be formatted, got %v", edits)
+	}
+	if string(responses[10]) != "null" {
+		t.Errorf("expected a null result to shutdown, got %s", responses[10])
+	}
+}
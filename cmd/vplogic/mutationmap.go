@@ -5,11 +5,12 @@
 package vplogic
 
 import (
+	"context"
 	"fmt"
 )
 
-func mutationMapInit(
-	valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
+func (s *Session) mutationMapInit(
+	ctx context.Context, valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
 	valAttackerState AttackerState, stage int,
 ) (MutationMap, error) {
 	var err error
@@ -21,10 +22,13 @@ func mutationMapInit(
 		Combination:    []*Value{},
 		DepthIndex:     []int{},
 	}
-	InfoMessage(fmt.Sprintf(
+	s.infoMessage(fmt.Sprintf(
 		"Initializing Stage %d mutation map for %s...", stage, valPrincipalState.Name,
 	), "analysis", false)
 	for _, v := range valAttackerState.Known {
+		if ctx.Err() != nil {
+			return MutationMap{}, ctx.Err()
+		}
 		switch v.Kind {
 		case typesEnumPrimitive:
 			continue
@@ -36,7 +40,7 @@ func mutationMapInit(
 			continue
 		}
 		var r []*Value
-		r, err = mutationMapReplaceValue(a, i, stage, valPrincipalState, valAttackerState)
+		r, err = s.mutationMapReplaceValue(ctx, a, i, stage, valPrincipalState, valAttackerState)
 		if err != nil {
 			return MutationMap{}, err
 		}
@@ -48,9 +52,17 @@ func mutationMapInit(
 	}
 	valMutationMap.Combination = make([]*Value, len(valMutationMap.Constants))
 	valMutationMap.DepthIndex = make([]int, len(valMutationMap.Constants))
+	mutations := 0
 	for ii := 0; ii < len(valMutationMap.Constants); ii++ {
 		valMutationMap.DepthIndex[ii] = 0
-	}
+		mutations = mutations + len(valMutationMap.Mutations[ii])
+	}
+	s.observe(EventMutationMapInitialized{
+		Stage:     stage,
+		Principal: valPrincipalState.Name,
+		Constants: len(valMutationMap.Constants),
+		Mutations: mutations,
+	})
 	return valMutationMap, err
 }
 
@@ -75,8 +87,8 @@ func mutationMapSkipValue(
 	return false
 }
 
-func mutationMapReplaceValue(
-	a *Value, rootIndex int, stage int,
+func (s *Session) mutationMapReplaceValue(
+	ctx context.Context, a *Value, rootIndex int, stage int,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) ([]*Value, error) {
 	a, err := valueResolveValueInternalValuesFromPrincipalState(
@@ -91,10 +103,10 @@ func mutationMapReplaceValue(
 			a, stage, valPrincipalState, valAttackerState,
 		), nil
 	case typesEnumPrimitive:
-		p := mutationMapReplacePrimitive(
-			a, stage, valPrincipalState, valAttackerState,
+		p := s.mutationMapReplacePrimitive(
+			ctx, a, stage, valPrincipalState, valAttackerState,
 		)
-		return p, err
+		return p, ctx.Err()
 	case typesEnumEquation:
 		return mutationMapReplaceEquation(
 			a, stage, valAttackerState,
@@ -133,12 +145,15 @@ func mutationMapReplaceConstant(
 	return mutations
 }
 
-func mutationMapReplacePrimitive(
-	a *Value, stage int,
+func (s *Session) mutationMapReplacePrimitive(
+	ctx context.Context, a *Value, stage int,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) []*Value {
 	mutations := []*Value{}
 	for _, v := range valAttackerState.Known {
+		if ctx.Err() != nil {
+			return mutations
+		}
 		switch v.Kind {
 		case typesEnumConstant:
 			if valueIsGOrNil(v.Data.(*Constant)) {
@@ -160,12 +175,15 @@ func mutationMapReplacePrimitive(
 			}
 		}
 	}
-	injectants := inject(
+	injectants := s.inject(
 		a.Data.(*Primitive), 0,
 		valPrincipalState, valAttackerState, stage,
 	)
 	uinjectants := []*Value{}
 	for _, a := range injectants {
+		if ctx.Err() != nil {
+			return mutations
+		}
 		if valueEquivalentValueInValues(a, uinjectants) < 0 {
 			uinjectants = append(uinjectants, a)
 			mutations = append(mutations, a)
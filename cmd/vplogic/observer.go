@@ -0,0 +1,181 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/logrusorgru/aurora"
+)
+
+// Kind returns "message".
+func (e EventMessage) Kind() string {
+	return "message"
+}
+
+// Kind returns "stageStarted".
+func (e EventStageStarted) Kind() string {
+	return "stageStarted"
+}
+
+// Kind returns "mutationMapInitialized".
+func (e EventMutationMapInitialized) Kind() string {
+	return "mutationMapInitialized"
+}
+
+// Kind returns "attackerLearned".
+func (e EventAttackerLearned) Kind() string {
+	return "attackerLearned"
+}
+
+// Kind returns "queryResolved".
+func (e EventQueryResolved) Kind() string {
+	return "queryResolved"
+}
+
+// Kind returns "analysis".
+func (e EventAnalysis) Kind() string {
+	return "analysis"
+}
+
+// Kind returns "verificationCompleted".
+func (e EventVerificationCompleted) Kind() string {
+	return "verificationCompleted"
+}
+
+func (s *Session) observe(event Event) {
+	for _, o := range s.observers {
+		o.Observe(event)
+	}
+}
+
+// NewTerminalObserver returns an Observer which writes events as the status
+// messages shown by the Verifpal command-line interface, in color or not,
+// and filtered according to the given Verbosity.
+func NewTerminalObserver(w io.Writer, color bool, verbosity Verbosity) Observer {
+	return &terminalObserver{
+		output:    w,
+		color:     color,
+		verbosity: verbosity,
+	}
+}
+
+// Observe writes the status messages corresponding to an event.
+func (o *terminalObserver) Observe(event Event) {
+	o.mutex.Lock()
+	switch e := event.(type) {
+	case EventMessage:
+		o.message(e.Message, e.Type, e.AnalysisCount)
+	case EventAttackerLearned:
+		o.message(e.Message, "deduction", e.AnalysisCount)
+	case EventQueryResolved:
+		o.message(fmt.Sprintf(
+			"%s — %s", e.Query, e.Result.Summary,
+		), "result", e.AnalysisCount)
+	case EventAnalysis:
+		o.analysis(e.Stage, e.AnalysisCount)
+	case EventVerificationCompleted:
+		o.verificationCompleted(e)
+	}
+	o.mutex.Unlock()
+}
+
+func (o *terminalObserver) message(m string, t string, analysisCount int) {
+	if o.verbosity < VerbosityProgress && t != "result" && t != "warning" {
+		return
+	}
+	if o.color {
+		infoMessageColor(o.output, m, t, analysisCount)
+	} else {
+		infoMessageRegular(o.output, m, t, analysisCount)
+	}
+}
+
+func (o *terminalObserver) analysis(stage int, analysisCount int) {
+	a := ""
+	st := ""
+	if o.verbosity < VerbosityAnalysis {
+		return
+	}
+	switch {
+	case analysisCount > 100000:
+		if analysisCount%10000 != 0 {
+			return
+		}
+	case analysisCount > 10000:
+		if analysisCount%1000 != 0 {
+			return
+		}
+	case analysisCount > 1000:
+		if analysisCount%100 != 0 {
+			return
+		}
+	case analysisCount > 100:
+		if analysisCount%10 != 0 {
+			return
+		}
+	}
+	switch {
+	case stage == 1:
+		st = "1"
+	case stage == 2 || stage == 3:
+		st = "2-3"
+	case stage == 4 || stage == 5:
+		st = "4-5"
+	default:
+		st = fmt.Sprintf("%d", stage)
+	}
+	if o.color {
+		a = aurora.Faint(fmt.Sprintf(
+			" Stage %s, Analysis %d...", st, analysisCount,
+		)).Italic().String()
+	} else {
+		a = fmt.Sprintf(" Stage %s, Analysis %d...", st, analysisCount)
+	}
+	fmt.Fprint(o.output, a)
+	fmt.Fprint(o.output, "\r\r\r\r")
+}
+
+func (o *terminalObserver) verificationCompleted(e EventVerificationCompleted) {
+	r := e.Report
+	if o.verbosity >= VerbosityProgress {
+		fmt.Fprint(o.output, "\n\n")
+	}
+	completed := "completed"
+	if r.Interrupted {
+		completed = "stopped"
+	}
+	o.message(fmt.Sprintf(
+		"Verification %s for '%s' at %s.",
+		completed, r.Model, r.Completed.Format("03:04:05 PM"),
+	), "verifpal", 0)
+	if r.Interrupted {
+		o.message("Verification stopped before completion: results are partial.", "warning", 0)
+	}
+	switch r.Status {
+	case VerifyStatusAttackFound:
+		o.message("Summary of failed queries will follow.", "verifpal", 0)
+	case VerifyStatusVerified:
+		o.message("All queries pass.", "verifpal", 0)
+	}
+	if o.verbosity >= VerbosityProgress {
+		fmt.Fprint(o.output, "\n")
+	}
+	for i, verifyResult := range e.Results {
+		switch verifyResult.Status {
+		case VerifyStatusAttackFound:
+			o.message(fmt.Sprintf("%s — %s",
+				r.Queries[i].Query, verifyResult.Summary,
+			), "result", 0)
+		case VerifyStatusInconclusive:
+			o.message(fmt.Sprintf(
+				"%s — Inconclusive: no attack was found before analysis stopped at stage %d.",
+				r.Queries[i].Query, verifyResult.Stage,
+			), "result", 0)
+		}
+	}
+	o.message("Thank you for using Verifpal.", "verifpal", 0)
+}
@@ -4,7 +4,7 @@
 
 package vplogic
 
-func possibleToDecomposePrimitive(
+func (s *Session) possibleToDecomposePrimitive(
 	p *Primitive, valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) (bool, *Value, []*Value) {
 	has := []*Value{}
@@ -21,19 +21,19 @@ func possibleToDecomposePrimitive(
 		if !valid {
 			continue
 		}
-		ii := valueEquivalentValueInValues(a, valAttackerState.Known)
+		ii := attackerStateKnownIndex(valAttackerState, a)
 		if ii >= 0 {
 			has = append(has, a)
 			continue
 		}
 		switch a.Kind {
 		case typesEnumPrimitive:
-			r, _ := possibleToReconstructPrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
+			r, _ := s.possibleToReconstructPrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
 			if r {
 				has = append(has, a)
 				continue
 			}
-			r, _, _ = possibleToDecomposePrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
+			r, _, _ = s.possibleToDecomposePrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
 			if r {
 				has = append(has, a)
 				continue
@@ -88,27 +88,27 @@ func possibleToRecomposePrimitive(
 	return false, &Value{}, []*Value{}
 }
 
-func possibleToReconstructPrimitive(
+func (s *Session) possibleToReconstructPrimitive(
 	p *Primitive, valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) (bool, []*Value) {
 	has := []*Value{}
-	r, _ := possibleToRewrite(p, valPrincipalState)
+	r, _ := s.possibleToRewrite(p, valPrincipalState)
 	if !r {
 		return false, []*Value{}
 	}
 	for _, a := range p.Arguments {
-		if valueEquivalentValueInValues(a, valAttackerState.Known) >= 0 {
+		if attackerStateKnownIndex(valAttackerState, a) >= 0 {
 			has = append(has, a)
 			continue
 		}
 		switch a.Kind {
 		case typesEnumPrimitive:
-			r, _, _ = possibleToDecomposePrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
+			r, _, _ = s.possibleToDecomposePrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
 			if r {
 				has = append(has, a)
 				continue
 			}
-			r, _ = possibleToReconstructPrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
+			r, _ = s.possibleToReconstructPrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
 			if r {
 				has = append(has, a)
 				continue
@@ -129,15 +129,15 @@ func possibleToReconstructPrimitive(
 
 func possibleToReconstructEquation(e *Equation, valAttackerState AttackerState) (bool, []*Value) {
 	if len(e.Values) <= 2 {
-		if valueEquivalentValueInValues(e.Values[1], valAttackerState.Known) >= 0 {
+		if attackerStateKnownIndex(valAttackerState, e.Values[1]) >= 0 {
 			return true, []*Value{e.Values[1]}
 		}
 		return false, []*Value{}
 	}
 	s0 := e.Values[1]
 	s1 := e.Values[2]
-	hs0 := valueEquivalentValueInValues(s0, valAttackerState.Known) >= 0
-	hs1 := valueEquivalentValueInValues(s1, valAttackerState.Known) >= 0
+	hs0 := attackerStateKnownIndex(valAttackerState, s0) >= 0
+	hs1 := attackerStateKnownIndex(valAttackerState, s1) >= 0
 	if hs0 && hs1 {
 		return true, []*Value{s0, s1}
 	}
@@ -153,52 +153,44 @@ func possibleToReconstructEquation(e *Equation, valAttackerState AttackerState)
 			Values: []*Value{e.Values[0], e.Values[2]},
 		},
 	}
-	hp1 := valueEquivalentValueInValues(p1, valAttackerState.Known) >= 0
+	hp1 := attackerStateKnownIndex(valAttackerState, p1) >= 0
 	if hs0 && hp1 {
 		return true, []*Value{s0, p1}
 	}
-	hp0 := valueEquivalentValueInValues(p0, valAttackerState.Known) >= 0
+	hp0 := attackerStateKnownIndex(valAttackerState, p0) >= 0
 	if hp0 && hs1 {
 		return true, []*Value{p0, s1}
 	}
 	return false, []*Value{}
 }
 
-func possibleToRewrite(
+func (s *Session) possibleToRewrite(
 	p *Primitive, valPrincipalState *PrincipalState,
 ) (bool, []*Value) {
-	v := []*Value{{Kind: typesEnumPrimitive, Data: p}}
 	switch p.ID {
 	case primitiveEnumGROUPADD:
-		return rewriteGroupAddPrimitive(p)
+		return s.rewriteGroupAddPrimitive(p)
 	case primitiveEnumNEG:
-		return rewriteNegPrimitive(p)
+		return s.rewriteNegPrimitive(p)
 	case primitiveEnumXOR:
 		return rewriteXorPrimitive(p)
 	case primitiveEnumPEDERSENCOMMIT:
-		return rewritePedersenCommit(p)
+		return s.rewritePedersenCommit(p)
 	case primitiveEnumSCALARNEG:
-		return rewriteScalarNegPrimitive(p)
+		return s.rewriteScalarNegPrimitive(p)
 	case primitiveEnumSCALARADD:
-		return rewriteScalarAddPrimitive(p)
-	}
-	for i, a := range p.Arguments {
-		switch a.Kind {
-		case typesEnumPrimitive:
-			_, pp := possibleToRewrite(a.Data.(*Primitive), valPrincipalState)
-			p.Arguments[i] = pp[0]
-		}
+		return s.rewriteScalarAddPrimitive(p)
 	}
+	p = s.possibleToRewriteArguments(p, valPrincipalState)
+	v := []*Value{{Kind: typesEnumPrimitive, Data: p}}
 	if primitiveIsCorePrimitive(p.ID) {
 		prim, _ := primitiveCoreGet(p.ID)
-		p.Check = p.Check || prim.Check
 		if prim.HasRule {
 			return prim.CoreRule(p)
 		}
 		return !prim.Check, v
 	}
 	prim, _ := primitiveGet(p.ID)
-	p.Check = p.Check || prim.Check
 	if !prim.Rewrite.HasRule {
 		return true, v
 	}
@@ -208,7 +200,7 @@ func possibleToRewrite(
 		if from.Data.(*Primitive).ID != prim.Rewrite.ID {
 			return !prim.Check, v
 		}
-		if !possibleToRewritePrimitive(p, valPrincipalState) {
+		if !s.possibleToRewritePrimitive(p, valPrincipalState) {
 			return !prim.Check, v
 		}
 		rewrite := prim.Rewrite.To(from.Data.(*Primitive))
@@ -217,7 +209,50 @@ func possibleToRewrite(
 	return !prim.Check, v
 }
 
-func possibleToRewritePrimitive(
+// possibleToRewriteArguments returns p with its primitive arguments rewritten
+// and its Check flag set if its definition requires it. Since p may be shared
+// with other principal states and with the attacker's knowledge, it is never
+// modified: a copy is returned instead whenever anything changes.
+func (s *Session) possibleToRewriteArguments(p *Primitive, valPrincipalState *PrincipalState) *Primitive {
+	check := p.Check
+	if primitiveIsCorePrimitive(p.ID) {
+		prim, _ := primitiveCoreGet(p.ID)
+		check = check || prim.Check
+	} else {
+		prim, _ := primitiveGet(p.ID)
+		check = check || prim.Check
+	}
+	var arguments []*Value
+	for i, a := range p.Arguments {
+		switch a.Kind {
+		case typesEnumPrimitive:
+			_, pp := s.possibleToRewrite(a.Data.(*Primitive), valPrincipalState)
+			if pp[0].Kind == typesEnumPrimitive && pp[0].Data.(*Primitive) == a.Data.(*Primitive) {
+				continue
+			}
+			if arguments == nil {
+				arguments = make([]*Value, len(p.Arguments))
+				copy(arguments, p.Arguments)
+			}
+			arguments[i] = pp[0]
+		}
+	}
+	if arguments == nil && check == p.Check {
+		return p
+	}
+	if arguments == nil {
+		arguments = p.Arguments
+	}
+	return &Primitive{
+		ID:        p.ID,
+		Arguments: arguments,
+		Output:    p.Output,
+		Check:     check,
+		Position:  p.Position,
+	}
+}
+
+func (s *Session) possibleToRewritePrimitive(
 	p *Primitive, valPrincipalState *PrincipalState,
 ) bool {
 	prim, _ := primitiveGet(p.ID)
@@ -233,20 +268,23 @@ func possibleToRewritePrimitive(
 			for i := range ax {
 				switch ax[i].Kind {
 				case typesEnumPrimitive:
-					r, v := possibleToRewrite(ax[i].Data.(*Primitive), valPrincipalState)
+					r, v := s.possibleToRewrite(ax[i].Data.(*Primitive), valPrincipalState)
 					if r {
 						ax[i] = v[0]
 					}
 				case typesEnumEquation:
-					for ii, a := range ax[i].Data.(*Equation).Values {
+					values := make([]*Value, len(ax[i].Data.(*Equation).Values))
+					copy(values, ax[i].Data.(*Equation).Values)
+					for ii, a := range values {
 						switch a.Kind {
 						case typesEnumPrimitive:
-							r, v := possibleToRewrite(a.Data.(*Primitive), valPrincipalState)
+							r, v := s.possibleToRewrite(a.Data.(*Primitive), valPrincipalState)
 							if r {
-								ax[i].Data.(*Equation).Values[ii] = v[0]
+								values[ii] = v[0]
 							}
 						}
 					}
+					ax[i] = &Value{Kind: typesEnumEquation, Data: &Equation{Values: values}}
 				}
 			}
 			valid = valueEquivalentValues(ax[0], ax[1], true)
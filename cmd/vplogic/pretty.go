@@ -13,11 +13,12 @@ import (
 
 // PrettyPrint pretty-prints a Verifpal model based on a model loaded from a file.
 func PrettyPrint(modelFile string) error {
-	m, err := libpegParseModel(modelFile, false)
+	s := NewSession()
+	m, err := s.libpegParseModel(modelFile, false)
 	if err != nil {
 		return err
 	}
-	pretty, err := PrettyModel(m)
+	pretty, err := s.PrettyModel(m)
 	if err != nil {
 		return err
 	}
@@ -25,6 +26,22 @@ func PrettyPrint(modelFile string) error {
 	return nil
 }
 
+// PrettyModel pretty-prints a Verifpal model that has already
+// been parsed into the Model struct, within a new Session.
+func PrettyModel(m Model) (string, error) {
+	s := NewSession()
+	s.modelAdopt(m)
+	return s.PrettyModel(m)
+}
+
+// PrettyDiagram generates a sequence diagram format based on a Verifpal model,
+// within a new Session.
+func PrettyDiagram(m Model) (string, error) {
+	s := NewSession()
+	s.modelAdopt(m)
+	return s.PrettyDiagram(m)
+}
+
 func prettyConstant(c *Constant) string {
 	if c.Guard {
 		return fmt.Sprintf("[%s]", c.Name)
@@ -193,7 +210,11 @@ func prettyValues(a []*Value) string {
 	return pretty
 }
 
-func prettyQuery(query Query) string {
+func (s *Session) prettyQuery(query Query) string {
+	return s.prettyQueryWithComments(query, false)
+}
+
+func (s *Session) prettyQueryWithComments(query Query, comments bool) string {
 	output := ""
 	switch query.Kind {
 	case typesEnumConfidentiality:
@@ -204,8 +225,8 @@ func prettyQuery(query Query) string {
 	case typesEnumAuthentication:
 		output = fmt.Sprintf(
 			"authentication? %s -> %s: %s",
-			principalGetNameFromID(query.Message.Sender),
-			principalGetNameFromID(query.Message.Recipient),
+			s.principalGetNameFromID(query.Message.Sender),
+			s.principalGetNameFromID(query.Message.Recipient),
 			prettyConstants(query.Message.Constants),
 		)
 	case typesEnumFreshness:
@@ -230,12 +251,20 @@ func prettyQuery(query Query) string {
 	for _, option := range query.Options {
 		switch option.Kind {
 		case typesEnumPrecondition:
+			for _, comment := range option.Comments.Leading {
+				if comments {
+					output = fmt.Sprintf("%s\n\t\t//%s", output, comment.Text)
+				}
+			}
 			output = fmt.Sprintf(
 				"%s\n\t\tprecondition[%s -> %s: %s]",
-				output, principalGetNameFromID(option.Message.Sender),
-				principalGetNameFromID(option.Message.Recipient),
+				output, s.principalGetNameFromID(option.Message.Sender),
+				s.principalGetNameFromID(option.Message.Recipient),
 				prettyConstants(option.Message.Constants),
 			)
+			if comments {
+				output = output + prettyTrailingComments(option.Comments)
+			}
 		}
 	}
 	if len(query.Options) > 0 {
@@ -251,11 +280,16 @@ func prettyPrincipal(block Block) string {
 	)
 	for _, expression := range block.Principal.Expressions {
 		output = fmt.Sprintf(
-			"%s\t%s\n",
-			output, prettyExpression(expression),
+			"%s%s\t%s%s\n",
+			output, prettyComments(expression.Comments.Leading, "\t"),
+			prettyExpression(expression), prettyTrailingComments(expression.Comments),
 		)
 	}
-	output = fmt.Sprintf("%s]\n\n", output)
+	output = fmt.Sprintf(
+		"%s%s]%s\n\n",
+		output, prettyComments(block.Principal.Comments, "\t"),
+		prettyTrailingComments(block.Comments),
+	)
 	return output
 }
 
@@ -308,11 +342,11 @@ func prettyExpression(expression Expression) string {
 	return output
 }
 
-func prettyMessage(block Block) string {
+func (s *Session) prettyMessage(block Block) string {
 	output := fmt.Sprintf(
 		"%s -> %s: %s",
-		principalGetNameFromID(block.Message.Sender),
-		principalGetNameFromID(block.Message.Recipient),
+		s.principalGetNameFromID(block.Message.Sender),
+		s.principalGetNameFromID(block.Message.Recipient),
 		prettyConstants(block.Message.Constants),
 	)
 	return output
@@ -320,78 +354,95 @@ func prettyMessage(block Block) string {
 
 func prettyPhase(block Block) string {
 	output := fmt.Sprintf(
-		"phase[%d]\n\n",
-		block.Phase.Number,
+		"phase[%d]%s\n\n",
+		block.Phase.Number, prettyTrailingComments(block.Comments),
 	)
 	return output
 }
 
+func prettyComments(comments []Comment, indent string) string {
+	output := ""
+	for _, comment := range comments {
+		output = fmt.Sprintf(
+			"%s%s//%s\n",
+			output, indent, comment.Text,
+		)
+	}
+	return output
+}
+
+// prettyMLComment formats a comment with the (* *) syntax of ProVerif and Coq.
+// Since these comments nest, both delimiters are broken up within the comment.
+func prettyMLComment(comment Comment) string {
+	text := strings.ReplaceAll(comment.Text, "(*", "( *")
+	return fmt.Sprintf("(*%s *)", strings.ReplaceAll(text, "*)", "* )"))
+}
+
+func prettyTrailingComments(trivia Trivia) string {
+	output := ""
+	for _, comment := range trivia.Trailing {
+		output = fmt.Sprintf(
+			"%s //%s",
+			output, comment.Text,
+		)
+	}
+	return output
+}
+
 // PrettyModel pretty-prints a Verifpal model that has already
-// been parsed into the Model struct.
-func PrettyModel(m Model) (string, error) {
-	_, _, err := sanity(m)
+// been parsed into the Model struct within this Session.
+func (s *Session) PrettyModel(m Model) (string, error) {
+	_, _, err := s.sanity(m)
 	if err != nil {
 		return "", err
 	}
-	output := fmt.Sprintf(
-		"attacker[%s]\n\n",
-		m.Attacker,
+	output := prettyComments(m.Comments.Leading, "")
+	if len(m.Comments.Leading) > 0 {
+		output = output + "\n"
+	}
+	output = fmt.Sprintf(
+		"%sattacker[%s]\n\n",
+		output, m.Attacker,
 	)
 	for _, block := range m.Blocks {
+		output = output + prettyComments(block.Comments.Leading, "")
 		switch block.Kind {
 		case "principal":
 			output = output + prettyPrincipal(block)
 		case "message":
-			output = output + prettyMessage(block) + "\n\n"
+			output = output + s.prettyMessage(block) + prettyTrailingComments(block.Comments) + "\n\n"
 		case "phase":
 			output = output + prettyPhase(block)
 		}
 	}
-	output = fmt.Sprintf("%squeries[\n", output)
+	output = fmt.Sprintf("%s%squeries[\n", output, prettyComments(m.QueriesComments.Leading, ""))
 	for _, query := range m.Queries {
 		output = fmt.Sprintf(
-			"%s\t%s\n", output, prettyQuery(query),
+			"%s%s\t%s%s%s\n", output, prettyComments(query.Comments.Leading, "\t"),
+			s.prettyQueryWithComments(query, true), prettyQueryExpect(query), prettyTrailingComments(query.Comments),
 		)
 	}
-	output = fmt.Sprintf("%s]\n", output)
+	output = fmt.Sprintf("%s%s]\n", output, prettyComments(m.QueriesComments.Trailing, "\t"))
+	if len(m.Comments.Trailing) > 0 {
+		output = output + "\n" + prettyComments(m.Comments.Trailing, "")
+	}
 	return output, nil
 }
 
-// PrettyDiagram generates a sequence diagram format based on a Verifpal model.
-func PrettyDiagram(m Model) (string, error) {
-	_, _, err := sanity(m)
-	if err != nil {
-		return "", err
-	}
-	output := ""
-	firstPrincipal := ""
-	for _, block := range m.Blocks {
-		switch block.Kind {
-		case "principal":
-			output = fmt.Sprintf(
-				"%sNote over %s: ",
-				output, block.Principal.Name,
-			)
-			if len(firstPrincipal) == 0 {
-				firstPrincipal = block.Principal.Name
-			}
-			for _, expression := range block.Principal.Expressions {
-				output = fmt.Sprintf(
-					"%s\t%s\\n",
-					output, prettyExpression(expression),
-				)
-			}
-			output = fmt.Sprintf("%s\n", output)
-		case "message":
-			output = output + prettyMessage(block) + "\n"
-		case "phase":
-			output = fmt.Sprintf(
-				"%sNote left of %s:phase %d\n",
-				output, firstPrincipal, block.Phase.Number,
-			)
-		}
+func prettyQueryExpect(query Query) string {
+	switch query.Expect {
+	case typesEnumPass:
+		return " // expect: pass"
+	case typesEnumFail:
+		return " // expect: fail"
 	}
-	return output, nil
+	return ""
+}
+
+// PrettyDiagram generates a sequence diagram format based on a Verifpal model,
+// in the seqdiag format of Diagram.
+func (s *Session) PrettyDiagram(m Model) (string, error) {
+	return s.Diagram(m, "seqdiag", nil)
 }
 
 func prettyArity(specArity []int) string {
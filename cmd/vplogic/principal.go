@@ -4,27 +4,43 @@
 
 package vplogic
 
-var principalNamesMap map[string]principalEnum = map[string]principalEnum{
-	"Attacker": 0,
-}
-
-var principalNamesMapCounter principalEnum = 1
-
-func principalNamesMapAdd(name string) principalEnum {
-	id, exists := principalNamesMap[name]
+func (s *Session) principalNamesMapAdd(name string) principalEnum {
+	s.principalNamesMutex.Lock()
+	id, exists := s.principalNamesMap[name]
 	if !exists {
-		id = principalNamesMapCounter
-		principalNamesMap[name] = id
-		principalNamesMapCounter++
+		id = s.principalNamesMapCounter
+		s.principalNamesMap[name] = id
+		s.principalNamesMapCounter++
 	}
+	s.principalNamesMutex.Unlock()
 	return id
 }
 
-func principalGetNameFromID(id principalEnum) string {
-	for k, v := range principalNamesMap {
+func (s *Session) principalNamesMapAdopt(name string, id principalEnum) {
+	s.principalNamesMutex.Lock()
+	s.principalNamesMap[name] = id
+	if id >= s.principalNamesMapCounter {
+		s.principalNamesMapCounter = id + 1
+	}
+	s.principalNamesMutex.Unlock()
+}
+
+func (s *Session) principalGetNameFromID(id principalEnum) string {
+	name := ""
+	s.principalNamesMutex.Lock()
+	for k, v := range s.principalNamesMap {
 		if v == id {
-			return k
+			name = k
+			break
 		}
 	}
-	return ""
+	s.principalNamesMutex.Unlock()
+	return name
+}
+
+func (s *Session) principalGetIDFromName(name string) principalEnum {
+	s.principalNamesMutex.Lock()
+	id := s.principalNamesMap[name]
+	s.principalNamesMutex.Unlock()
+	return id
 }
@@ -12,15 +12,16 @@ import (
 
 // Pv translates a Verifpal model into a ProVerif model.
 func Pv(modelFile string) error {
-	m, err := libpegParseModel(modelFile, false)
+	s := NewSession()
+	m, err := s.libpegParseModel(modelFile, false)
 	if err != nil {
 		return err
 	}
-	valKnowledgeMap, _, err := sanity(m)
+	valKnowledgeMap, _, err := s.sanity(m)
 	if err != nil {
 		return err
 	}
-	pvm, err := pvModel(m, valKnowledgeMap)
+	pvm, err := s.pvModel(m, valKnowledgeMap)
 	if err != nil {
 		return err
 	}
@@ -28,17 +29,17 @@ func Pv(modelFile string) error {
 	return nil
 }
 
-func pvConstantPrefix(valKnowledgeMap *KnowledgeMap, principal string, c *Constant) string {
+func (s *Session) pvConstantPrefix(valKnowledgeMap *KnowledgeMap, principal string, c *Constant) string {
 	prefix := "const"
 	i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, c)
 	c = valKnowledgeMap.Constants[i]
-	if principalGetNameFromID(valKnowledgeMap.Creator[i]) == principal && c.Declaration == typesEnumAssignment {
+	if s.principalGetNameFromID(valKnowledgeMap.Creator[i]) == principal && c.Declaration == typesEnumAssignment {
 		prefix = principal
 	} else {
 		for _, m := range valKnowledgeMap.KnownBy[i] {
-			if p, ok := m[principalNamesMap[principal]]; ok {
-				if principalGetNameFromID(p) != principal {
-					prefix = principalGetNameFromID(p)
+			if p, ok := m[s.principalGetIDFromName(principal)]; ok {
+				if s.principalGetNameFromID(p) != principal {
+					prefix = s.principalGetNameFromID(p)
 				}
 			}
 		}
@@ -46,8 +47,8 @@ func pvConstantPrefix(valKnowledgeMap *KnowledgeMap, principal string, c *Consta
 	return prefix
 }
 
-func pvConstant(valKnowledgeMap *KnowledgeMap, principal string, c *Constant, valType string) string {
-	prefix := pvConstantPrefix(valKnowledgeMap, principal, c)
+func (s *Session) pvConstant(valKnowledgeMap *KnowledgeMap, principal string, c *Constant, valType string) string {
+	prefix := s.pvConstantPrefix(valKnowledgeMap, principal, c)
 	t := ""
 	if len(valType) > 0 {
 		t = fmt.Sprintf(":%s", valType)
@@ -55,7 +56,7 @@ func pvConstant(valKnowledgeMap *KnowledgeMap, principal string, c *Constant, va
 	return fmt.Sprintf("%s_%s%s", prefix, c.Name, t)
 }
 
-func pvConstants(valKnowledgeMap *KnowledgeMap, principal string, c []*Constant, valType string) string {
+func (s *Session) pvConstants(valKnowledgeMap *KnowledgeMap, principal string, c []*Constant, valType string) string {
 	consts := ""
 	for i, v := range c {
 		sep := ""
@@ -63,13 +64,13 @@ func pvConstants(valKnowledgeMap *KnowledgeMap, principal string, c []*Constant,
 			sep = ", "
 		}
 		consts = fmt.Sprintf("%s%s%s",
-			consts, pvConstant(valKnowledgeMap, principal, v, valType), sep,
+			consts, s.pvConstant(valKnowledgeMap, principal, v, valType), sep,
 		)
 	}
 	return consts
 }
 
-func pvPrimitive(valKnowledgeMap *KnowledgeMap, principal string, p *Primitive, check bool) string {
+func (s *Session) pvPrimitive(valKnowledgeMap *KnowledgeMap, principal string, p *Primitive, check bool) string {
 	primitiveStringName := ""
 	if primitiveIsCorePrimitive(p.ID) {
 		prim, _ := primitiveCoreGet(p.ID)
@@ -97,59 +98,59 @@ func pvPrimitive(valKnowledgeMap *KnowledgeMap, principal string, p *Primitive,
 			sep = ", "
 		}
 		prim = fmt.Sprintf("%s%s%s",
-			prim, pvValue(valKnowledgeMap, principal, arg), sep,
+			prim, s.pvValue(valKnowledgeMap, principal, arg), sep,
 		)
 	}
 	prim = fmt.Sprintf("%s)", prim)
 	return prim
 }
 
-func pvEquation(valKnowledgeMap *KnowledgeMap, principal string, e *Equation) string {
+func (s *Session) pvEquation(valKnowledgeMap *KnowledgeMap, principal string, e *Equation) string {
 	eq := ""
 	switch len(e.Values) {
 	case 1:
 		eq = fmt.Sprintf(
 			"G(%s)",
-			pvValue(valKnowledgeMap, principal, e.Values[0]),
+			s.pvValue(valKnowledgeMap, principal, e.Values[0]),
 		)
 	case 2:
 		eq = fmt.Sprintf(
 			"exp(%s, %s)",
-			pvValue(valKnowledgeMap, principal, e.Values[1]),
-			pvValue(valKnowledgeMap, principal, e.Values[0]),
+			s.pvValue(valKnowledgeMap, principal, e.Values[1]),
+			s.pvValue(valKnowledgeMap, principal, e.Values[0]),
 		)
 	}
 	return eq
 }
 
-func pvValue(valKnowledgeMap *KnowledgeMap, principal string, a *Value) string {
+func (s *Session) pvValue(valKnowledgeMap *KnowledgeMap, principal string, a *Value) string {
 	switch a.Kind {
 	case typesEnumConstant:
-		return pvConstant(valKnowledgeMap, principal, a.Data.(*Constant), "")
+		return s.pvConstant(valKnowledgeMap, principal, a.Data.(*Constant), "")
 	case typesEnumPrimitive:
-		return pvPrimitive(valKnowledgeMap, principal, a.Data.(*Primitive), false)
+		return s.pvPrimitive(valKnowledgeMap, principal, a.Data.(*Primitive), false)
 	case typesEnumEquation:
-		return pvEquation(valKnowledgeMap, principal, a.Data.(*Equation))
+		return s.pvEquation(valKnowledgeMap, principal, a.Data.(*Equation))
 	}
 	return ""
 }
 
-func pvQuery(valKnowledgeMap *KnowledgeMap, query Query) (string, error) {
+func (s *Session) pvQuery(valKnowledgeMap *KnowledgeMap, query Query) (string, error) {
 	output := ""
 	switch query.Kind {
 	case typesEnumConfidentiality:
 		i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, query.Constants[0])
 		resolved, _ := valueResolveValueInternalValuesFromKnowledgeMap(valKnowledgeMap.Assigned[i], valKnowledgeMap)
-		output = fmt.Sprintf("query attacker(%s).", pvValue(valKnowledgeMap, "attacker", resolved))
+		output = fmt.Sprintf("query attacker(%s).", s.pvValue(valKnowledgeMap, "attacker", resolved))
 	case typesEnumAuthentication:
 		output = fmt.Sprintf("%s ==> %s.",
 			fmt.Sprintf("query event(RecvMsg(principal_%s, principal_%s, phase_%d, %s))",
-				principalGetNameFromID(query.Message.Sender), principalGetNameFromID(query.Message.Recipient), 0,
-				pvConstant(valKnowledgeMap, "attacker", query.Message.Constants[0], ""),
+				s.principalGetNameFromID(query.Message.Sender), s.principalGetNameFromID(query.Message.Recipient), 0,
+				s.pvConstant(valKnowledgeMap, "attacker", query.Message.Constants[0], ""),
 			),
 			fmt.Sprintf("event(SendMsg(principal_%s, principal_%s, phase_%d, %s))",
-				principalGetNameFromID(query.Message.Sender), principalGetNameFromID(query.Message.Recipient), 0,
-				pvConstant(valKnowledgeMap, "attacker", query.Message.Constants[0], ""),
+				s.principalGetNameFromID(query.Message.Sender), s.principalGetNameFromID(query.Message.Recipient), 0,
+				s.pvConstant(valKnowledgeMap, "attacker", query.Message.Constants[0], ""),
 			),
 		)
 	case typesEnumFreshness:
@@ -165,28 +166,29 @@ func pvQuery(valKnowledgeMap *KnowledgeMap, query Query) (string, error) {
 	return output, nil
 }
 
-func pvPrincipal(
+func (s *Session) pvPrincipal(
 	valKnowledgeMap *KnowledgeMap, block Block,
 	procs string, consts string, pc int, cc int,
 ) (string, string, int, int) {
 	procs = fmt.Sprintf(
-		"%slet %s_%d() =\n",
-		procs, block.Principal.Name, pc,
+		"%s%slet %s_%d() =\n",
+		procs, pvComments(block.Comments.Leading, ""), block.Principal.Name, pc,
 	)
 	for _, expression := range block.Principal.Expressions {
+		procs = procs + pvComments(expression.Comments.Leading, "\t")
 		switch expression.Kind {
 		case typesEnumLeaks:
 			for _, c := range expression.Constants {
 				procs = fmt.Sprintf(
 					"%s\tout(pub, (%s));\n",
-					procs, pvConstant(valKnowledgeMap, block.Principal.Name, c, ""),
+					procs, s.pvConstant(valKnowledgeMap, block.Principal.Name, c, ""),
 				)
 			}
 		case typesEnumAssignment:
-			c := valueGetConstantsFromValue(expression.Assigned)
+			c := s.valueGetConstantsFromValue(expression.Assigned)
 			get := ""
 			for _, cc := range c {
-				prefix := pvConstantPrefix(valKnowledgeMap, block.Principal.Name, cc)
+				prefix := s.pvConstantPrefix(valKnowledgeMap, block.Principal.Name, cc)
 				if prefix == "const" {
 					continue
 				}
@@ -198,7 +200,7 @@ func pvPrincipal(
 					get,
 					prefix, block.Principal.Name,
 					cc.Name,
-					pvConstant(valKnowledgeMap, block.Principal.Name, cc, ""),
+					s.pvConstant(valKnowledgeMap, block.Principal.Name, cc, ""),
 				)
 			}
 			if len(get) > 0 {
@@ -218,15 +220,15 @@ func pvPrincipal(
 				case true:
 					procs = fmt.Sprintf("%s\tif %s = true then\n",
 						procs,
-						pvPrimitive(valKnowledgeMap, block.Principal.Name, expression.Assigned.Data.(*Primitive), true),
+						s.pvPrimitive(valKnowledgeMap, block.Principal.Name, expression.Assigned.Data.(*Primitive), true),
 					)
 				}
 			}
 			procs = fmt.Sprintf(
 				"%s\tlet (%s) = %s in\n",
 				procs,
-				pvConstants(valKnowledgeMap, block.Principal.Name, expression.Constants, valType),
-				pvValue(valKnowledgeMap, block.Principal.Name, expression.Assigned),
+				s.pvConstants(valKnowledgeMap, block.Principal.Name, expression.Constants, valType),
+				s.pvValue(valKnowledgeMap, block.Principal.Name, expression.Assigned),
 			)
 			for _, l := range expression.Constants {
 				if strings.HasPrefix(l.Name, "unnamed_") {
@@ -237,37 +239,41 @@ func pvPrincipal(
 					procs,
 					block.Principal.Name, block.Principal.Name,
 					l.Name,
-					pvConstant(valKnowledgeMap, block.Principal.Name, l, ""),
+					s.pvConstant(valKnowledgeMap, block.Principal.Name, l, ""),
 				)
 			}
 		}
+		procs = procs + pvComments(expression.Comments.Trailing, "\t")
 	}
+	procs = procs + pvComments(block.Principal.Comments, "\t")
 	procs = procs + "\t0.\n"
+	procs = procs + pvComments(block.Comments.Trailing, "")
 	pc = pc + 1
 	return procs, consts, pc, cc
 }
 
-func pvMessage(
+func (s *Session) pvMessage(
 	valKnowledgeMap *KnowledgeMap, block Block,
 	procs string, pc int,
 ) (string, int) {
 	procs = fmt.Sprintf(
-		"%slet %s_to_%s_%d() =\n",
-		procs, principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Recipient), pc,
+		"%s%slet %s_to_%s_%d() =\n",
+		procs, pvComments(block.Comments.Leading, ""),
+		s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Recipient), pc,
 	)
 	for _, c := range block.Message.Constants {
 		procs = fmt.Sprintf(
 			"%s\tget valuestore(=principal_%s, =principal_%s, =const_%s, %s) in\n",
-			procs, principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Sender),
+			procs, s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Sender),
 			c.Name,
-			pvConstant(valKnowledgeMap, principalGetNameFromID(block.Message.Sender), c, ""),
+			s.pvConstant(valKnowledgeMap, s.principalGetNameFromID(block.Message.Sender), c, ""),
 		)
 	}
 	for _, c := range block.Message.Constants {
 		procs = fmt.Sprintf(
 			"%s\tevent SendMsg(principal_%s, principal_%s, phase_%d, %s);\n",
-			procs, principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Recipient),
-			0, pvConstant(valKnowledgeMap, "", c, ""),
+			procs, s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Recipient),
+			0, s.pvConstant(valKnowledgeMap, "", c, ""),
 		)
 	}
 	for _, c := range block.Message.Constants {
@@ -275,18 +281,18 @@ func pvMessage(
 		case true:
 			procs = fmt.Sprintf(
 				"%s\tout(pub, %s);\n",
-				procs, pvConstant(valKnowledgeMap, principalGetNameFromID(block.Message.Sender), c, ""),
+				procs, s.pvConstant(valKnowledgeMap, s.principalGetNameFromID(block.Message.Sender), c, ""),
 			)
 			procs = fmt.Sprintf(
 				"%s\tout(chan_%s_to_%s_private, (%s));\n",
-				procs, principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Recipient),
-				pvConstant(valKnowledgeMap, principalGetNameFromID(block.Message.Sender), c, ""),
+				procs, s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Recipient),
+				s.pvConstant(valKnowledgeMap, s.principalGetNameFromID(block.Message.Sender), c, ""),
 			)
 		case false:
 			procs = fmt.Sprintf(
 				"%s\tout(chan_%s_to_%s, (%s));\n",
-				procs, principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Recipient),
-				pvConstant(valKnowledgeMap, principalGetNameFromID(block.Message.Sender), c, ""),
+				procs, s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Recipient),
+				s.pvConstant(valKnowledgeMap, s.principalGetNameFromID(block.Message.Sender), c, ""),
 			)
 		}
 	}
@@ -294,50 +300,62 @@ func pvMessage(
 	pc = pc + 1
 	procs = fmt.Sprintf(
 		"%slet %s_from_%s_%d() =\n",
-		procs, principalGetNameFromID(block.Message.Recipient), principalGetNameFromID(block.Message.Sender), pc,
+		procs, s.principalGetNameFromID(block.Message.Recipient), s.principalGetNameFromID(block.Message.Sender), pc,
 	)
 	for _, c := range block.Message.Constants {
 		switch c.Guard {
 		case true:
 			procs = fmt.Sprintf(
 				"%s\tin(chan_%s_to_%s_private, (%s));\n",
-				procs, principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Recipient),
-				pvConstant(valKnowledgeMap, principalGetNameFromID(block.Message.Sender), c, "bitstring"),
+				procs, s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Recipient),
+				s.pvConstant(valKnowledgeMap, s.principalGetNameFromID(block.Message.Sender), c, "bitstring"),
 			)
 		case false:
 			procs = fmt.Sprintf(
 				"%s\tin(chan_%s_to_%s, (%s));\n",
-				procs, principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Recipient),
-				pvConstant(valKnowledgeMap, principalGetNameFromID(block.Message.Sender), c, "bitstring"),
+				procs, s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Recipient),
+				s.pvConstant(valKnowledgeMap, s.principalGetNameFromID(block.Message.Sender), c, "bitstring"),
 			)
 		}
 	}
 	for _, c := range block.Message.Constants {
 		procs = fmt.Sprintf(
 			"%s\tevent RecvMsg(principal_%s, principal_%s, phase_%d, %s);\n",
-			procs, principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Recipient), 0,
-			pvConstant(valKnowledgeMap, "", c, ""),
+			procs, s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Recipient), 0,
+			s.pvConstant(valKnowledgeMap, "", c, ""),
 		)
 		procs = fmt.Sprintf(
 			"%s\tinsert valuestore(principal_%s, principal_%s, const_%s, %s);\n",
 			procs,
-			principalGetNameFromID(block.Message.Sender), principalGetNameFromID(block.Message.Recipient),
+			s.principalGetNameFromID(block.Message.Sender), s.principalGetNameFromID(block.Message.Recipient),
 			c.Name,
-			pvConstant(valKnowledgeMap, principalGetNameFromID(block.Message.Sender), c, ""),
+			s.pvConstant(valKnowledgeMap, s.principalGetNameFromID(block.Message.Sender), c, ""),
 		)
 	}
 	procs = procs + "\t0.\n"
+	procs = procs + pvComments(block.Comments.Trailing, "")
 	pc = pc + 1
 	return procs, pc
 }
 
+func pvComments(comments []Comment, indent string) string {
+	output := ""
+	for _, comment := range comments {
+		output = fmt.Sprintf(
+			"%s%s%s\n",
+			output, indent, prettyMLComment(comment),
+		)
+	}
+	return output
+}
+
 func pvPhase(block Block) (string, error) {
 	return "", fmt.Errorf("phases are not yet supported in ProVerif model generation")
 	// return fmt.Sprintf("phase %d;", block.Phase.Number)
 }
 
-func pvModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
-	pv := ""
+func (s *Session) pvModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
+	pv := pvComments(m.Comments.Leading, "")
 	procs := ""
 	consts := ""
 	pc := 0
@@ -345,11 +363,11 @@ func pvModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
 	for _, block := range m.Blocks {
 		switch block.Kind {
 		case "principal":
-			procs, consts, pc, cc = pvPrincipal(
+			procs, consts, pc, cc = s.pvPrincipal(
 				valKnowledgeMap, block, procs, consts, pc, cc,
 			)
 		case "message":
-			procs, pc = pvMessage(valKnowledgeMap, block, procs, pc)
+			procs, pc = s.pvMessage(valKnowledgeMap, block, procs, pc)
 		case "phase":
 			pvp, err := pvPhase(block)
 			if err != nil {
@@ -358,7 +376,7 @@ func pvModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
 			pv = pv + pvp
 		}
 	}
-	queries, err := libpv.Queries(valKnowledgeMap, m.Queries)
+	queries, err := libpv.Queries(s, valKnowledgeMap, m.Queries, m.QueriesComments)
 	if err != nil {
 		return "", err
 	}
@@ -370,6 +388,9 @@ func pvModel(m Model, valKnowledgeMap *KnowledgeMap) (string, error) {
 	pv = pv + libpv.Channels(valKnowledgeMap)
 	pv = pv + queries
 	pv = pv + procs
-	pv = pv + libpv.TopLevel(m.Blocks)
+	pv = pv + libpv.TopLevel(s, m.Blocks)
+	if len(m.Comments.Trailing) > 0 {
+		pv = pv + "\n" + strings.TrimSuffix(pvComments(m.Comments.Trailing, ""), "\n")
+	}
 	return pv, nil
 }
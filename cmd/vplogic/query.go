@@ -8,28 +8,28 @@ import (
 	"fmt"
 )
 
-func queryStart(
-	query Query, valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
+func (s *Session) queryStart(
+	query Query, stage int, valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
 ) error {
-	valAttackerState := attackerStateGetRead()
+	valAttackerState := s.attackerStateGetRead()
 	var err error
 	switch query.Kind {
 	case typesEnumConfidentiality:
-		queryConfidentiality(query, valKnowledgeMap, valPrincipalState, valAttackerState)
+		s.queryConfidentiality(query, stage, valKnowledgeMap, valPrincipalState, valAttackerState)
 	case typesEnumAuthentication:
-		queryAuthentication(query, valKnowledgeMap, valPrincipalState, valAttackerState)
+		s.queryAuthentication(query, stage, valKnowledgeMap, valPrincipalState, valAttackerState)
 	case typesEnumFreshness:
-		_, err = queryFreshness(query, valKnowledgeMap, valPrincipalState, valAttackerState)
+		_, err = s.queryFreshness(query, stage, valKnowledgeMap, valPrincipalState, valAttackerState)
 	case typesEnumUnlinkability:
-		_, err = queryUnlinkability(query, valKnowledgeMap, valPrincipalState, valAttackerState)
+		_, err = s.queryUnlinkability(query, stage, valKnowledgeMap, valPrincipalState, valAttackerState)
 	case typesEnumEquivalence:
-		queryEquivalence(query, valKnowledgeMap, valPrincipalState, valAttackerState)
+		s.queryEquivalence(query, stage, valKnowledgeMap, valPrincipalState, valAttackerState)
 	}
 	return err
 }
 
-func queryConfidentiality(
-	query Query, valKnowledgeMap *KnowledgeMap,
+func (s *Session) queryConfidentiality(
+	query Query, stage int, valKnowledgeMap *KnowledgeMap,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) VerifyResult {
 	result := VerifyResult{
@@ -37,37 +37,37 @@ func queryConfidentiality(
 		Resolved: false,
 		Summary:  "",
 		Options:  []QueryOptionResult{},
+		Stage:    stage,
 	}
 	i := valueGetPrincipalStateIndexFromConstant(valPrincipalState, query.Constants[0])
 	if i < 0 {
 		return result
 	}
 	resolvedValue := valPrincipalState.Assigned[i]
-	ii := valueEquivalentValueInValues(resolvedValue, valAttackerState.Known)
+	ii := attackerStateKnownIndex(valAttackerState, resolvedValue)
 	if ii < 0 {
 		return result
 	}
-	mutatedInfo := infoQueryMutatedValues(
+	mutatedInfo, trace := s.infoQueryMutatedValues(
 		valKnowledgeMap, valAttackerState.PrincipalState[ii], valAttackerState, resolvedValue, 0,
 	)
+	result.Trace = trace
 	result.Resolved = true
-	result.Summary = infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
+	result.Summary = s.infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
 		"%s (%s) is obtained by Attacker.",
 		prettyConstant(query.Constants[0]),
 		prettyValue(valAttackerState.Known[ii]),
 	), result.Options)
-	result = queryPrecondition(result, valPrincipalState)
-	written := verifyResultsPutWrite(result)
+	result = s.queryPrecondition(result, valPrincipalState)
+	written := s.verifyResultsPutWrite(result)
 	if written {
-		InfoMessage(fmt.Sprintf(
-			"%s — %s", prettyQuery(query), result.Summary,
-		), "result", true)
+		s.infoQueryResolved(result)
 	}
 	return result
 }
 
-func queryAuthentication(
-	query Query, valKnowledgeMap *KnowledgeMap,
+func (s *Session) queryAuthentication(
+	query Query, stage int, valKnowledgeMap *KnowledgeMap,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) VerifyResult {
 	result := VerifyResult{
@@ -75,11 +75,12 @@ func queryAuthentication(
 		Resolved: false,
 		Summary:  "",
 		Options:  []QueryOptionResult{},
+		Stage:    stage,
 	}
 	if query.Message.Recipient != valPrincipalState.ID {
 		return result
 	}
-	indices, sender, c := queryAuthenticationGetPassIndices(
+	indices, sender, c := s.queryAuthenticationGetPassIndices(
 		query, valKnowledgeMap, valPrincipalState,
 	)
 	for _, index := range indices {
@@ -89,18 +90,19 @@ func queryAuthentication(
 		result.Resolved = true
 		a := valPrincipalState.Assigned[index]
 		b := valPrincipalState.BeforeRewrite[index]
-		mutatedInfo := infoQueryMutatedValues(
+		mutatedInfo, trace := s.infoQueryMutatedValues(
 			valKnowledgeMap, valPrincipalState, valAttackerState, a, 0,
 		)
-		result = queryPrecondition(result, valPrincipalState)
-		return queryAuthenticationHandlePass(
+		result.Trace = trace
+		result = s.queryPrecondition(result, valPrincipalState)
+		return s.queryAuthenticationHandlePass(
 			result, c, b, mutatedInfo, sender, valPrincipalState,
 		)
 	}
 	return result
 }
 
-func queryAuthenticationGetPassIndices(
+func (s *Session) queryAuthenticationGetPassIndices(
 	query Query, valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
 ) ([]int, principalEnum, *Constant) {
 	indices := []int{}
@@ -110,7 +112,7 @@ func queryAuthenticationGetPassIndices(
 	}
 	c := valKnowledgeMap.Constants[i]
 	sender := valPrincipalState.Sender[i]
-	if sender == principalNamesMap["Attacker"] {
+	if sender == s.principalGetIDFromName("Attacker") {
 		v := valPrincipalState.BeforeMutate[i]
 		if valueEquivalentValues(v, valPrincipalState.Assigned[i], true) {
 			return indices, sender, c
@@ -145,7 +147,7 @@ func queryAuthenticationGetPassIndices(
 			indices = append(indices, iiii)
 			continue
 		}
-		pass, _ := possibleToRewrite(b.Data.(*Primitive), valPrincipalState)
+		pass, _ := s.possibleToRewrite(b.Data.(*Primitive), valPrincipalState)
 		if pass {
 			indices = append(indices, iiii)
 		}
@@ -153,28 +155,26 @@ func queryAuthenticationGetPassIndices(
 	return indices, sender, c
 }
 
-func queryAuthenticationHandlePass(
+func (s *Session) queryAuthenticationHandlePass(
 	result VerifyResult, c *Constant, b *Value, mutatedInfo string, sender principalEnum,
 	valPrincipalState *PrincipalState,
 ) VerifyResult {
 	cc, _ := valueResolveConstant(c, valPrincipalState, true)
-	result.Summary = infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
+	result.Summary = s.infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
 		"%s (%s), sent by %s and not by %s, is successfully used in %s within %s's state.",
-		prettyConstant(c), prettyValue(cc), principalGetNameFromID(sender),
-		principalGetNameFromID(result.Query.Message.Sender),
-		prettyValue(b), principalGetNameFromID(result.Query.Message.Recipient),
+		prettyConstant(c), prettyValue(cc), s.principalGetNameFromID(sender),
+		s.principalGetNameFromID(result.Query.Message.Sender),
+		prettyValue(b), s.principalGetNameFromID(result.Query.Message.Recipient),
 	), result.Options)
-	written := verifyResultsPutWrite(result)
+	written := s.verifyResultsPutWrite(result)
 	if written {
-		InfoMessage(fmt.Sprintf(
-			"%s — %s", prettyQuery(result.Query), result.Summary,
-		), "result", true)
+		s.infoQueryResolved(result)
 	}
 	return result
 }
 
-func queryFreshness(
-	query Query, valKnowledgeMap *KnowledgeMap,
+func (s *Session) queryFreshness(
+	query Query, stage int, valKnowledgeMap *KnowledgeMap,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) (VerifyResult, error) {
 	result := VerifyResult{
@@ -182,9 +182,10 @@ func queryFreshness(
 		Resolved: false,
 		Summary:  "",
 		Options:  []QueryOptionResult{},
+		Stage:    stage,
 	}
 	indices := []int{}
-	freshnessFound, err := valueConstantContainsFreshValues(query.Constants[0], valPrincipalState)
+	freshnessFound, err := s.valueConstantContainsFreshValues(query.Constants[0], valPrincipalState)
 	if err != nil {
 		return result, err
 	}
@@ -220,7 +221,7 @@ func queryFreshness(
 			indices = append(indices, ii)
 			continue
 		}
-		pass, _ := possibleToRewrite(b.Data.(*Primitive), valPrincipalState)
+		pass, _ := s.possibleToRewrite(b.Data.(*Primitive), valPrincipalState)
 		if pass {
 			indices = append(indices, ii)
 		}
@@ -229,21 +230,20 @@ func queryFreshness(
 		return result, nil
 	}
 	resolved, _ := valueResolveConstant(query.Constants[0], valPrincipalState, true)
-	mutatedInfo := infoQueryMutatedValues(
+	mutatedInfo, trace := s.infoQueryMutatedValues(
 		valKnowledgeMap, valPrincipalState, valAttackerState, resolved, 0,
 	)
+	result.Trace = trace
 	result.Resolved = true
-	result.Summary = infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
+	result.Summary = s.infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
 		"%s (%s) is used by %s in %s despite not being a fresh value.",
 		prettyConstant(query.Constants[0]), prettyValue(resolved),
 		valPrincipalState.Name, prettyValue(valPrincipalState.BeforeRewrite[indices[0]]),
 	), result.Options)
-	result = queryPrecondition(result, valPrincipalState)
-	written := verifyResultsPutWrite(result)
+	result = s.queryPrecondition(result, valPrincipalState)
+	written := s.verifyResultsPutWrite(result)
 	if written {
-		InfoMessage(fmt.Sprintf(
-			"%s — %s", prettyQuery(query), result.Summary,
-		), "result", true)
+		s.infoQueryResolved(result)
 	}
 	return result, nil
 }
@@ -256,8 +256,8 @@ func queryFreshness(
  * This definition of unlinkability on values is almost certainly
  * incomplete.
  */
-func queryUnlinkability(
-	query Query, valKnowledgeMap *KnowledgeMap,
+func (s *Session) queryUnlinkability(
+	query Query, stage int, valKnowledgeMap *KnowledgeMap,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) (VerifyResult, error) {
 	result := VerifyResult{
@@ -265,10 +265,11 @@ func queryUnlinkability(
 		Resolved: false,
 		Summary:  "",
 		Options:  []QueryOptionResult{},
+		Stage:    stage,
 	}
 	noFreshness := []*Constant{}
 	for _, c := range query.Constants {
-		freshnessFound, err := valueConstantContainsFreshValues(c, valPrincipalState)
+		freshnessFound, err := s.valueConstantContainsFreshValues(c, valPrincipalState)
 		if err != nil {
 			return result, err
 		}
@@ -278,20 +279,19 @@ func queryUnlinkability(
 	}
 	if len(noFreshness) > 0 {
 		resolved, _ := valueResolveConstant(noFreshness[0], valPrincipalState, true)
-		mutatedInfo := infoQueryMutatedValues(
+		mutatedInfo, trace := s.infoQueryMutatedValues(
 			valKnowledgeMap, valPrincipalState, valAttackerState, resolved, 0,
 		)
+		result.Trace = trace
 		result.Resolved = true
-		result.Summary = infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
+		result.Summary = s.infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
 			"%s (%s) cannot be a suitable unlinkability candidate since it does not satisfy freshness.",
 			prettyConstant(noFreshness[0]), prettyValue(resolved),
 		), result.Options)
-		result = queryPrecondition(result, valPrincipalState)
-		written := verifyResultsPutWrite(result)
+		result = s.queryPrecondition(result, valPrincipalState)
+		written := s.verifyResultsPutWrite(result)
 		if written {
-			InfoMessage(fmt.Sprintf(
-				"%s — %s", prettyQuery(query), result.Summary,
-			), "result", true)
+			s.infoQueryResolved(result)
 		}
 		return result, nil
 	}
@@ -313,29 +313,28 @@ func queryUnlinkability(
 			obtainable := false
 			switch assigneds[i].Kind {
 			case typesEnumPrimitive:
-				ok0, _ := possibleToReconstructPrimitive(assigneds[i].Data.(*Primitive), valPrincipalState, valAttackerState)
+				ok0, _ := s.possibleToReconstructPrimitive(assigneds[i].Data.(*Primitive), valPrincipalState, valAttackerState)
 				ok1, _, _ := possibleToRecomposePrimitive(assigneds[i].Data.(*Primitive), valAttackerState)
 				obtainable = ok0 || ok1
 			}
 			if !obtainable {
 				continue
 			}
-			mutatedInfo := infoQueryMutatedValues(
+			mutatedInfo, trace := s.infoQueryMutatedValues(
 				valKnowledgeMap, valPrincipalState, valAttackerState, &Value{}, 0,
 			)
+			result.Trace = trace
 			result.Resolved = true
-			result.Summary = infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
+			result.Summary = s.infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
 				"%s and %s %s (%s), %s.",
 				prettyConstant(constants[i]), prettyConstant(constants[ii]),
 				"are not unlinkable since they are the output of the same primitive",
 				prettyValue(assigneds[i]), "which can be obtained by Attacker",
 			), result.Options)
-			result = queryPrecondition(result, valPrincipalState)
-			written := verifyResultsPutWrite(result)
+			result = s.queryPrecondition(result, valPrincipalState)
+			written := s.verifyResultsPutWrite(result)
 			if written {
-				InfoMessage(fmt.Sprintf(
-					"%s — %s", prettyQuery(query), result.Summary,
-				), "result", true)
+				s.infoQueryResolved(result)
 			}
 			return result, nil
 		}
@@ -343,8 +342,8 @@ func queryUnlinkability(
 	return result, nil
 }
 
-func queryEquivalence(
-	query Query, valKnowledgeMap *KnowledgeMap,
+func (s *Session) queryEquivalence(
+	query Query, stage int, valKnowledgeMap *KnowledgeMap,
 	valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) VerifyResult {
 	result := VerifyResult{
@@ -352,6 +351,7 @@ func queryEquivalence(
 		Resolved: false,
 		Summary:  "",
 		Options:  []QueryOptionResult{},
+		Stage:    stage,
 	}
 	values := []*Value{}
 	for i := 0; i < len(query.Constants); i++ {
@@ -374,25 +374,24 @@ OuterLoop:
 	if !brokenEquivalence {
 		return result
 	}
-	mutatedInfo := infoQueryMutatedValues(
+	mutatedInfo, trace := s.infoQueryMutatedValues(
 		valKnowledgeMap, valPrincipalState, valAttackerState, &Value{}, 0,
 	)
+	result.Trace = trace
 	result.Resolved = true
-	result.Summary = infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
+	result.Summary = s.infoVerifyResultSummary(mutatedInfo, fmt.Sprintf(
 		"%s %s",
 		prettyValues(values), "are not equivalent.",
 	), result.Options)
-	result = queryPrecondition(result, valPrincipalState)
-	written := verifyResultsPutWrite(result)
+	result = s.queryPrecondition(result, valPrincipalState)
+	written := s.verifyResultsPutWrite(result)
 	if written {
-		InfoMessage(fmt.Sprintf(
-			"%s — %s", prettyQuery(query), result.Summary,
-		), "result", true)
+		s.infoQueryResolved(result)
 	}
 	return result
 }
 
-func queryPrecondition(
+func (s *Session) queryPrecondition(
 	result VerifyResult, valPrincipalState *PrincipalState,
 ) VerifyResult {
 	if !result.Resolved {
@@ -422,9 +421,9 @@ func queryPrecondition(
 			oResult.Resolved = true
 			oResult.Summary = fmt.Sprintf(
 				"%s sends %s to %s despite the query failing.",
-				principalGetNameFromID(option.Message.Sender),
+				s.principalGetNameFromID(option.Message.Sender),
 				prettyConstant(option.Message.Constants[0]),
-				principalGetNameFromID(option.Message.Recipient),
+				s.principalGetNameFromID(option.Message.Recipient),
 			)
 		}
 		result.Options = append(result.Options, oResult)
@@ -0,0 +1,139 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var reportColorCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func (s *Session) verifyReport(
+	m Model, fileName string, valVerifyResults []VerifyResult, interrupted bool, started time.Time,
+) Report {
+	completed := time.Now()
+	r := Report{
+		Model:         fileName,
+		Attacker:      m.Attacker,
+		Status:        VerifyStatusVerified,
+		ResultsCode:   verifyGetResultsCode(valVerifyResults),
+		Interrupted:   interrupted,
+		Stage:         s.verifyAnalysisStageGet(),
+		AnalysisCount: s.verifyAnalysisCountGet(),
+		Started:       started,
+		Completed:     completed,
+		Seconds:       completed.Sub(started).Seconds(),
+		Queries:       make([]ReportQuery, len(valVerifyResults)),
+	}
+	if interrupted {
+		r.Status = VerifyStatusInconclusive
+	}
+	for i, verifyResult := range valVerifyResults {
+		r.Queries[i] = s.verifyReportQuery(verifyResult)
+		switch verifyResult.Status {
+		case VerifyStatusAttackFound:
+			r.Status = VerifyStatusAttackFound
+		case VerifyStatusInconclusive:
+			if r.Status != VerifyStatusAttackFound {
+				r.Status = VerifyStatusInconclusive
+			}
+		}
+	}
+	return r
+}
+
+func (s *Session) verifyReportQuery(verifyResult VerifyResult) ReportQuery {
+	q := ReportQuery{
+		Query:         s.prettyQuery(verifyResult.Query),
+		Kind:          reportQueryKind(verifyResult.Query.Kind),
+		Status:        verifyResult.Status,
+		Summary:       reportSummary(verifyResult.Summary),
+		Stage:         verifyResult.Stage,
+		Exhausted:     verifyResult.Exhausted,
+		Preconditions: []ReportPrecondition{},
+		Position:      verifyResult.Query.Position,
+		Trace:         []ReportTraceStep{},
+	}
+	for _, step := range verifyResult.Trace {
+		q.Trace = append(q.Trace, ReportTraceStep{
+			Constant: prettyConstant(step.Constant),
+			Value:    step.Value,
+			Note:     step.Note,
+			Position: step.Constant.Position,
+		})
+	}
+	for _, option := range verifyResult.Options {
+		q.Preconditions = append(q.Preconditions, ReportPrecondition{
+			Precondition: fmt.Sprintf(
+				"%s -> %s: %s",
+				s.principalGetNameFromID(option.Option.Message.Sender),
+				s.principalGetNameFromID(option.Option.Message.Recipient),
+				prettyConstants(option.Option.Message.Constants),
+			),
+			Satisfied: option.Resolved,
+			Summary:   reportSummary(option.Summary),
+		})
+	}
+	return q
+}
+
+func reportQueryKind(kind typesEnum) string {
+	switch kind {
+	case typesEnumConfidentiality:
+		return "confidentiality"
+	case typesEnumAuthentication:
+		return "authentication"
+	case typesEnumFreshness:
+		return "freshness"
+	case typesEnumUnlinkability:
+		return "unlinkability"
+	case typesEnumEquivalence:
+		return "equivalence"
+	}
+	return ""
+}
+
+func reportSummary(summary string) string {
+	return strings.TrimSpace(reportColorCodes.ReplaceAllString(summary, ""))
+}
+
+// NewReportCollector returns an Observer which collects the Report of each verification it observes.
+func NewReportCollector() *ReportCollector {
+	return &ReportCollector{
+		reports: []Report{},
+	}
+}
+
+// Observe collects the Report carried by EventVerificationCompleted events.
+func (c *ReportCollector) Observe(event Event) {
+	switch e := event.(type) {
+	case EventVerificationCompleted:
+		c.mutex.Lock()
+		c.reports = append(c.reports, e.Report)
+		c.mutex.Unlock()
+	}
+}
+
+// Reports returns the Reports collected so far, in the order in which verifications completed.
+func (c *ReportCollector) Reports() []Report {
+	c.mutex.Lock()
+	reports := make([]Report, len(c.reports))
+	copy(reports, c.reports)
+	c.mutex.Unlock()
+	return reports
+}
+
+// WriteReportJSON writes a Report as an indented JSON document.
+func WriteReportJSON(w io.Writer, r Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,57 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestVerifyReport(t *testing.T) {
+	reports := NewReportCollector()
+	_, _, err := VerifyFile(
+		context.Background(), "../../examples/test/hmac_unguarded_bob.vp",
+		Options{Color: true, Observers: []Observer{reports}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports.Reports()) != 1 {
+		t.Fatalf("expected one report, got %d", len(reports.Reports()))
+	}
+	r := reports.Reports()[0]
+	if r.ResultsCode != "c1a0" || r.Status != VerifyStatusAttackFound || r.AnalysisCount == 0 {
+		t.Errorf("unexpected report: %s, %s, %d analyses", r.ResultsCode, r.Status, r.AnalysisCount)
+	}
+	if len(r.Queries) != 2 {
+		t.Fatalf("expected two queries, got %d", len(r.Queries))
+	}
+	if r.Queries[0].Query != "confidentiality? plaintext" || r.Queries[0].Kind != "confidentiality" ||
+		r.Queries[0].Status != VerifyStatusAttackFound || r.Queries[1].Status != VerifyStatusVerified {
+		t.Errorf("unexpected queries: %v", r.Queries)
+	}
+	if strings.Contains(r.Queries[0].Summary, "\x1b[") {
+		t.Errorf("expected summary without terminal colors, got %q", r.Queries[0].Summary)
+	}
+	var j bytes.Buffer
+	err = WriteReportJSON(&j, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(j.String(), `"status": "attack"`) {
+		t.Errorf("expected status to be written by name, got %s", j.String())
+	}
+	var decoded Report
+	err = json.Unmarshal(j.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ResultsCode != r.ResultsCode || decoded.Queries[1].Status != VerifyStatusVerified {
+		t.Errorf("expected report to survive a JSON round trip, got %v", decoded)
+	}
+}
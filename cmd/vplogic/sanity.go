@@ -8,22 +8,22 @@ import (
 	"fmt"
 )
 
-func sanity(m Model) (*KnowledgeMap, []*PrincipalState, error) {
+func (s *Session) sanity(m Model) (*KnowledgeMap, []*PrincipalState, error) {
 	err := sanityPhases(m)
 	if err != nil {
-		return &KnowledgeMap{}, []*PrincipalState{}, err
+		return &KnowledgeMap{}, []*PrincipalState{}, modelErrorInFile(m.FileName, err)
 	}
 	principals, principalIDs, err := sanityDeclaredPrincipals(m)
 	if err != nil {
-		return &KnowledgeMap{}, []*PrincipalState{}, err
+		return &KnowledgeMap{}, []*PrincipalState{}, modelErrorInFile(m.FileName, err)
 	}
-	valKnowledgeMap, err := constructKnowledgeMap(m, principals, principalIDs)
+	valKnowledgeMap, err := s.constructKnowledgeMap(m, principals, principalIDs)
 	if err != nil {
-		return &KnowledgeMap{}, []*PrincipalState{}, err
+		return &KnowledgeMap{}, []*PrincipalState{}, modelErrorInFile(m.FileName, err)
 	}
-	err = sanityQueries(m, valKnowledgeMap)
+	err = s.sanityQueries(m, valKnowledgeMap)
 	if err != nil {
-		return &KnowledgeMap{}, []*PrincipalState{}, err
+		return &KnowledgeMap{}, []*PrincipalState{}, modelErrorInFile(m.FileName, err)
 	}
 	valPrincipalStates := constructPrincipalStates(m, valKnowledgeMap)
 	return valKnowledgeMap, valPrincipalStates, nil
@@ -36,15 +36,15 @@ func sanityPhases(m Model) error {
 		case "phase":
 			switch {
 			case blck.Phase.Number <= phase:
-				return fmt.Errorf(
+				return modelErrorAt(blck.Position, fmt.Errorf(
 					"phase being declared (%d) must be superior to last declared phase (%d)",
 					blck.Phase.Number, phase,
-				)
+				))
 			case blck.Phase.Number != phase+1:
-				return fmt.Errorf(
+				return modelErrorAt(blck.Position, fmt.Errorf(
 					"phase being declared (%d) skips phases since last declared phase (%d)",
 					blck.Phase.Number, phase,
-				)
+				))
 			default:
 				phase = blck.Phase.Number
 			}
@@ -87,21 +87,22 @@ func sanityAssignmentConstants(
 func sanityAssignmentConstantsFromPrimitive(
 	right *Value, constants []*Constant, valKnowledgeMap *KnowledgeMap,
 ) ([]*Constant, error) {
-	primArguments := len(right.Data.(*Primitive).Arguments)
-	specArity, err := primitiveGetArity(right.Data.(*Primitive))
+	p := right.Data.(*Primitive)
+	primArguments := len(p.Arguments)
+	specArity, err := primitiveGetArity(p)
 	if err != nil {
-		return []*Constant{}, err
+		return []*Constant{}, modelErrorAt(p.Position, err)
 	}
 	if primArguments == 0 {
-		return []*Constant{}, fmt.Errorf("primitive has no inputs")
+		return []*Constant{}, modelErrorAt(p.Position, fmt.Errorf("primitive has no inputs"))
 	}
 	if !intInSlice(primArguments, specArity) {
 		arityString := prettyArity(specArity)
-		return []*Constant{}, fmt.Errorf(
+		return []*Constant{}, modelErrorAt(p.Position, fmt.Errorf(
 			"primitive has %d inputs, expecting %s", primArguments, arityString,
-		)
+		))
 	}
-	for _, a := range right.Data.(*Primitive).Arguments {
+	for _, a := range p.Arguments {
 		switch a.Kind {
 		case typesEnumConstant:
 			unique := true
@@ -155,104 +156,104 @@ func sanityPrimitive(p *Primitive, outputs []*Constant) error {
 	} else {
 		prim, err := primitiveGet(p.ID)
 		if err != nil {
-			return err
+			return modelErrorAt(p.Position, err)
 		}
 		output = prim.Output
 		check = prim.Check
 	}
 	if !intInSlice(len(outputs), output) {
 		outputString := prettyArity(output)
-		return fmt.Errorf(
+		return modelErrorAt(p.Position, fmt.Errorf(
 			"primitive has %d outputs, expecting %s",
 			len(outputs), outputString,
-		)
+		))
 	}
 	if p.Check && !check {
-		return fmt.Errorf("primitive is checked but does not support checking")
+		return modelErrorAt(p.Position, fmt.Errorf("primitive is checked but does not support checking"))
 	}
-	return sanityCheckPrimitiveArgumentOutputs(p)
+	return modelErrorAt(p.Position, sanityCheckPrimitiveArgumentOutputs(p))
 }
 
-func sanityQueries(m Model, valKnowledgeMap *KnowledgeMap) error {
+func (s *Session) sanityQueries(m Model, valKnowledgeMap *KnowledgeMap) error {
 	var err error
 	for _, query := range m.Queries {
 		switch query.Kind {
 		case typesEnumConfidentiality:
-			err = sanityQueriesConfidentiality(query, valKnowledgeMap)
+			err = s.sanityQueriesConfidentiality(query, valKnowledgeMap)
 		case typesEnumAuthentication:
-			err = sanityQueriesAuthentication(query, valKnowledgeMap)
+			err = s.sanityQueriesAuthentication(query, valKnowledgeMap)
 		case typesEnumFreshness:
-			err = sanityQueriesFreshness(query, valKnowledgeMap)
+			err = s.sanityQueriesFreshness(query, valKnowledgeMap)
 		case typesEnumUnlinkability:
-			err = sanityQueriesUnlinkability(query, valKnowledgeMap)
+			err = s.sanityQueriesUnlinkability(query, valKnowledgeMap)
 		case typesEnumEquivalence:
-			err = sanityQueriesEquivalence(query, valKnowledgeMap)
+			err = s.sanityQueriesEquivalence(query, valKnowledgeMap)
 		default:
-			return fmt.Errorf("invalid query kind")
+			return modelErrorAt(query.Position, fmt.Errorf("invalid query kind"))
 		}
 		if err != nil {
-			return err
+			return modelErrorAt(query.Position, err)
 		}
-		err = sanityQueryOptions(query, valKnowledgeMap)
+		err = s.sanityQueryOptions(query, valKnowledgeMap)
 		if err != nil {
-			return err
+			return modelErrorAt(query.Position, err)
 		}
 	}
 	return nil
 }
 
-func sanityQueriesConfidentiality(query Query, valKnowledgeMap *KnowledgeMap) error {
+func (s *Session) sanityQueriesConfidentiality(query Query, valKnowledgeMap *KnowledgeMap) error {
 	i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, query.Constants[0])
 	if i < 0 {
 		return fmt.Errorf(
 			"confidentiality query (%s) refers to unknown constant (%s)",
-			prettyQuery(query),
+			s.prettyQuery(query),
 			prettyConstant(query.Constants[0]),
 		)
 	}
 	return nil
 }
 
-func sanityQueriesAuthentication(query Query, valKnowledgeMap *KnowledgeMap) error {
+func (s *Session) sanityQueriesAuthentication(query Query, valKnowledgeMap *KnowledgeMap) error {
 	i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, query.Message.Constants[0])
 	if i < 0 {
 		return fmt.Errorf(
 			"authentication query (%s) refers to unknown constant (%s)",
-			prettyQuery(query),
+			s.prettyQuery(query),
 			prettyConstant(query.Message.Constants[0]),
 		)
 	}
 	if len(query.Message.Constants) != 1 {
 		return fmt.Errorf(
 			"authentication query (%s) has more than one constant",
-			prettyQuery(query),
+			s.prettyQuery(query),
 		)
 	}
 	c := query.Message.Constants[0]
-	err := sanityQueriesCheckMessagePrincipals(query.Message)
+	err := s.sanityQueriesCheckMessagePrincipals(query.Message)
 	if err != nil {
 		return err
 	}
-	return sanityQueriesCheckKnown(query, query.Message, c, valKnowledgeMap)
+	return s.sanityQueriesCheckKnown(query, query.Message, c, valKnowledgeMap)
 }
 
-func sanityQueriesFreshness(query Query, valKnowledgeMap *KnowledgeMap) error {
+func (s *Session) sanityQueriesFreshness(query Query, valKnowledgeMap *KnowledgeMap) error {
 	i := valueGetKnowledgeMapIndexFromConstant(valKnowledgeMap, query.Constants[0])
 	if i < 0 {
 		return fmt.Errorf(
 			"freshness query (%s) refers to unknown constant (%s)",
-			prettyQuery(query),
+			s.prettyQuery(query),
 			prettyConstant(query.Constants[0]),
 		)
 	}
 	return nil
 }
 
-func sanityQueriesUnlinkability(query Query, valKnowledgeMap *KnowledgeMap) error {
+func (s *Session) sanityQueriesUnlinkability(query Query, valKnowledgeMap *KnowledgeMap) error {
 	if len(query.Constants) < 2 {
 		return fmt.Errorf(
 			"unlinkability query (%s) must specify at least two constants",
-			prettyQuery(query),
+			s.prettyQuery(query),
 		)
 	}
 	for i := 0; i < len(query.Constants); i++ {
@@ -260,24 +261,24 @@ func sanityQueriesUnlinkability(query Query, valKnowledgeMap *KnowledgeMap) erro
 		if ii < 0 {
 			return fmt.Errorf(
 				"unlinkability query (%s) refers to unknown constant (%s)",
-				prettyQuery(query), prettyConstant(query.Constants[i]),
+				s.prettyQuery(query), prettyConstant(query.Constants[i]),
 			)
 		}
 		if valueEquivalentConstantInConstants(query.Constants[i], query.Constants[:i]) >= 0 {
 			return fmt.Errorf(
 				"unlinkability query (%s) refers to same constant more than once (%s)",
-				prettyQuery(query), prettyConstant(query.Constants[i]),
+				s.prettyQuery(query), prettyConstant(query.Constants[i]),
 			)
 		}
 	}
 	return nil
 }
 
-func sanityQueriesEquivalence(query Query, valKnowledgeMap *KnowledgeMap) error {
+func (s *Session) sanityQueriesEquivalence(query Query, valKnowledgeMap *KnowledgeMap) error {
 	if len(query.Constants) < 2 {
 		return fmt.Errorf(
 			"equivalence query (%s) must specify at least two constants",
-			prettyQuery(query),
+			s.prettyQuery(query),
 		)
 	}
 	for i := 0; i < len(query.Constants); i++ {
@@ -285,35 +286,35 @@ func sanityQueriesEquivalence(query Query, valKnowledgeMap *KnowledgeMap) error
 		if ii < 0 {
 			return fmt.Errorf(
 				"equivalence query (%s) refers to unknown constant (%s)",
-				prettyQuery(query), prettyConstant(query.Constants[i]),
+				s.prettyQuery(query), prettyConstant(query.Constants[i]),
 			)
 		}
 		if valueEquivalentConstantInConstants(query.Constants[i], query.Constants[:i]) >= 0 {
 			return fmt.Errorf(
 				"equivalence query (%s) refers to same constant more than once (%s)",
-				prettyQuery(query), prettyConstant(query.Constants[i]),
+				s.prettyQuery(query), prettyConstant(query.Constants[i]),
 			)
 		}
 	}
 	return nil
 }
 
-func sanityQueryOptions(query Query, valKnowledgeMap *KnowledgeMap) error {
+func (s *Session) sanityQueryOptions(query Query, valKnowledgeMap *KnowledgeMap) error {
 	for _, option := range query.Options {
 		switch option.Kind {
 		case typesEnumPrecondition:
 			if len(option.Message.Constants) != 1 {
 				return fmt.Errorf(
 					"precondition option message (%s) has more than one constant",
-					prettyQuery(query),
+					s.prettyQuery(query),
 				)
 			}
 			c := option.Message.Constants[0]
-			err := sanityQueriesCheckMessagePrincipals(option.Message)
+			err := s.sanityQueriesCheckMessagePrincipals(option.Message)
 			if err != nil {
 				return err
 			}
-			return sanityQueriesCheckKnown(query, option.Message, c, valKnowledgeMap)
+			return s.sanityQueriesCheckKnown(query, option.Message, c, valKnowledgeMap)
 		default:
 			return fmt.Errorf("invalid query option kind")
 		}
@@ -321,17 +322,17 @@ func sanityQueryOptions(query Query, valKnowledgeMap *KnowledgeMap) error {
 	return nil
 }
 
-func sanityQueriesCheckMessagePrincipals(message Message) error {
+func (s *Session) sanityQueriesCheckMessagePrincipals(message Message) error {
 	if message.Sender == message.Recipient {
 		return fmt.Errorf(
 			"query with message (%s) has identical sender and recipient",
-			prettyMessage(Block{Kind: "message", Message: message}),
+			s.prettyMessage(Block{Kind: "message", Message: message}),
 		)
 	}
 	return nil
 }
 
-func sanityQueriesCheckKnown(query Query, m Message, c *Constant, valKnowledgeMap *KnowledgeMap) error {
+func (s *Session) sanityQueriesCheckKnown(query Query, m Message, c *Constant, valKnowledgeMap *KnowledgeMap) error {
 	senderKnows := false
 	recipientKnows := false
 	i := valueGetKnowledgeMapIndexFromConstant(
@@ -340,7 +341,7 @@ func sanityQueriesCheckKnown(query Query, m Message, c *Constant, valKnowledgeMa
 	if i < 0 {
 		return fmt.Errorf(
 			"query (%s) refers to unknown constant (%s)",
-			prettyQuery(query),
+			s.prettyQuery(query),
 			prettyConstant(m.Constants[0]),
 		)
 	}
@@ -364,19 +365,19 @@ func sanityQueriesCheckKnown(query Query, m Message, c *Constant, valKnowledgeMa
 	if !senderKnows {
 		return fmt.Errorf(
 			"authentication query (%s) depends on %s sending a constant (%s) that they do not know",
-			prettyQuery(query), principalGetNameFromID(m.Sender), prettyConstant(c),
+			s.prettyQuery(query), s.principalGetNameFromID(m.Sender), prettyConstant(c),
 		)
 	}
 	if !recipientKnows {
 		return fmt.Errorf(
 			"authentication query (%s) depends on %s receiving a constant (%s) that they never receive",
-			prettyQuery(query), principalGetNameFromID(m.Recipient), prettyConstant(c),
+			s.prettyQuery(query), s.principalGetNameFromID(m.Recipient), prettyConstant(c),
 		)
 	}
 	if !constantUsedByPrincipal {
 		return fmt.Errorf(
 			"authentication query (%s) depends on %s using a constant (%s) in a primitive, but this never happens",
-			prettyQuery(query), principalGetNameFromID(m.Recipient), prettyConstant(c),
+			s.prettyQuery(query), s.principalGetNameFromID(m.Recipient), prettyConstant(c),
 		)
 	}
 	return nil
@@ -385,11 +386,9 @@ func sanityQueriesCheckKnown(query Query, m Message, c *Constant, valKnowledgeMa
 func sanityDeclaredPrincipals(m Model) ([]string, []principalEnum, error) {
 	declaredNames := []string{}
 	declaredIDs := []principalEnum{}
-	principals := []principalEnum{}
 	for _, block := range m.Blocks {
 		switch block.Kind {
 		case "principal":
-			principals, _ = appendUniquePrincipalEnum(principals, block.Principal.ID)
 			declaredNames, _ = appendUniqueString(declaredNames, block.Principal.Name)
 			declaredIDs, _ = appendUniquePrincipalEnum(declaredIDs, block.Principal.ID)
 		}
@@ -397,20 +396,23 @@ func sanityDeclaredPrincipals(m Model) ([]string, []principalEnum, error) {
 	for _, block := range m.Blocks {
 		switch block.Kind {
 		case "message":
-			principals, _ = appendUniquePrincipalEnum(principals, block.Message.Sender)
-			principals, _ = appendUniquePrincipalEnum(principals, block.Message.Recipient)
+			if !principalEnumInSlice(block.Message.Sender, declaredIDs) ||
+				!principalEnumInSlice(block.Message.Recipient, declaredIDs) {
+				return []string{}, []principalEnum{}, modelErrorAt(
+					block.Position, fmt.Errorf("principal does not exist"),
+				)
+			}
 		}
 	}
 	for _, query := range m.Queries {
 		switch query.Kind {
 		case typesEnumAuthentication:
-			principals, _ = appendUniquePrincipalEnum(principals, query.Message.Sender)
-			principals, _ = appendUniquePrincipalEnum(principals, query.Message.Recipient)
-		}
-	}
-	for _, p := range principals {
-		if !principalEnumInSlice(p, declaredIDs) {
-			return []string{}, []principalEnum{}, fmt.Errorf("principal does not exist")
+			if !principalEnumInSlice(query.Message.Sender, declaredIDs) ||
+				!principalEnumInSlice(query.Message.Recipient, declaredIDs) {
+				return []string{}, []principalEnum{}, modelErrorAt(
+					query.Position, fmt.Errorf("principal does not exist"),
+				)
+			}
 		}
 	}
 	if len(declaredNames) > 64 {
@@ -422,10 +424,10 @@ func sanityDeclaredPrincipals(m Model) ([]string, []principalEnum, error) {
 func sanityFailOnFailedCheckedPrimitiveRewrite(failedRewrites []*Primitive) error {
 	for _, p := range failedRewrites {
 		if p.Check {
-			return fmt.Errorf(
+			return modelErrorAt(p.Position, fmt.Errorf(
 				"checked primitive fails: %s",
 				prettyPrimitive(p),
-			)
+			))
 		}
 	}
 	return nil
@@ -505,7 +507,7 @@ func sanityCheckEquationGenerators(a *Value) error {
 				err = sanityCheckEquationRootGenerator(va.Data.(*Equation))
 			}
 			if err != nil {
-				return err
+				return modelErrorAt(a.Data.(*Primitive).Position, err)
 			}
 		}
 	case typesEnumEquation:
@@ -0,0 +1,93 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+var sarifRules = []sarifRule{
+	{ID: "confidentiality", ShortDescription: sarifMessage{Text: "Confidentiality query fails."}},
+	{ID: "authentication", ShortDescription: sarifMessage{Text: "Authentication query fails."}},
+	{ID: "freshness", ShortDescription: sarifMessage{Text: "Freshness query fails."}},
+	{ID: "unlinkability", ShortDescription: sarifMessage{Text: "Unlinkability query fails."}},
+	{ID: "equivalence", ShortDescription: sarifMessage{Text: "Equivalence query fails."}},
+}
+
+// WriteReportSARIF writes the failed queries of a Report as a SARIF 2.1.0 log,
+// with uri identifying the model's file. Each failed query is reported at the
+// position of its declaration, with the values in the trace of its attack as
+// related locations at the positions of their declarations.
+func WriteReportSARIF(w io.Writer, r Report, uri string) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "Verifpal",
+			InformationURI: "https://verifpal.com",
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+	for _, q := range r.Queries {
+		if q.Status != VerifyStatusAttackFound {
+			continue
+		}
+		run.Results = append(run.Results, sarifQueryResult(q, uri))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+func sarifQueryResult(q ReportQuery, uri string) sarifResult {
+	result := sarifResult{
+		RuleID:    q.Kind,
+		RuleIndex: -1,
+		Level:     "error",
+		Message: sarifMessage{
+			Text: fmt.Sprintf("%s — %s", q.Query, q.Summary),
+		},
+		Locations:        []sarifLocation{sarifPositionLocation(q.Position, uri)},
+		RelatedLocations: []sarifLocation{},
+	}
+	for i, rule := range sarifRules {
+		if rule.ID == q.Kind {
+			result.RuleIndex = i
+		}
+	}
+	for i, step := range q.Trace {
+		id := i
+		text := fmt.Sprintf("%s → %s", step.Constant, step.Value)
+		if len(step.Note) > 0 {
+			text = fmt.Sprintf("%s ← %s", text, step.Note)
+		}
+		related := sarifPositionLocation(step.Position, uri)
+		related.ID = &id
+		related.Message = &sarifMessage{Text: text}
+		result.RelatedLocations = append(result.RelatedLocations, related)
+	}
+	return result
+}
+
+func sarifPositionLocation(position Position, uri string) sarifLocation {
+	location := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: uri},
+		},
+	}
+	if position.Line > 0 {
+		location.PhysicalLocation.Region = &sarifRegion{
+			StartLine:   position.Line,
+			StartColumn: position.Column,
+		}
+	}
+	return location
+}
@@ -0,0 +1,50 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestVerifyReportSARIF(t *testing.T) {
+	reports := NewReportCollector()
+	_, _, err := VerifyFile(
+		context.Background(), "../../examples/test/hmac_unguarded_bob.vp",
+		Options{Observers: []Observer{reports}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j bytes.Buffer
+	err = WriteReportSARIF(&j, reports.Reports()[0], "hmac_unguarded_bob.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded sarifLog
+	err = json.Unmarshal(j.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := decoded.Runs[0].Results
+	if len(results) != 1 || results[0].RuleID != "confidentiality" {
+		t.Fatalf("expected one confidentiality result, got %v", results)
+	}
+	region := results[0].Locations[0].PhysicalLocation.Region
+	if region == nil || region.StartLine != 36 {
+		t.Errorf("expected result to point to the query on line 36, got %v", region)
+	}
+	if len(results[0].RelatedLocations) == 0 {
+		t.Fatal("expected the attack trace as related locations")
+	}
+	related := results[0].RelatedLocations[0]
+	if related.Message == nil || !strings.HasPrefix(related.Message.Text, "b_public → G^nil ← mutated") ||
+		related.PhysicalLocation.Region == nil || related.PhysicalLocation.Region.StartLine != 12 {
+		t.Errorf("expected the mutated b_public declared on line 12, got %v", related)
+	}
+}
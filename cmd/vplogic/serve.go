@@ -0,0 +1,451 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+)
+
+const (
+	serveMaxModelSize      = 4 << 20
+	serveModelName         = "model.vp"
+	serveContentText       = "text/plain; charset=utf-8"
+	serveContentJSON       = "application/json"
+	serveJobIDByteCount    = 8
+	serveJobTTL            = time.Hour
+	serveMaxEndedJobs      = 1000
+	serveReadHeaderTimeout = 10 * time.Second
+	serveReadTimeout       = time.Minute
+	serveWriteTimeout      = 2 * time.Minute
+	serveIdleTimeout       = 2 * time.Minute
+)
+
+// NewServer returns an HTTP handler serving Verifpal's HTTP API, which
+// checks, pretty-prints, diagrams and translates models sent as request bodies,
+// and verifies them as asynchronous jobs. Up to parallel jobs run side by side
+// (GOMAXPROCS if parallel is zero), each within its own Session configured
+// with the given Options. Jobs are kept for jobTTL once they have ended (an hour
+// if jobTTL is zero), and only the serveMaxEndedJobs which ended last are kept.
+// Close cancels the jobs still queued or running.
+func NewServer(parallel int, jobTTL time.Duration, opts Options) *Server {
+	if parallel <= 0 {
+		parallel = runtime.GOMAXPROCS(0)
+	}
+	if jobTTL <= 0 {
+		jobTTL = serveJobTTL
+	}
+	server := &Server{
+		mux:     http.NewServeMux(),
+		opts:    opts,
+		jobTTL:  jobTTL,
+		slots:   make(chan struct{}, parallel),
+		jobs:    map[string]*ServeJob{},
+		cancels: map[string]context.CancelFunc{},
+		done:    map[string]chan struct{}{},
+	}
+	server.mux.HandleFunc("POST /parse", server.handleParse)
+	server.mux.HandleFunc("POST /pretty", server.handlePretty)
+	server.mux.HandleFunc("POST /diagram", server.handleDiagram)
+	server.mux.HandleFunc("POST /translate/{language}", server.handleTranslate)
+	server.mux.HandleFunc("POST /jobs", server.handleJobSubmit)
+	server.mux.HandleFunc("GET /jobs", server.handleJobList)
+	server.mux.HandleFunc("GET /jobs/{id}", server.handleJobGet)
+	server.mux.HandleFunc("DELETE /jobs/{id}", server.handleJobDelete)
+	return server
+}
+
+// ServeHTTP dispatches a request to the endpoint matching its method and path.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+// HTTPServer returns an http.Server serving the API on addr, with timeouts
+// preventing slow or idle clients from holding connections open indefinitely.
+func (server *Server) HTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           server,
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+		WriteTimeout:      serveWriteTimeout,
+		IdleTimeout:       serveIdleTimeout,
+	}
+}
+
+// Close cancels the jobs still queued or running and waits for them to end.
+func (server *Server) Close() {
+	server.mutex.Lock()
+	for _, cancel := range server.cancels {
+		cancel()
+	}
+	server.mutex.Unlock()
+	server.pending.Wait()
+}
+
+// serveModel reads the model sent as the request's body, along with its
+// file name, given by the name query parameter.
+func serveModel(w http.ResponseWriter, r *http.Request) (string, []byte, error) {
+	name := r.URL.Query().Get("name")
+	if len(name) == 0 {
+		name = serveModelName
+	}
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, serveMaxModelSize))
+	if err != nil {
+		return name, []byte{}, fmt.Errorf("cannot read model (%v)", err)
+	}
+	return name, b, nil
+}
+
+func serveWriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", serveContentJSON)
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+func serveWriteText(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", serveContentText)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, text)
+}
+
+// serveWriteError writes err as a ServeError, with the position at
+// which it occurs in the model if it is a ModelError.
+func serveWriteError(w http.ResponseWriter, status int, err error) {
+	serveError := ServeError{Error: err.Error()}
+	var modelErr *ModelError
+	if errors.As(err, &modelErr) && modelErr.Position.Line > 0 {
+		serveError.Position = &modelErr.Position
+	}
+	serveWriteJSON(w, status, serveError)
+}
+
+// sanity parses the model sent as the request's body and checks it, within a
+// Session configured with the server's Options, writing an error response and
+// returning false if either fails.
+func (server *Server) sanity(w http.ResponseWriter, r *http.Request) (*Session, Model, *KnowledgeMap, bool) {
+	s := NewSessionWithOptions(server.opts)
+	name, b, err := serveModel(w, r)
+	if err != nil {
+		serveWriteError(w, http.StatusBadRequest, err)
+		return s, Model{}, nil, false
+	}
+	m, err := s.ParseBytes(name, b)
+	if err != nil {
+		serveWriteError(w, http.StatusUnprocessableEntity, err)
+		return s, m, nil, false
+	}
+	valKnowledgeMap, _, err := s.Sanity(m)
+	if err != nil {
+		serveWriteError(w, http.StatusUnprocessableEntity, err)
+		return s, m, nil, false
+	}
+	return s, m, valKnowledgeMap, true
+}
+
+func (server *Server) handleParse(w http.ResponseWriter, r *http.Request) {
+	s, m, valKnowledgeMap, ok := server.sanity(w, r)
+	if !ok {
+		return
+	}
+	result := ServeModel{
+		Model:      m.FileName,
+		Attacker:   m.Attacker,
+		Principals: valKnowledgeMap.Principals,
+		Queries:    make([]ServeQuery, len(m.Queries)),
+	}
+	for i, query := range m.Queries {
+		result.Queries[i] = ServeQuery{
+			Query:    s.prettyQuery(query),
+			Kind:     reportQueryKind(query.Kind),
+			Position: query.Position,
+		}
+	}
+	serveWriteJSON(w, http.StatusOK, result)
+}
+
+func (server *Server) handlePretty(w http.ResponseWriter, r *http.Request) {
+	s := NewSessionWithOptions(server.opts)
+	name, b, err := serveModel(w, r)
+	if err != nil {
+		serveWriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	m, err := s.ParseBytes(name, b)
+	if err != nil {
+		serveWriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	pretty, err := s.PrettyModel(m)
+	if err != nil {
+		serveWriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	serveWriteText(w, pretty)
+}
+
+func (server *Server) handleDiagram(w http.ResponseWriter, r *http.Request) {
+	s := NewSessionWithOptions(server.opts)
+	name, b, err := serveModel(w, r)
+	if err != nil {
+		serveWriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	m, err := s.ParseBytes(name, b)
+	if err != nil {
+		serveWriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if len(format) == 0 {
+		format = "seqdiag"
+	}
+	if !strInSlice(format, DiagramFormats) {
+		serveWriteError(w, http.StatusBadRequest, fmt.Errorf("unsupported diagram format (%s)", format))
+		return
+	}
+	diagram, err := s.Diagram(m, format, nil)
+	if err != nil {
+		serveWriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	serveWriteText(w, diagram)
+}
+
+func (server *Server) handleTranslate(w http.ResponseWriter, r *http.Request) {
+	language := r.PathValue("language")
+	if language != "pv" && language != "coq" {
+		serveWriteError(w, http.StatusNotFound, fmt.Errorf("unsupported translation (%s)", language))
+		return
+	}
+	s, m, valKnowledgeMap, ok := server.sanity(w, r)
+	if !ok {
+		return
+	}
+	var translation string
+	var err error
+	switch language {
+	case "pv":
+		translation, err = s.pvModel(m, valKnowledgeMap)
+	case "coq":
+		translation, err = s.coqModel(m, valKnowledgeMap)
+	}
+	if err != nil {
+		serveWriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	serveWriteText(w, translation)
+}
+
+// handleJobSubmit queues the verification of the model sent as the request's
+// body. The model is parsed before the job is queued, so that a job is only
+// created for a model which can be parsed.
+func (server *Server) handleJobSubmit(w http.ResponseWriter, r *http.Request) {
+	name, b, err := serveModel(w, r)
+	if err != nil {
+		serveWriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	_, err = NewSessionWithOptions(server.opts).ParseBytes(name, b)
+	if err != nil {
+		serveWriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	id, err := serveJobID()
+	if err != nil {
+		serveWriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &ServeJob{
+		ID:        id,
+		Model:     name,
+		Status:    ServeJobQueued,
+		Submitted: time.Now(),
+	}
+	server.mutex.Lock()
+	server.expire(time.Now())
+	server.jobs[id] = job
+	server.cancels[id] = cancel
+	server.done[id] = make(chan struct{})
+	snapshot := *job
+	server.mutex.Unlock()
+	server.pending.Add(1)
+	go server.run(ctx, id, name, b)
+	w.Header().Set("Location", "/jobs/"+id)
+	serveWriteJSON(w, http.StatusAccepted, snapshot)
+}
+
+func (server *Server) handleJobList(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	server.expire(time.Now())
+	jobs := make([]ServeJob, 0, len(server.jobs))
+	for _, job := range server.jobs {
+		jobs = append(jobs, *job)
+	}
+	server.mutex.Unlock()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Submitted.Before(jobs[j].Submitted)
+	})
+	serveWriteJSON(w, http.StatusOK, jobs)
+}
+
+func (server *Server) handleJobGet(w http.ResponseWriter, r *http.Request) {
+	job, ok := server.job(r.PathValue("id"))
+	if !ok {
+		serveWriteError(w, http.StatusNotFound, fmt.Errorf("unknown job (%s)", r.PathValue("id")))
+		return
+	}
+	serveWriteJSON(w, http.StatusOK, job)
+}
+
+// handleJobDelete cancels a job which is queued or running, waits for it to
+// end and returns it. A job which had already ended is removed instead.
+func (server *Server) handleJobDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	server.mutex.Lock()
+	job, ok := server.jobs[id]
+	if !ok {
+		server.mutex.Unlock()
+		serveWriteError(w, http.StatusNotFound, fmt.Errorf("unknown job (%s)", id))
+		return
+	}
+	cancel, running := server.cancels[id]
+	done := server.done[id]
+	if !running {
+		server.remove(id)
+		server.mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	server.mutex.Unlock()
+	cancel()
+	select {
+	case <-done:
+	case <-r.Context().Done():
+		return
+	}
+	server.mutex.Lock()
+	snapshot := *job
+	server.mutex.Unlock()
+	serveWriteJSON(w, http.StatusOK, snapshot)
+}
+
+func (server *Server) job(id string) (ServeJob, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.expire(time.Now())
+	job, ok := server.jobs[id]
+	if !ok {
+		return ServeJob{}, false
+	}
+	return *job, true
+}
+
+// expire removes the jobs which ended longer than the job TTL before now and,
+// if more than serveMaxEndedJobs remain ended, the oldest of them.
+// It must be called with the server's mutex held.
+func (server *Server) expire(now time.Time) {
+	ended := []*ServeJob{}
+	for id, job := range server.jobs {
+		switch {
+		case job.Ended == nil:
+		case now.Sub(*job.Ended) > server.jobTTL:
+			server.remove(id)
+		default:
+			ended = append(ended, job)
+		}
+	}
+	if len(ended) <= serveMaxEndedJobs {
+		return
+	}
+	sort.Slice(ended, func(i, j int) bool {
+		return ended[i].Ended.Before(*ended[j].Ended)
+	})
+	for _, job := range ended[:len(ended)-serveMaxEndedJobs] {
+		server.remove(job.ID)
+	}
+}
+
+// remove forgets a job which has ended.
+// It must be called with the server's mutex held.
+func (server *Server) remove(id string) {
+	delete(server.jobs, id)
+	delete(server.done, id)
+}
+
+// run verifies a job's model once a slot is free, unless the job
+// is cancelled first, and records the outcome of the verification.
+func (server *Server) run(ctx context.Context, id string, name string, b []byte) {
+	defer server.pending.Done()
+	select {
+	case server.slots <- struct{}{}:
+	case <-ctx.Done():
+		server.finish(id, ServeJobCancelled, nil, nil)
+		return
+	}
+	defer func() { <-server.slots }()
+	if ctx.Err() != nil {
+		server.finish(id, ServeJobCancelled, nil, nil)
+		return
+	}
+	server.mutex.Lock()
+	server.jobs[id].Status = ServeJobRunning
+	server.mutex.Unlock()
+	reports := NewReportCollector()
+	opts := server.opts
+	opts.Observers = append(append([]Observer{}, opts.Observers...), reports)
+	_, _, err := VerifyBytes(ctx, name, b, opts)
+	collected := reports.Reports()
+	var report *Report
+	if len(collected) > 0 {
+		report = &collected[0]
+	}
+	switch {
+	case ctx.Err() != nil:
+		server.finish(id, ServeJobCancelled, report, nil)
+	case err != nil:
+		server.finish(id, ServeJobFailed, nil, err)
+	default:
+		server.finish(id, ServeJobCompleted, report, nil)
+	}
+}
+
+func (server *Server) finish(id string, status ServeJobStatus, report *Report, err error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	job := server.jobs[id]
+	ended := time.Now()
+	job.Status = status
+	job.Report = report
+	job.Ended = &ended
+	if err != nil {
+		job.Error = err.Error()
+	}
+	server.cancels[id]()
+	delete(server.cancels, id)
+	close(server.done[id])
+}
+
+func serveJobID() (string, error) {
+	b := make([]byte, serveJobIDByteCount)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("cannot generate job ID (%v)", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,139 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	fast, err := os.ReadFile("../../examples/test/hmac_ok.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, err := os.ReadFile("../../examples/test/signal_small_leaks.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	translatable, err := os.ReadFile("../../examples/test/exa.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(1, 0, Options{})
+	defer server.Close()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	request := func(method string, path string, body []byte) (int, []byte) {
+		req, err := http.NewRequest(method, httpServer.URL+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, b
+	}
+	job := func(method string, path string, body []byte, status int) ServeJob {
+		code, b := request(method, path, body)
+		if code != status {
+			t.Fatalf("%s %s: status %d, expected %d (%s)", method, path, code, status, b)
+		}
+		j := ServeJob{}
+		if err := json.Unmarshal(b, &j); err != nil {
+			t.Fatal(err)
+		}
+		return j
+	}
+	await := func(id string, status ServeJobStatus) ServeJob {
+		deadline := time.Now().Add(time.Minute)
+		for {
+			j := job(http.MethodGet, "/jobs/"+id, nil, http.StatusOK)
+			if j.Status == status {
+				return j
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s is %s, expected %s", id, j.Status, status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	code, b := request(http.MethodPost, "/parse?name=hmac_ok.vp", fast)
+	parsed := ServeModel{}
+	_ = json.Unmarshal(b, &parsed)
+	if code != http.StatusOK || parsed.Model != "hmac_ok.vp" || len(parsed.Principals) != 2 ||
+		len(parsed.Queries) != 2 || parsed.Queries[0].Kind != "confidentiality" {
+		t.Errorf("unexpected parse response: %d %s", code, b)
+	}
+	code, b = request(http.MethodPost, "/parse", []byte("attacker[active]\n["))
+	serveError := ServeError{}
+	_ = json.Unmarshal(b, &serveError)
+	if code != http.StatusUnprocessableEntity || serveError.Position == nil || serveError.Position.Line != 2 {
+		t.Errorf("unexpected parse error response: %d %s", code, b)
+	}
+	for _, test := range []struct {
+		path     string
+		model    []byte
+		expected string
+	}{
+		{"/pretty", fast, "attacker[active]"},
+		{"/diagram", fast, "Alice"},
+		{"/diagram?format=mermaid", fast, "sequenceDiagram"},
+		{"/translate/pv", fast, "process"},
+		{"/translate/coq", translatable, "Require Import"},
+	} {
+		code, b := request(http.MethodPost, test.path, test.model)
+		if code != http.StatusOK || !strings.Contains(string(b), test.expected) {
+			t.Errorf("%s: unexpected response: %d %s", test.path, code, b)
+		}
+	}
+	if code, _ := request(http.MethodPost, "/translate/tamarin", fast); code != http.StatusNotFound {
+		t.Errorf("unsupported translation: status %d, expected %d", code, http.StatusNotFound)
+	}
+	job(http.MethodPost, "/jobs", []byte("attacker[active]\n["), http.StatusUnprocessableEntity)
+
+	slowJob := job(http.MethodPost, "/jobs?name=signal_small_leaks.vp", slow, http.StatusAccepted)
+	await(slowJob.ID, ServeJobRunning)
+	fastJob := job(http.MethodPost, "/jobs?name=hmac_ok.vp", fast, http.StatusAccepted)
+	if j := job(http.MethodGet, "/jobs/"+fastJob.ID, nil, http.StatusOK); j.Status != ServeJobQueued {
+		t.Errorf("job %s is %s while another job runs, expected %s", j.ID, j.Status, ServeJobQueued)
+	}
+	if j := job(http.MethodDelete, "/jobs/"+slowJob.ID, nil, http.StatusOK); j.Status != ServeJobCancelled {
+		t.Errorf("cancelled job %s is %s", j.ID, j.Status)
+	}
+	completed := await(fastJob.ID, ServeJobCompleted)
+	if completed.Report == nil || completed.Report.Model != "hmac_ok.vp" ||
+		completed.Report.ResultsCode != "c0a0" || completed.Report.Status != VerifyStatusVerified {
+		t.Errorf("unexpected report for job %s: %+v", completed.ID, completed.Report)
+	}
+	if code, _ := request(http.MethodDelete, "/jobs/"+fastJob.ID, nil); code != http.StatusNoContent {
+		t.Errorf("removing job %s: status %d, expected %d", fastJob.ID, code, http.StatusNoContent)
+	}
+	job(http.MethodGet, "/jobs/"+fastJob.ID, nil, http.StatusNotFound)
+	jobs := []ServeJob{}
+	_, b = request(http.MethodGet, "/jobs", nil)
+	_ = json.Unmarshal(b, &jobs)
+	if len(jobs) != 1 || jobs[0].ID != slowJob.ID {
+		t.Errorf("unexpected jobs: %s", b)
+	}
+	server.mutex.Lock()
+	server.expire(time.Now().Add(2 * serveJobTTL))
+	server.mutex.Unlock()
+	job(http.MethodGet, "/jobs/"+slowJob.ID, nil, http.StatusNotFound)
+}
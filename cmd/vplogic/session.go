@@ -0,0 +1,154 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"context"
+	"os"
+)
+
+// NewSession returns a new Session with its own principal and value names,
+// attacker state and verification results. Its status messages are written
+// to the standard output, as with the Verifpal command-line interface.
+func NewSession() *Session {
+	return NewSessionWithOptions(DefaultOptions())
+}
+
+// DefaultOptions returns the Options used by the Verifpal command-line interface:
+// all status messages are written to the standard output, in color if supported.
+func DefaultOptions() Options {
+	return Options{
+		Output:    os.Stdout,
+		Color:     colorOutputSupport(),
+		Verbosity: VerbosityAnalysis,
+	}
+}
+
+// NewSessionWithOptions returns a new Session which reports on its progress
+// and results according to the given Options.
+func NewSessionWithOptions(opts Options) *Session {
+	observers := []Observer{}
+	if opts.Output != nil {
+		observers = append(observers, NewTerminalObserver(opts.Output, opts.Color, opts.Verbosity))
+	}
+	observers = append(observers, opts.Observers...)
+	return &Session{
+		principalNamesMap: map[string]principalEnum{
+			"Attacker": 0,
+		},
+		principalNamesMapCounter: 1,
+		valueNamesMap: map[string]valueEnum{
+			"g":   valueG.Data.(*Constant).ID,
+			"nil": valueNil.Data.(*Constant).ID,
+			"0":   valueZero.Data.(*Constant).ID,
+		},
+		valueNamesMapCounter: 3,
+		libpegUnnamedCounter: 0,
+		options:              opts,
+		observers:            observers,
+	}
+}
+
+// VerifyModel verifies a Verifpal model within a new Session configured
+// with the given Options. The model may have been parsed by any Session.
+// It returns a slice of verifyResults and a "results code". If ctx ends before the
+// analysis is complete, the partial results are returned along with ctx's error.
+func VerifyModel(ctx context.Context, m Model, opts Options) ([]VerifyResult, string, error) {
+	s := NewSessionWithOptions(opts)
+	s.modelAdopt(m)
+	return s.verifyModel(ctx, m)
+}
+
+// VerifyBytes parses and verifies a Verifpal model held in memory within
+// a new Session configured with the given Options. The name is used as the
+// model's file name in status messages and results.
+// It returns a slice of verifyResults and a "results code". If ctx ends before the
+// analysis is complete, the partial results are returned along with ctx's error.
+func VerifyBytes(ctx context.Context, name string, b []byte, opts Options) ([]VerifyResult, string, error) {
+	s := NewSessionWithOptions(opts)
+	m, err := s.ParseBytes(name, b)
+	if err != nil {
+		return []VerifyResult{}, "", err
+	}
+	return s.verifyModel(ctx, m)
+}
+
+// Parse parses a Verifpal model loaded from a file within this Session.
+func (s *Session) Parse(filePath string) (Model, error) {
+	return s.libpegParseModel(filePath, true)
+}
+
+// ParseBytes parses a Verifpal model held in memory within this Session.
+func (s *Session) ParseBytes(name string, b []byte) (Model, error) {
+	return s.libpegParseBytes(name, b)
+}
+
+// Sanity checks a Verifpal model parsed within this Session and returns
+// its KnowledgeMap and the initial PrincipalState of each principal.
+func (s *Session) Sanity(m Model) (*KnowledgeMap, []*PrincipalState, error) {
+	return s.sanity(m)
+}
+
+// Verify runs the main verification engine for Verifpal on a model parsed within this Session.
+// It returns a slice of verifyResults and a "results code". If ctx ends before the
+// analysis is complete, the partial results are returned along with ctx's error.
+func (s *Session) Verify(ctx context.Context, m Model) ([]VerifyResult, string, error) {
+	return s.verifyModel(ctx, m)
+}
+
+// Lint checks a Verifpal model parsed within this Session for likely modelling mistakes.
+// It returns the warnings found, ordered by their position in the model.
+func (s *Session) Lint(m Model) ([]LintWarning, error) {
+	return s.lint(m)
+}
+
+func (s *Session) modelAdopt(m Model) {
+	for _, blck := range m.Blocks {
+		switch blck.Kind {
+		case "principal":
+			s.principalNamesMapAdopt(blck.Principal.Name, blck.Principal.ID)
+			for _, expr := range blck.Principal.Expressions {
+				for _, c := range expr.Constants {
+					s.valueNamesMapAdopt(c)
+				}
+				if expr.Assigned != nil {
+					s.valueAdopt(expr.Assigned)
+				}
+			}
+		case "message":
+			for _, c := range blck.Message.Constants {
+				s.valueNamesMapAdopt(c)
+			}
+		}
+	}
+	for _, query := range m.Queries {
+		for _, c := range query.Constants {
+			s.valueNamesMapAdopt(c)
+		}
+		for _, c := range query.Message.Constants {
+			s.valueNamesMapAdopt(c)
+		}
+		for _, option := range query.Options {
+			for _, c := range option.Message.Constants {
+				s.valueNamesMapAdopt(c)
+			}
+		}
+	}
+}
+
+func (s *Session) valueAdopt(a *Value) {
+	switch a.Kind {
+	case typesEnumConstant:
+		s.valueNamesMapAdopt(a.Data.(*Constant))
+	case typesEnumPrimitive:
+		for _, aa := range a.Data.(*Primitive).Arguments {
+			s.valueAdopt(aa)
+		}
+	case typesEnumEquation:
+		for _, aa := range a.Data.(*Equation).Values {
+			s.valueAdopt(aa)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+/* SPDX-FileCopyrightText: © 2019-2022 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: GPL-3.0-only */
+// 00000000000000000000000000000000
+
+package vplogic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSessionIndependentNames(t *testing.T) {
+	s1 := NewSessionWithOptions(Options{})
+	_, err := s1.Parse("../../examples/test/hmac_ok.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2 := NewSessionWithOptions(Options{})
+	m, err := s2.Parse("../../examples/test/pke.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Blocks[0].Principal.ID != 1 {
+		t.Errorf("expected first principal to have ID 1, got %d", m.Blocks[0].Principal.ID)
+	}
+	c := m.Blocks[0].Principal.Expressions[0].Constants[0]
+	if c.ID != 3 {
+		t.Errorf("expected first constant (%s) to have ID 3, got %d", c.Name, c.ID)
+	}
+}
+
+func TestSessionConcurrentVerify(t *testing.T) {
+	models := []struct {
+		fileName    string
+		resultsCode string
+	}{
+		{"../../examples/test/pke.vp", "c0a0"},
+		{"../../examples/test/hmac_unguarded_bob.vp", "c1a0"},
+		{"../../examples/test/pke_unguarded_alice.vp", "c0a1"},
+	}
+	var wg sync.WaitGroup
+	for _, v := range models {
+		wg.Add(1)
+		go func(fileName string, expected string) {
+			defer wg.Done()
+			s := NewSessionWithOptions(Options{})
+			m, err := s.Parse(fileName)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, resultsCode, err := s.Verify(context.Background(), m)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resultsCode != expected {
+				t.Errorf("%s: expected %s, got %s", fileName, expected, resultsCode)
+			}
+		}(v.fileName, v.resultsCode)
+	}
+	wg.Wait()
+}
+
+func TestVerifyBytes(t *testing.T) {
+	b, err := os.ReadFile("../../examples/test/hmac_unguarded_bob.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	name := "a model generated in memory, whose name is well over sixty-four characters long"
+	verifyResults, resultsCode, err := VerifyBytes(context.Background(), name, b, Options{
+		Output:    &output,
+		Color:     false,
+		Verbosity: VerbosityResults,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultsCode != "c1a0" {
+		t.Errorf("expected c1a0, got %s", resultsCode)
+	}
+	if !verifyResults[0].Resolved || strings.Contains(verifyResults[0].Summary, "\x1b") {
+		t.Errorf("expected a resolved query with an uncolored summary, got %q", verifyResults[0].Summary)
+	}
+	if verifyResults[0].Status != VerifyStatusAttackFound || verifyResults[1].Status != VerifyStatusVerified {
+		t.Errorf("expected statuses attack and verified, got %s and %s", verifyResults[0].Status, verifyResults[1].Status)
+	}
+	if !verifyResults[1].Exhausted || verifyResults[1].Stage < 6 {
+		t.Errorf("expected the attacker to be exhausted past stage 5, got stage %d", verifyResults[1].Stage)
+	}
+	if strings.Contains(output.String(), "Verification initiated") {
+		t.Errorf("expected only results to be written, got %q", output.String())
+	}
+	if !strings.Contains(output.String(), "   Result • confidentiality? plaintext") {
+		t.Errorf("expected results to be written, got %q", output.String())
+	}
+}
+
+func TestVerifyModel(t *testing.T) {
+	s := NewSessionWithOptions(Options{})
+	_, err := s.Parse("../../examples/test/pke.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.Parse("../../examples/test/hmac_unguarded_bob.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resultsCode, err := VerifyModel(context.Background(), m, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultsCode != "c1a0" {
+		t.Errorf("expected c1a0, got %s", resultsCode)
+	}
+}
+
+func TestPrettyModel(t *testing.T) {
+	s := NewSessionWithOptions(Options{})
+	_, err := s.Parse("../../examples/test/pke.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.Parse("../../examples/test/hmac_unguarded_bob.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := s.PrettyModel(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pretty, err := PrettyModel(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pretty != expected {
+		t.Errorf("expected %q, got %q", expected, pretty)
+	}
+}
+
+func TestVerifyTimeout(t *testing.T) {
+	b, err := os.ReadFile("../../examples/messaging/signal.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	verifyResults, resultsCode, err := VerifyBytes(ctx, "signal.vp", b, Options{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected verification to stop promptly, took %s", elapsed)
+	}
+	if len(verifyResults) == 0 || len(resultsCode) != 2*len(verifyResults) {
+		t.Errorf("expected partial results for every query, got %d (%s)", len(verifyResults), resultsCode)
+	}
+	for _, verifyResult := range verifyResults {
+		if !verifyResult.Resolved && verifyResult.Status != VerifyStatusInconclusive {
+			t.Errorf("expected unresolved queries to be inconclusive, got %s", verifyResult.Status)
+		}
+		if verifyResult.Exhausted {
+			t.Errorf("expected the attacker not to be exhausted")
+		}
+	}
+	if !strings.Contains(resultsCode, "-") {
+		t.Errorf("expected results code to show inconclusive queries, got %s", resultsCode)
+	}
+}
+
+type cancelObserver struct {
+	stage  int
+	cancel context.CancelFunc
+}
+
+func (o cancelObserver) Observe(event Event) {
+	if e, ok := event.(EventStageStarted); ok && e.Stage == o.stage {
+		o.cancel()
+	}
+}
+
+func TestVerifyStage(t *testing.T) {
+	b, err := os.ReadFile("../../examples/messaging/signal.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	verifyResults, _, err := VerifyBytes(ctx, "signal.vp", b, Options{
+		Observers: []Observer{cancelObserver{stage: 1, cancel: cancel}},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected verification to be cancelled, got %v", err)
+	}
+	for _, verifyResult := range verifyResults {
+		if !verifyResult.Resolved && verifyResult.Stage != 0 {
+			t.Errorf("expected no stage to be completed, got stage %d", verifyResult.Stage)
+		}
+	}
+}
+
+type testObserver struct {
+	mutex sync.Mutex
+	kinds map[string]int
+	rules map[string]int
+}
+
+func (o *testObserver) Observe(event Event) {
+	o.mutex.Lock()
+	o.kinds[event.Kind()]++
+	switch e := event.(type) {
+	case EventAttackerLearned:
+		o.rules[e.Rule]++
+	}
+	o.mutex.Unlock()
+}
+
+func TestVerifyObservers(t *testing.T) {
+	o := &testObserver{kinds: map[string]int{}, rules: map[string]int{}}
+	s := NewSessionWithOptions(Options{Observers: []Observer{o}})
+	m, err := s.Parse("../../examples/test/hmac_unguarded_bob.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = s.Verify(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []string{
+		"message", "stageStarted", "mutationMapInitialized",
+		"attackerLearned", "queryResolved", "analysis",
+	} {
+		if o.kinds[kind] == 0 {
+			t.Errorf("expected %s events to be observed", kind)
+		}
+	}
+	if o.kinds["verificationCompleted"] != 1 {
+		t.Errorf("expected one verificationCompleted event, got %d", o.kinds["verificationCompleted"])
+	}
+	if o.rules["reconstruct"] == 0 {
+		t.Errorf("expected the attacker to learn values by reconstruction, got %v", o.rules)
+	}
+}
+
+func TestVerifyError(t *testing.T) {
+	s := NewSessionWithOptions(Options{})
+	m, err := s.Parse("../../examples/test/exa2.vp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resultsCode, err := s.Verify(context.Background(), m)
+	if err == nil || !strings.Contains(err.Error(), "checked primitive fails") {
+		t.Fatalf("expected checked primitive failure to be returned, got %v", err)
+	}
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Position.Line != 25 || modelErr.Position.Column != 8 {
+		t.Errorf("expected the failure to be positioned at 25:8, got %v", err)
+	}
+	if resultsCode != "" {
+		t.Errorf("expected no results code, got %s", resultsCode)
+	}
+}
+
+func TestModelError(t *testing.T) {
+	for _, c := range []struct {
+		source   []string
+		expected string
+		excerpt  string
+	}{
+		{
+			[]string{"attacker[active]", "principal Alice[", "\tc == HASH(k)", "]"},
+			"error.vp:3:2: invalid value assignment",
+			"\tc == HASH(k)\n\t^\n",
+		},
+		{
+			[]string{"attacker[active]", "principal Alice[", "\tknows private k", "\tgenerates k", "]", "queries[", "]"},
+			"error.vp:4:12: generated constant already exists (k)",
+			"\tgenerates k\n\t          ^\n",
+		},
+		{
+			[]string{"attacker[active]", "principal Alice[", "  knows private k", "  h = HASH(k, k, k, k, k, k)", "]", "queries[", "]"},
+			"error.vp:4:7: primitive has 6 inputs, expecting 1, 2, 3, 4, or 5",
+			"  h = HASH(k, k, k, k, k, k)\n      ^\n",
+		},
+		{
+			[]string{"attacker[active]", "principal Alice[", "\tknows private k", "]", "Alice -> Bob: k", "queries[", "]"},
+			"error.vp:5:1: principal does not exist",
+			"Alice -> Bob: k\n^\n",
+		},
+		{
+			[]string{"attacker[active]", "principal Alice[knows private k]", "queries[", "\tconfidentiality? x", "]"},
+			"error.vp:4:2: confidentiality query (confidentiality? x) refers to unknown constant (x)",
+			"\tconfidentiality? x\n\t^\n",
+		},
+		{
+			[]string{"attacker[active]", "principal Alice[", "\tgenerates a, b", "\tT = a + b + HASH(a]", "]"},
+			"error.vp:4:14: cannot use reserved keyword in Name: hash",
+			"\tT = a + b + HASH(a]\n\t            ^\n",
+		},
+		{
+			[]string{"attacker[active]", "principal Alice[", "\tgenerates a, b", "\tT = -a + b", "\th = HASH(a, a, a, a, a, a)", "]", "queries[", "]"},
+			"error.vp:5:6: primitive has 6 inputs, expecting 1, 2, 3, 4, or 5",
+			"\th = HASH(a, a, a, a, a, a)\n\t    ^\n",
+		},
+	} {
+		source := []byte(strings.Join(c.source, "\n"))
+		s := NewSessionWithOptions(Options{})
+		m, err := s.ParseBytes("error.vp", source)
+		if err == nil {
+			_, _, err = s.Sanity(m)
+		}
+		var modelErr *ModelError
+		if !errors.As(err, &modelErr) {
+			t.Errorf("expected a ModelError, got %v", err)
+			continue
+		}
+		if err.Error() != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, err.Error())
+		}
+		if modelErr.Excerpt(source) != c.excerpt {
+			t.Errorf("expected excerpt %q, got %q", c.excerpt, modelErr.Excerpt(source))
+		}
+		offset := modelErr.Position.Offset
+		lineStart := bytes.LastIndexByte(source[:offset], '\n') + 1
+		if bytes.Count(source[:offset], []byte("\n"))+1 != modelErr.Position.Line ||
+			utf8.RuneCount(source[lineStart:offset])+1 != modelErr.Position.Column {
+			t.Errorf("expected offset %d to be at %d:%d", offset, modelErr.Position.Line, modelErr.Position.Column)
+		}
+	}
+}
+
+func TestVerifyWorkers(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		_, resultsCode, err := VerifyFile(
+			context.Background(), "../../examples/test/hmac_unguarded_bob.vp",
+			Options{Workers: workers},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resultsCode != "c1a0" {
+			t.Errorf("expected c1a0 with %d workers, got %s", workers, resultsCode)
+		}
+	}
+}
+
+func TestVerifyDeterministic(t *testing.T) {
+	timestamps := regexp.MustCompile(` at \d\d:\d\d:\d\d [AP]M`)
+	outputs := []string{}
+	for i := 0; i < 3; i++ {
+		var output bytes.Buffer
+		valVerifyResults, resultsCode, err := VerifyFile(
+			context.Background(), "../../examples/test/ringsign_substitute.vp",
+			Options{Output: &output, Verbosity: VerbosityProgress, Workers: 1 + i, Deterministic: true},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resultsCode != "a1a0a1a1" {
+			t.Fatalf("expected a1a0a1a1, got %s", resultsCode)
+		}
+		for _, verifyResult := range valVerifyResults {
+			output.WriteString(verifyResult.Summary)
+		}
+		outputs = append(outputs, timestamps.ReplaceAllString(output.String(), ""))
+	}
+	for i := 1; i < len(outputs); i++ {
+		if outputs[i] != outputs[0] {
+			t.Errorf("expected deterministic run %d to match the first run", i+1)
+		}
+	}
+}
@@ -4,6 +4,17 @@
 
 package vplogic
 
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
 type typesEnum uint8
 
 const (
@@ -24,41 +35,802 @@ const (
 	typesEnumUnlinkability   typesEnum = iota
 	typesEnumEquivalence     typesEnum = iota
 	typesEnumPrecondition    typesEnum = iota
+	typesEnumPass            typesEnum = iota
+	typesEnumFail            typesEnum = iota
 )
 
 type valueEnum uint16
 
 type principalEnum uint8
 
+// Verbosity controls which status messages are written during verification.
+type Verbosity uint8
+
+const (
+	// VerbosityResults only writes warnings and query results.
+	VerbosityResults Verbosity = iota
+	// VerbosityProgress also writes parsing, deduction and analysis messages.
+	VerbosityProgress Verbosity = iota
+	// VerbosityAnalysis also writes the running analysis counter.
+	VerbosityAnalysis Verbosity = iota
+)
+
+// VerifyStatus indicates the outcome of the analysis of a query.
+type VerifyStatus uint8
+
+const (
+	// VerifyStatusInconclusive indicates that the analysis stopped, for example
+	// due to a timeout, before either finding an attack or exhausting the attacker.
+	VerifyStatusInconclusive VerifyStatus = iota
+	// VerifyStatusAttackFound indicates that the query fails: an attack was found.
+	VerifyStatusAttackFound VerifyStatus = iota
+	// VerifyStatusVerified indicates that no attack was found once the analysis
+	// completed, i.e. once the attacker could learn nothing more.
+	VerifyStatusVerified VerifyStatus = iota
+)
+
+// Options configures how a Session reports on a verification:
+//   - Output is where status messages are written. A nil Output discards them.
+//   - Color indicates whether status messages and result summaries use terminal colors.
+//   - Verbosity indicates which status messages are written.
+//   - Sinks receive the results of the verification once it completes, for example
+//     to archive them locally or to submit them to VerifHub. Failures to send
+//     results are returned as SinkErrors, alongside the verification's results.
+//   - Observers receive the events emitted during verification, alongside
+//     the terminal observer writing status messages to Output.
+//   - Workers is the number of principal states analyzed concurrently by the
+//     active attacker for each principal and stage. Zero means GOMAXPROCS.
+//   - Deterministic runs the active attacker's stages and analyses one at a time,
+//     in a fixed order, so that deductions, mutations and the reported attacks are
+//     the same across runs and machines. Workers is then ignored.
+type Options struct {
+	Output        io.Writer
+	Color         bool
+	Verbosity     Verbosity
+	Sinks         []ResultsSink
+	Observers     []Observer
+	Workers       int
+	Deterministic bool
+}
+
+// ResultsSink receives the results of each verification which completes,
+// such as the sinks returned by NewArchiveSink and NewVerifHubSink.
+// Name describes where the results are sent, for status messages.
+type ResultsSink interface {
+	Name() string
+	Submit(results Results) error
+}
+
+// Results are the results of a verification, as received by a ResultsSink:
+//   - FileName is the model's file name, and Model the pretty-printed model.
+//   - ResultsCode summarizes the outcome of each query, as returned by Verify.
+//   - Report summarizes the verification.
+type Results struct {
+	FileName    string
+	Model       string
+	ResultsCode string
+	Report      Report
+}
+
+// Observer receives the events emitted by a Session during verification.
+// Events are emitted concurrently by the analysis, so an Observer must be
+// safe for concurrent use.
+type Observer interface {
+	Observe(event Event)
+}
+
+// Event is one of the events emitted during verification:
+//   - EventMessage: a status message.
+//   - EventStageStarted: the active attacker analysis started a stage.
+//   - EventMutationMapInitialized: a principal's mutation map was initialized for a stage.
+//   - EventAttackerLearned: the attacker learned a new value.
+//   - EventQueryResolved: an attack was found against a query.
+//   - EventAnalysis: an analysis of a principal's state was completed.
+//   - EventVerificationCompleted: the verification of a model ended.
+//
+// Kind returns a short name for the event, such as "attackerLearned".
+type Event interface {
+	Kind() string
+}
+
+// EventMessage is a status message. Type is one of "verifpal", "info",
+// "analysis", "deduction", "result" or "warning". AnalysisCount is
+// positive if the message is to be shown alongside the analysis count.
+type EventMessage struct {
+	Type          string
+	Message       string
+	AnalysisCount int
+}
+
+// EventStageStarted indicates that the active attacker analysis started
+// the given stage within the given phase.
+type EventStageStarted struct {
+	Phase int
+	Stage int
+}
+
+// EventMutationMapInitialized indicates that a principal's mutation map was
+// initialized for a stage: Constants is the number of constants the attacker
+// may mutate and Mutations is the total number of candidate mutations.
+type EventMutationMapInitialized struct {
+	Stage     int
+	Principal string
+	Constants int
+	Mutations int
+}
+
+// EventAttackerLearned indicates that the attacker learned Value through Rule,
+// which is one of "decompose", "recompose", "reconstruct", "equivalize",
+// "password" or "concat". Message describes the deduction.
+type EventAttackerLearned struct {
+	Value         string
+	Rule          string
+	Message       string
+	AnalysisCount int
+}
+
+// EventQueryResolved indicates that an attack was found against Query.
+type EventQueryResolved struct {
+	Query         string
+	Result        VerifyResult
+	AnalysisCount int
+}
+
+// EventAnalysis indicates that an analysis was completed during Stage,
+// bringing the number of completed analyses to AnalysisCount.
+type EventAnalysis struct {
+	Stage         int
+	AnalysisCount int
+}
+
+// EventVerificationCompleted indicates that the verification of a model ended,
+// with Report summarizing the verification and Results holding the result of
+// each of the model's queries, in the same order as Report.Queries.
+type EventVerificationCompleted struct {
+	Report  Report
+	Results []VerifyResult
+}
+
+// Report summarizes the verification of a model:
+//   - Model is the model's file name and Attacker its attacker type.
+//   - Status is VerifyStatusAttackFound if any query failed, VerifyStatusInconclusive
+//     if any query was left inconclusive and VerifyStatusVerified otherwise.
+//   - ResultsCode is the "results code" of the verification, eg. "c1a0".
+//   - Interrupted indicates whether verification stopped before completion.
+//   - Stage is the deepest stage completed by the analysis and AnalysisCount the
+//     number of analyses completed.
+//   - Started and Completed are the times at which verification started and ended,
+//     and Seconds the duration of the verification.
+//   - Queries contains the result of each of the model's queries.
+type Report struct {
+	Model         string        `json:"model"`
+	Attacker      string        `json:"attacker"`
+	Status        VerifyStatus  `json:"status"`
+	ResultsCode   string        `json:"resultsCode"`
+	Interrupted   bool          `json:"interrupted"`
+	Stage         int           `json:"stage"`
+	AnalysisCount int           `json:"analysisCount"`
+	Started       time.Time     `json:"started"`
+	Completed     time.Time     `json:"completed"`
+	Seconds       float64       `json:"seconds"`
+	Queries       []ReportQuery `json:"queries"`
+}
+
+// ReportQuery summarizes the result of a query within a Report:
+//   - Query is the pretty-printed query and Kind its kind, eg. "confidentiality".
+//   - Status indicates whether the query failed, verified or was left inconclusive.
+//   - Summary explains the attack found against the query, if any, without colors.
+//   - Stage is the stage at which the attack was found or, if no attack was found,
+//     the deepest stage completed by the analysis.
+//   - Exhausted indicates whether the analysis ended because the attacker could learn nothing more.
+//   - Preconditions contains the result of each of the query's preconditions, if it failed.
+//   - Position indicates where the query is declared in the model.
+//   - Trace contains the values shown in the Summary of the attack, if any.
+type ReportQuery struct {
+	Query         string               `json:"query"`
+	Kind          string               `json:"kind"`
+	Status        VerifyStatus         `json:"status"`
+	Summary       string               `json:"summary"`
+	Stage         int                  `json:"stage"`
+	Exhausted     bool                 `json:"exhausted"`
+	Preconditions []ReportPrecondition `json:"preconditions"`
+	Position      Position             `json:"position"`
+	Trace         []ReportTraceStep    `json:"trace"`
+}
+
+// ReportTraceStep describes a value within the trace of an attack in a Report:
+//   - Constant is the name of the constant to which the value is assigned, and
+//     Position indicates where that constant is declared in the model.
+//   - Value is the pretty-printed value.
+//   - Note explains the role of the value in the attack, eg. "obtained by Attacker".
+type ReportTraceStep struct {
+	Constant string   `json:"constant"`
+	Value    string   `json:"value"`
+	Note     string   `json:"note"`
+	Position Position `json:"position"`
+}
+
+// ReportPrecondition summarizes the result of a query's precondition within a Report:
+//   - Precondition is the pretty-printed precondition message, eg. "Alice -> Bob: m".
+//   - Satisfied indicates whether the message was sent despite the query failing,
+//     in which case Summary explains so.
+type ReportPrecondition struct {
+	Precondition string `json:"precondition"`
+	Satisfied    bool   `json:"satisfied"`
+	Summary      string `json:"summary"`
+}
+
+// BatchReport summarizes the verification of a batch of models:
+//   - Seconds is the duration of the whole batch.
+//   - Results contains the outcome of each model, in the order in which the models were given.
+type BatchReport struct {
+	Seconds float64       `json:"seconds"`
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of verifying one of the models of a batch:
+//   - FilePath is the path of the model's file.
+//   - Report summarizes the verification, unless it could not be completed.
+//   - Error explains why the verification could not be completed, or why its
+//     results could not be sent to the ResultsSinks, if so.
+type BatchResult struct {
+	FilePath string  `json:"filePath"`
+	Report   *Report `json:"report,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// ReportCollector is an Observer which collects the Report of each verification it observes.
+type ReportCollector struct {
+	mutex   sync.Mutex
+	reports []Report
+}
+
+// diagramStep is one of the steps of a sequence diagram rendered by Diagram,
+// with Kind being the kind of the model block from which it is rendered:
+//   - A principal block is rendered as a note over the Principal's lifeline
+//     holding its Expressions, pretty-printed.
+//   - A message block is rendered as an arrow from Sender to Recipient labelled
+//     with its pretty-printed Constants, with the values to which the attacker
+//     mutated these constants, if any, in Mutations.
+//   - A phase block is rendered as a separator for the given Phase.
+type diagramStep struct {
+	Kind        string
+	Principal   string
+	Expressions []string
+	Sender      string
+	Recipient   string
+	Constants   string
+	Mutations   []string
+	Phase       int
+}
+
+// ArchiveEntry holds the results of a verification within a results archive:
+//   - Model is the model's file name, Source the pretty-printed model and
+//     ModelHash the SHA-256 hash of Source, which changes whenever the model does.
+//   - ResultsCode summarizes the outcome of each query, as returned by Verify.
+//   - Timestamp is when the verification completed.
+//   - Report summarizes the verification, including the summary of each query.
+type ArchiveEntry struct {
+	Model       string    `json:"model"`
+	ModelHash   string    `json:"modelHash"`
+	Source      string    `json:"source"`
+	ResultsCode string    `json:"resultsCode"`
+	Timestamp   time.Time `json:"timestamp"`
+	Report      Report    `json:"report"`
+}
+
+// archiveSink is a ResultsSink writing results to the archive in dir.
+type archiveSink struct {
+	dir string
+}
+
+// verifHubSink is a ResultsSink submitting models to VerifHub.
+type verifHubSink struct{}
+
+// sarifLog and the types it contains represent the subset of the SARIF 2.1.0
+// format used to export the failed queries of a Report.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        int             `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+// lspServer holds the state of a Language Server Protocol server started by LSP:
+//   - Reader and Writer carry the messages exchanged with the client.
+//   - Documents holds the models opened by the client, by URI.
+//   - Shutdown indicates whether the client has requested the server to shut down.
+type lspServer struct {
+	Reader    *bufio.Reader
+	Writer    io.Writer
+	Documents map[string]*lspDocument
+	Shutdown  bool
+}
+
+// lspDocument represents a model opened by a Language Server Protocol client:
+//   - Text is the model's source, as last sent by the client.
+//   - Session is the Session within which the model was last parsed.
+//   - Model is the parsed model, and Parsed whether it could be parsed.
+//   - KnowledgeMap is the model's KnowledgeMap, or nil if the model is not sane.
+//   - Err is the error which prevented the model from being parsed or checked, if any.
+type lspDocument struct {
+	Text         string
+	Session      *Session
+	Model        Model
+	Parsed       bool
+	KnowledgeMap *KnowledgeMap
+	Err          error
+}
+
+// lspToken represents an identifier or symbol within the source of a model,
+// with Offset, Line and Column indicating where it begins.
+type lspToken struct {
+	Text   string
+	Offset int
+	Line   int
+	Column int
+}
+
+// lspPrincipalOccurrence represents an occurrence of a principal's name within the
+// source of a model, with Declaration indicating whether it is the first declaration
+// of the principal.
+type lspPrincipalOccurrence struct {
+	Token       lspToken
+	Declaration bool
+}
+
+// jsonRPCServer holds the state of a JSON-RPC server started by JSONRPC:
+//   - Reader and Writer carry the messages exchanged with the client, with
+//     WriteMutex ensuring that messages are written one at a time.
+//   - Cancels holds the function cancelling each request being processed, by request ID.
+//   - Pending tracks the requests being processed.
+type jsonRPCServer struct {
+	Reader       *bufio.Reader
+	Writer       io.Writer
+	WriteMutex   sync.Mutex
+	Cancels      map[string]context.CancelFunc
+	CancelsMutex sync.Mutex
+	Pending      sync.WaitGroup
+}
+
+// jsonRPCProgress is an Observer which sends the events emitted during the
+// verification requested by ID as progress notifications. EventAnalysis events,
+// which are the most frequent, are sent at most once per jsonRPCProgressInterval.
+type jsonRPCProgress struct {
+	Server       *jsonRPCServer
+	ID           *json.RawMessage
+	mutex        sync.Mutex
+	lastAnalysis time.Time
+}
+
+// jsonRPCMessage and the types it contains represent the messages of the
+// JSON-RPC 2.0 protocol, as exchanged by JSONRPC and LSP.
+type jsonRPCMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *jsonRPCError    `json:"error,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRPCModelParams struct {
+	Model string `json:"model"`
+}
+
+type jsonRPCValueParams struct {
+	Value json.RawMessage `json:"value"`
+}
+
+type jsonRPCQueryParams struct {
+	Query json.RawMessage `json:"query"`
+}
+
+type jsonRPCCancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
+
+type jsonRPCProgressParams struct {
+	ID    *json.RawMessage `json:"id"`
+	Kind  string           `json:"kind"`
+	Event Event            `json:"event"`
+}
+
+// Server is an http.Handler serving Verifpal's HTTP API, created by NewServer:
+//   - opts configures the Session within which each job is verified, and slots
+//     holds a token for each job running, limiting how many run side by side.
+//   - jobs holds every job by ID, cancels the function cancelling each job which
+//     is queued or running, and done a channel closed once each job ends.
+//   - jobTTL is how long a job is kept once it has ended.
+//   - pending tracks the jobs which are queued or running.
+type Server struct {
+	mux     *http.ServeMux
+	opts    Options
+	jobTTL  time.Duration
+	slots   chan struct{}
+	jobs    map[string]*ServeJob
+	cancels map[string]context.CancelFunc
+	done    map[string]chan struct{}
+	mutex   sync.Mutex
+	pending sync.WaitGroup
+}
+
+// ServeJobStatus indicates the progress of a verification job submitted to a Server.
+type ServeJobStatus string
+
+const (
+	// ServeJobQueued indicates that the job waits for another job to end before running.
+	ServeJobQueued ServeJobStatus = "queued"
+	// ServeJobRunning indicates that the job's model is being verified.
+	ServeJobRunning ServeJobStatus = "running"
+	// ServeJobCompleted indicates that the verification ended, with its Report.
+	ServeJobCompleted ServeJobStatus = "completed"
+	// ServeJobFailed indicates that the verification could not be completed.
+	ServeJobFailed ServeJobStatus = "failed"
+	// ServeJobCancelled indicates that the job was cancelled, with the Report
+	// of the partial verification if it had started.
+	ServeJobCancelled ServeJobStatus = "cancelled"
+)
+
+// ServeJob is a verification job submitted to a Server:
+//   - ID identifies the job, and Model is the model's file name.
+//   - Status indicates the progress of the job, Submitted when it was submitted
+//     and Ended when it ended, if it has.
+//   - Report summarizes the verification, once it has ended.
+//   - Error explains why the verification could not be completed, if so.
+type ServeJob struct {
+	ID        string         `json:"id"`
+	Model     string         `json:"model"`
+	Status    ServeJobStatus `json:"status"`
+	Submitted time.Time      `json:"submitted"`
+	Ended     *time.Time     `json:"ended,omitempty"`
+	Report    *Report        `json:"report,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// ServeModel describes a model checked by a Server:
+//   - Model is the model's file name and Attacker its attacker type.
+//   - Principals contains the names of the model's principals.
+//   - Queries contains each of the model's queries.
+type ServeModel struct {
+	Model      string       `json:"model"`
+	Attacker   string       `json:"attacker"`
+	Principals []string     `json:"principals"`
+	Queries    []ServeQuery `json:"queries"`
+}
+
+// ServeQuery describes a query within a ServeModel: Query is the pretty-printed
+// query, Kind its kind and Position where it is declared in the model.
+type ServeQuery struct {
+	Query    string   `json:"query"`
+	Kind     string   `json:"kind"`
+	Position Position `json:"position"`
+}
+
+// ServeError is the body of a Server's error responses, with Position
+// indicating where the error occurs in the model, where known.
+type ServeError struct {
+	Error    string    `json:"error"`
+	Position *Position `json:"position,omitempty"`
+}
+
+// lspPosition and the types which follow represent the subset of
+// the Language Server Protocol 3.17 used by LSP.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspTextDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument lspTextDocumentItem `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []lspContentChange        `json:"contentChanges"`
+}
+
+type lspContentChange struct {
+	Text string `json:"text"`
+}
+
+type lspDidCloseParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	Position     lspPosition               `json:"position"`
+	Context      lspReferenceContext       `json:"context"`
+}
+
+type lspReferenceContext struct {
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+type lspFormattingParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+}
+
+type lspPublishDiagnosticsParams struct {
+	URI         string          `json:"uri"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+type lspHover struct {
+	Contents lspMarkupContent `json:"contents"`
+	Range    lspRange         `json:"range"`
+}
+
+type lspMarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+// junitTestSuites and the types it contains represent the JUnit XML format
+// used to export Reports, with one test suite per model and one test case per query.
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",cdata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// terminalObserver writes the events emitted during verification as status
+// messages, in the format used by the Verifpal command-line interface.
+type terminalObserver struct {
+	output    io.Writer
+	color     bool
+	verbosity Verbosity
+	mutex     sync.Mutex
+}
+
+// Session contains all of the state required to parse, check and verify
+// a Verifpal model. Sessions are independent of one another, which allows
+// many models to be verified concurrently within the same process.
+// A Model may only be used with the Session that parsed it (VerifyModel
+// takes care of this), and a Session may only verify one model at a time.
+//   - principalNamesMap and valueNamesMap map principal and constant names
+//     to their internal IDs.
+//   - libpegUnnamedCounter tracks the number of unnamed (`_`) constants parsed.
+//   - attackerState contains the attacker's state during model analysis.
+//   - verifyResults contains the verification results for the model's queries.
+//   - verifyAnalysisCount tracks the number of analyses performed.
+//   - verifyAnalysisStage tracks the deepest stage completed by the analysis.
+//   - options indicates how the Session reports on its progress and results.
+//   - observers receive the Session's events, starting with the terminal observer.
+type Session struct {
+	principalNamesMap        map[string]principalEnum
+	principalNamesMapCounter principalEnum
+	principalNamesMutex      sync.Mutex
+	valueNamesMap            map[string]valueEnum
+	valueNamesMapCounter     valueEnum
+	valueNamesMutex          sync.Mutex
+	libpegUnnamedCounter     int
+	attackerState            AttackerState
+	attackerStateMutex       sync.Mutex
+	verifyResults            []VerifyResult
+	verifyResultsFileName    string
+	verifyResultsMutex       sync.Mutex
+	verifyAnalysisCount      uint32
+	verifyAnalysisStage      uint32
+	options                  Options
+	observers                []Observer
+}
+
 // Model is the main parsed representation of the Verifpal model.
+// Comments holds the comments before the attacker declaration (Leading)
+// and after the queries (Trailing), and QueriesComments those before
+// the queries declaration (Leading) and after its last query (Trailing).
 type Model struct {
-	FileName string
-	Attacker string
-	Blocks   []Block
-	Queries  []Query
+	FileName        string
+	Attacker        string
+	Blocks          []Block
+	Queries         []Query
+	Comments        Trivia
+	QueriesComments Trivia
 }
 
-// VerifyResult contains the verification results for a particular query.
+// VerifyResult contains the verification results for a particular query:
+//   - Resolved indicates whether an attack was found against the query.
+//   - Status indicates whether the query was found to fail, verified or left inconclusive.
+//   - Stage indicates the stage at which the attack was found or, if no attack
+//     was found, the deepest stage completed by the analysis.
+//   - Exhausted indicates whether the analysis ended because the attacker
+//     could learn nothing more.
+//   - Trace contains the values shown in the Summary of the attack, if any.
 type VerifyResult struct {
-	Query    Query
-	Resolved bool
-	Summary  string
-	Options  []QueryOptionResult
+	Query     Query
+	Resolved  bool
+	Summary   string
+	Options   []QueryOptionResult
+	Status    VerifyStatus
+	Stage     int
+	Exhausted bool
+	Trace     []VerifyTraceStep
+}
+
+// VerifyTraceStep describes a value within the trace of an attack:
+//   - Constant is the constant to which the value is assigned.
+//   - Value is the pretty-printed value.
+//   - Note explains the role of the value in the attack, eg. "obtained by Attacker",
+//     and is empty if the value plays no particular role.
+type VerifyTraceStep struct {
+	Constant *Constant
+	Value    string
+	Note     string
+}
+
+// ExpectResult compares the outcome of a query with the outcome it is annotated
+// as expected to have, using a "// expect: pass" or "// expect: fail" comment:
+//   - Model is the model's file name and Query the pretty-printed query, without its options.
+//   - Expected is VerifyStatusVerified for "pass" and VerifyStatusAttackFound for "fail".
+//   - Status is the outcome of the query.
+//   - Pass indicates whether Status matches Expected.
+type ExpectResult struct {
+	Model    string
+	Query    string
+	Expected VerifyStatus
+	Status   VerifyStatus
+	Pass     bool
+}
+
+// LintWarning represents a likely modelling mistake found by Lint.
+//   - Position is the location in the model source to which the warning refers.
+//   - Rule is a short name for the kind of warning, such as "unchecked-primitive".
+//   - Message describes the warning.
+type LintWarning struct {
+	Position Position `json:"position"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
 }
 
 // Block represents a principal, message or phase declaration in a Verifpal model.
+// Position is where the declaration begins in the model source.
 type Block struct {
 	Kind      string
+	Position  Position
 	Principal Principal
 	Message   Message
 	Phase     Phase
+	Comments  Trivia
 }
 
-// Principal represents a principal declaration in a Verifpal model.
+// Principal represents a principal declaration in a Verifpal model,
+// with Comments holding the comments after its last expression.
 type Principal struct {
 	Name        string
 	ID          principalEnum
 	Expressions []Expression
+	Comments    []Comment
 }
 
 // Message represents a message declaration in a Verifpal model.
@@ -73,18 +845,24 @@ type Phase struct {
 	Number int
 }
 
-// Query represents a query declaration in a Verifpal model.
+// Query represents a query declaration in a Verifpal model,
+// with Position indicating where it is declared and Expect the outcome
+// it is annotated as expected to have (typesEnumPass or typesEnumFail), if any.
 type Query struct {
 	Kind      typesEnum
+	Position  Position
 	Constants []*Constant
 	Message   Message
 	Options   []QueryOption
+	Expect    typesEnum
+	Comments  Trivia
 }
 
 // QueryOption represents a query option (i.e. precondition) declaration in a Verifpal model.
 type QueryOption struct {
-	Kind    typesEnum
-	Message Message
+	Kind     typesEnum
+	Message  Message
+	Comments Trivia
 }
 
 // QueryOptionResult represents the analysis result of a QueryOption.
@@ -99,11 +877,46 @@ type QueryOptionResult struct {
 // - "generates": `generates [constants]`, eg. "generates x, y"
 // - "assignment": `[constants] = [value]`, eg. "x, y = HKDF(a, b, c)"
 // - "leaks": `leaks [constants]`, eg. "leaks x"
+// Position is where the expression begins in the model source.
 type Expression struct {
 	Kind      typesEnum
+	Position  Position
 	Qualifier typesEnum
 	Constants []*Constant
 	Assigned  *Value
+	Comments  Trivia
+}
+
+// Comment represents a `//` comment in a Verifpal model, with Text holding
+// what follows the `//` and Position where the comment begins.
+type Comment struct {
+	Text     string
+	Position Position
+}
+
+// Trivia represents the comments attached to a declaration in a Verifpal model:
+//   - Leading holds the comments on the lines before the declaration.
+//   - Trailing holds the comment on the same line after the declaration, if any.
+type Trivia struct {
+	Leading  []Comment
+	Trailing []Comment
+}
+
+// formatDiffOp represents a line of a diff between the source of a model and its formatted version:
+//   - Kind is ' ' for a line found in both, '-' for a removed line and '+' for an added line.
+//   - Line is the line, including its terminating newline if it has one.
+//   - A and B are the indices of the line in the source and formatted version at which the operation occurs.
+type formatDiffOp struct {
+	Kind byte
+	Line string
+	A    int
+	B    int
+}
+
+// libpegQueries represents a parsed queries declaration, before it is added to its Model.
+type libpegQueries struct {
+	Queries  []Query
+	Comments Trivia
 }
 
 // Value represents either a constant, primitive or equation expression.
@@ -120,6 +933,8 @@ type Value struct {
 // - Leaked indicates if this constant has been leaked.
 // - Declaration indicates how the constant was declared.
 // - Qualifier indicates the "knows" qualifier (eg. "private").
+// - Position indicates where the constant appears in the model or, within a
+// knowledge map, where it is declared. It is zero for constants not parsed from a model.
 type Constant struct {
 	Name        string
 	ID          valueEnum
@@ -128,6 +943,50 @@ type Constant struct {
 	Leaked      bool
 	Declaration typesEnum
 	Qualifier   typesEnum
+	Position    Position
+}
+
+// Position represents a position within the source of a Verifpal model:
+// Line and Column both start at 1, and are 0 if the position is unknown.
+// Offset is the number of bytes preceding the position in the model source.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// sourceMap maps positions in a model rewritten by preprocessModel back to
+// the model source:
+//   - lines holds each line of the model source.
+//   - starts and processedStarts hold the offset at which each line begins
+//     in the model source and in the rewritten model.
+//   - offsets holds, for each byte of each rewritten line, its offset within
+//     the source line, followed by the length of the source line.
+type sourceMap struct {
+	lines           []string
+	starts          []int
+	processedStarts []int
+	offsets         [][]int
+}
+
+// ModelError represents an error found in a Verifpal model while parsing, checking
+// or analyzing it:
+//   - FileName is the model's file name, if known.
+//   - Position is where the error occurs in the model source, or zero if unknown.
+//   - Err is the underlying error.
+type ModelError struct {
+	FileName string
+	Position Position
+	Err      error
+}
+
+// SinkError represents the failure to send the results of a completed
+// verification to one of a Session's ResultsSinks:
+//   - Sink is the name of the ResultsSink.
+//   - Err is the underlying error.
+type SinkError struct {
+	Sink string
+	Err  error
 }
 
 // Primitive represents a primitive expression:
@@ -135,11 +994,14 @@ type Constant struct {
 // - Arguments indicates the arguments of the primitive.
 // - Output indicates which output value of the primitive this copy should rewrite to (starts at 0).
 // - Check indicates whether this has been a checked primitive.
+// - Position indicates where the primitive appears in the model, or is zero
+// for primitives not parsed from a model.
 type Primitive struct {
 	ID        primitiveEnum
 	Arguments []*Value
 	Output    int
 	Check     bool
+	Position  Position
 }
 
 // Equation represents an equation expression.
@@ -204,6 +1066,8 @@ type KnowledgeMap struct {
 //   - MutatableTo tracks the principal for whom it is possible for this value to ever be mutated.
 //   - BeforeMutate tracks the value before it was mutated.
 //   - Phase documents at which phase the constant was declared.
+//   - shared tracks which of the columns written to during analysis are still shared
+//     with the PrincipalState from which this one was cloned.
 type PrincipalState struct {
 	Name          string
 	ID            principalEnum
@@ -223,8 +1087,24 @@ type PrincipalState struct {
 	MutatableTo   [][]principalEnum
 	BeforeMutate  []*Value
 	Phase         [][]int
+	shared        principalStateColumn
 }
 
+// principalStateColumn identifies the PrincipalState columns which may be written
+// to during analysis, and which are therefore copied on write by clones.
+type principalStateColumn uint8
+
+const (
+	principalStateColumnAssigned principalStateColumn = 1 << iota
+	principalStateColumnCreator
+	principalStateColumnSender
+	principalStateColumnRewritten
+	principalStateColumnBeforeRewrite
+	principalStateColumnMutated
+	principalStateColumnBeforeMutate
+	principalStateColumnAll principalStateColumn = 1<<iota - 1
+)
+
 // DecomposeRule contains a primitive's DecomposeRule.
 type DecomposeRule struct {
 	HasRule bool
@@ -296,12 +1176,24 @@ type PrimitiveSpec struct {
 //   - Known tracks the values learned by the attacker.
 //   - PrincipalState contains a snapshot of the principal's PrincipalState at the moment
 //     where the corresponding value in Known was learned by the attacker.
+//   - knownIndex indexes Known by valueHash, and is shared by all copies of the
+//     AttackerState taken during the same phase.
 type AttackerState struct {
 	Active         bool
 	CurrentPhase   int
 	Exhausted      bool
 	Known          []*Value
 	PrincipalState []*PrincipalState
+	knownIndex     *attackerKnownIndex
+}
+
+// attackerKnownIndex maps the valueHash of each value learned by the attacker
+// to its indices in AttackerState.Known, in ascending order. Since Known is
+// only ever appended to, an index is valid for every copy of the AttackerState
+// whose Known slice is long enough to contain it.
+type attackerKnownIndex struct {
+	mutex  sync.RWMutex
+	values map[uint64][]int
 }
 
 // MutationMap contains the map of mutations that the attacker plans to
@@ -336,6 +1228,6 @@ type PvTemplate struct {
 	CorePrims  func() string
 	Prims      func() string
 	Channels   func(*KnowledgeMap) string
-	Queries    func(*KnowledgeMap, []Query) (string, error)
-	TopLevel   func([]Block) string
+	Queries    func(*Session, *KnowledgeMap, []Query, Trivia) (string, error)
+	TopLevel   func(*Session, []Block) string
 }
@@ -62,24 +62,27 @@ var valueGNilNil = &Value{
 	},
 }
 
-var valueNamesMap map[string]valueEnum = map[string]valueEnum{
-	"g":   valueG.Data.(*Constant).ID,
-	"nil": valueNil.Data.(*Constant).ID,
-	"0":   valueZero.Data.(*Constant).ID,
-}
-
-var valueNamesMapCounter valueEnum = 3
-
-func valueNamesMapAdd(name string) valueEnum {
-	id, exists := valueNamesMap[name]
+func (s *Session) valueNamesMapAdd(name string) valueEnum {
+	s.valueNamesMutex.Lock()
+	id, exists := s.valueNamesMap[name]
 	if !exists {
-		id = valueNamesMapCounter
-		valueNamesMap[name] = id
-		valueNamesMapCounter++
+		id = s.valueNamesMapCounter
+		s.valueNamesMap[name] = id
+		s.valueNamesMapCounter++
 	}
+	s.valueNamesMutex.Unlock()
 	return id
 }
 
+func (s *Session) valueNamesMapAdopt(c *Constant) {
+	s.valueNamesMutex.Lock()
+	s.valueNamesMap[c.Name] = c.ID
+	if c.ID >= s.valueNamesMapCounter {
+		s.valueNamesMapCounter = c.ID + 1
+	}
+	s.valueNamesMutex.Unlock()
+}
+
 func valueIsGOrNil(c *Constant) bool {
 	switch c.ID {
 	case valueG.Data.(*Constant).ID, valueNil.Data.(*Constant).ID:
@@ -106,13 +109,13 @@ func valueGetPrincipalStateIndexFromConstant(valPrincipalState *PrincipalState,
 	return -1
 }
 
-func valueGetConstantsFromValue(v *Value) []*Constant {
+func (s *Session) valueGetConstantsFromValue(v *Value) []*Constant {
 	c := []*Constant{}
 	switch v.Kind {
 	case typesEnumConstant:
 		name := v.Data.(*Constant).Name
 		if strings.HasPrefix(name, scalarExprPrefix) {
-			c = append(c, scalarExprVariableConstantsFromValue(v)...)
+			c = append(c, s.scalarExprVariableConstantsFromValue(v)...)
 			break
 		}
 		c = append(c, v.Data.(*Constant))
@@ -246,6 +249,41 @@ func valueFlattenEquation(e *Equation) *Equation {
 	return &ef
 }
 
+func valueHash(a *Value) uint64 {
+	switch a.Kind {
+	case typesEnumConstant:
+		return valueHashMix(uint64(typesEnumConstant), uint64(a.Data.(*Constant).ID))
+	case typesEnumPrimitive:
+		p := a.Data.(*Primitive)
+		h := valueHashMix(uint64(typesEnumPrimitive), uint64(p.ID))
+		h = valueHashMix(h, uint64(p.Output))
+		for _, aa := range p.Arguments {
+			h = valueHashMix(h, valueHash(aa))
+		}
+		return h
+	case typesEnumEquation:
+		ef := valueFlattenEquation(a.Data.(*Equation))
+		h := valueHashMix(uint64(typesEnumEquation), uint64(len(ef.Values)))
+		switch len(ef.Values) {
+		case 3:
+			// Exponents commute (G^a^b is equivalent to G^b^a) and the base is
+			// not compared by valueEquivalentEquations, so only the sum of the
+			// exponents' hashes is mixed in.
+			return valueHashMix(h, valueHash(ef.Values[1])+valueHash(ef.Values[2]))
+		default:
+			for _, v := range ef.Values {
+				h = valueHashMix(h, valueHash(v))
+			}
+		}
+		return h
+	}
+	return 0
+}
+
+func valueHashMix(h uint64, v uint64) uint64 {
+	return h ^ (v + 0x9e3779b97f4a7c15 + (h << 6) + (h >> 2))
+}
+
 func valueFindConstantInPrimitiveFromKnowledgeMap(c *Constant, a *Value, valKnowledgeMap *KnowledgeMap) bool {
 	v := &Value{
 		Kind: typesEnumConstant,
@@ -273,17 +311,18 @@ func valueEquivalentConstantInConstants(c *Constant, a []*Constant) int {
 	return -1
 }
 
-func valuePerformPrimitiveRewrite(
+func (s *Session) valuePerformPrimitiveRewrite(
 	p *Primitive, pi int, valPrincipalState *PrincipalState,
 ) ([]*Primitive, bool, *Value) {
 	rIndex := 0
-	rewrite, failedRewrites, rewritten := valuePerformPrimitiveArgumentsRewrite(
+	rewrite, failedRewrites, rewritten := s.valuePerformPrimitiveArgumentsRewrite(
 		p, valPrincipalState,
 	)
 	rebuilt, rebuild := possibleToRebuild(rewrite.Data.(*Primitive))
 	if rebuilt {
 		rewrite = rebuild
 		if pi >= 0 {
+			principalStateWritable(valPrincipalState, principalStateColumnAssigned|principalStateColumnBeforeMutate)
 			valPrincipalState.Assigned[pi] = rebuild
 			if !valPrincipalState.Mutated[pi] {
 				valPrincipalState.BeforeMutate[pi] = rebuild
@@ -294,7 +333,7 @@ func valuePerformPrimitiveRewrite(
 			return failedRewrites, rewritten, rewrite
 		}
 	}
-	rewrittenRoot, rewrittenValues := possibleToRewrite(
+	rewrittenRoot, rewrittenValues := s.possibleToRewrite(
 		rewrite.Data.(*Primitive), valPrincipalState,
 	)
 	if !rewrittenRoot {
@@ -304,6 +343,7 @@ func valuePerformPrimitiveRewrite(
 	}
 	if rIndex >= len(rewrittenValues) {
 		if pi >= 0 {
+			principalStateWritable(valPrincipalState, principalStateColumnAssigned|principalStateColumnBeforeMutate)
 			valPrincipalState.Assigned[pi] = valueNil
 			if !valPrincipalState.Mutated[pi] {
 				valPrincipalState.BeforeMutate[pi] = valueNil
@@ -313,6 +353,8 @@ func valuePerformPrimitiveRewrite(
 	}
 	if rewritten || rewrittenRoot {
 		if pi >= 0 {
+			principalStateWritable(valPrincipalState, principalStateColumnRewritten|
+				principalStateColumnAssigned|principalStateColumnBeforeMutate)
 			valPrincipalState.Rewritten[pi] = true
 			valPrincipalState.Assigned[pi] = rewrittenValues[rIndex]
 			if !valPrincipalState.Mutated[pi] {
@@ -323,7 +365,7 @@ func valuePerformPrimitiveRewrite(
 	return failedRewrites, (rewritten || rewrittenRoot), rewrittenValues[rIndex]
 }
 
-func valuePerformPrimitiveArgumentsRewrite(
+func (s *Session) valuePerformPrimitiveArgumentsRewrite(
 	p *Primitive, valPrincipalState *PrincipalState,
 ) (*Value, []*Primitive, bool) {
 	rewrite := &Value{
@@ -333,6 +375,7 @@ func valuePerformPrimitiveArgumentsRewrite(
 			Arguments: make([]*Value, len(p.Arguments)),
 			Output:    p.Output,
 			Check:     p.Check,
+			Position:  p.Position,
 		},
 	}
 	failedRewrites := []*Primitive{}
@@ -342,7 +385,7 @@ func valuePerformPrimitiveArgumentsRewrite(
 		case typesEnumConstant:
 			rewrite.Data.(*Primitive).Arguments[i] = p.Arguments[i]
 		case typesEnumPrimitive:
-			pFailedRewrite, pRewritten, pRewrite := valuePerformPrimitiveRewrite(
+			pFailedRewrite, pRewritten, pRewrite := s.valuePerformPrimitiveRewrite(
 				a.Data.(*Primitive), -1, valPrincipalState,
 			)
 			if pRewritten {
@@ -353,7 +396,7 @@ func valuePerformPrimitiveArgumentsRewrite(
 			rewrite.Data.(*Primitive).Arguments[i] = p.Arguments[i]
 			failedRewrites = append(failedRewrites, pFailedRewrite...)
 		case typesEnumEquation:
-			eFailedRewrite, eRewritten, eRewrite := valuePerformEquationRewrite(
+			eFailedRewrite, eRewritten, eRewrite := s.valuePerformEquationRewrite(
 				a.Data.(*Equation), -1, valPrincipalState,
 			)
 			if eRewritten {
@@ -368,7 +411,7 @@ func valuePerformPrimitiveArgumentsRewrite(
 	return rewrite, failedRewrites, rewritten
 }
 
-func valuePerformEquationRewrite(
+func (s *Session) valuePerformEquationRewrite(
 	e *Equation, pi int, valPrincipalState *PrincipalState,
 ) ([]*Primitive, bool, *Value) {
 	rewritten := false
@@ -395,7 +438,7 @@ func valuePerformEquationRewrite(
 			if !hasRule {
 				continue
 			}
-			pFailedRewrite, pRewritten, pRewrite := valuePerformPrimitiveRewrite(
+			pFailedRewrite, pRewritten, pRewrite := s.valuePerformPrimitiveRewrite(
 				a.Data.(*Primitive), -1, valPrincipalState,
 			)
 			if !pRewritten {
@@ -413,7 +456,7 @@ func valuePerformEquationRewrite(
 				rewrite.Data.(*Equation).Values = append(rewrite.Data.(*Equation).Values, pRewrite.Data.(*Equation).Values...)
 			}
 		case typesEnumEquation:
-			eFailedRewrite, eRewritten, eRewrite := valuePerformEquationRewrite(
+			eFailedRewrite, eRewritten, eRewrite := s.valuePerformEquationRewrite(
 				a.Data.(*Equation), -1, valPrincipalState,
 			)
 			if !eRewritten {
@@ -426,6 +469,8 @@ func valuePerformEquationRewrite(
 		}
 	}
 	if rewritten && pi >= 0 {
+		principalStateWritable(valPrincipalState, principalStateColumnRewritten|
+			principalStateColumnAssigned|principalStateColumnBeforeMutate)
 		valPrincipalState.Rewritten[pi] = true
 		valPrincipalState.Assigned[pi] = rewrite
 		if !valPrincipalState.Mutated[pi] {
@@ -435,13 +480,13 @@ func valuePerformEquationRewrite(
 	return failedRewrites, rewritten, rewrite
 }
 
-func valuePerformAllRewrites(valPrincipalState *PrincipalState) ([]*Primitive, []int, *PrincipalState) {
+func (s *Session) valuePerformAllRewrites(valPrincipalState *PrincipalState) ([]*Primitive, []int, *PrincipalState) {
 	failedRewrites := []*Primitive{}
 	failedRewriteIndices := []int{}
 	for i := range valPrincipalState.Assigned {
 		switch valPrincipalState.Assigned[i].Kind {
 		case typesEnumPrimitive:
-			failedRewrite, _, _ := valuePerformPrimitiveRewrite(
+			failedRewrite, _, _ := s.valuePerformPrimitiveRewrite(
 				valPrincipalState.Assigned[i].Data.(*Primitive), i, valPrincipalState,
 			)
 			if len(failedRewrite) == 0 {
@@ -452,7 +497,7 @@ func valuePerformAllRewrites(valPrincipalState *PrincipalState) ([]*Primitive, [
 				failedRewriteIndices = append(failedRewriteIndices, i)
 			}
 		case typesEnumEquation:
-			failedRewrite, _, _ := valuePerformEquationRewrite(
+			failedRewrite, _, _ := s.valuePerformEquationRewrite(
 				valPrincipalState.Assigned[i].Data.(*Equation), i, valPrincipalState,
 			)
 			if len(failedRewrite) == 0 {
@@ -534,6 +579,7 @@ func valueResolvePrimitiveInternalValuesFromKnowledgeMap(
 			Arguments: []*Value{},
 			Output:    a.Data.(*Primitive).Output,
 			Check:     a.Data.(*Primitive).Check,
+			Position:  a.Data.(*Primitive).Position,
 		},
 	}
 	for aai := range a.Data.(*Primitive).Arguments {
@@ -676,6 +722,7 @@ func valueResolvePrimitiveInternalValuesFromPrincipalState(
 			Arguments: []*Value{},
 			Output:    a.Data.(*Primitive).Output,
 			Check:     a.Data.(*Primitive).Check,
+			Position:  a.Data.(*Primitive).Position,
 		},
 	}
 	for i := 0; i < len(a.Data.(*Primitive).Arguments); i++ {
@@ -788,6 +835,7 @@ func valueResolveAllPrincipalStateValues(
 ) (*PrincipalState, error) {
 	var err error
 	valPrincipalStateClone := constructPrincipalStateClone(valPrincipalState, false)
+	principalStateWritable(valPrincipalStateClone, principalStateColumnAssigned|principalStateColumnBeforeRewrite)
 	for i := range valPrincipalState.Assigned {
 		valPrincipalStateClone.Assigned[i], err = valueResolveValueInternalValuesFromPrincipalState(
 			valPrincipalState.Assigned[i], valPrincipalState.Assigned[i], i, valPrincipalState,
@@ -807,14 +855,14 @@ func valueResolveAllPrincipalStateValues(
 	return valPrincipalStateClone, nil
 }
 
-func valueConstantContainsFreshValues(
+func (s *Session) valueConstantContainsFreshValues(
 	c *Constant, valPrincipalState *PrincipalState,
 ) (bool, error) {
 	i := valueGetPrincipalStateIndexFromConstant(valPrincipalState, c)
 	if i < 0 {
 		return false, errors.New("invalid value")
 	}
-	cc := valueGetConstantsFromValue(valPrincipalState.Assigned[i])
+	cc := s.valueGetConstantsFromValue(valPrincipalState.Assigned[i])
 	for i := 0; i < len(cc); i++ {
 		ii := valueGetPrincipalStateIndexFromConstant(valPrincipalState, cc[i])
 		if ii >= 0 {
@@ -847,6 +895,7 @@ func valueDeepCopy(v *Value) Value {
 			Arguments: []*Value{},
 			Output:    v.Data.(*Primitive).Output,
 			Check:     v.Data.(*Primitive).Check,
+			Position:  v.Data.(*Primitive).Position,
 		}
 		for i := 0; i < len(v.Data.(*Primitive).Arguments); i++ {
 			arg := valueDeepCopy(v.Data.(*Primitive).Arguments[i])
@@ -9,24 +9,58 @@ import (
 	"net/url"
 )
 
+const (
+	verifHubSubmitURI    = "https://verifhub.verifpal.com/submit"
+	verifHubMaxURILength = 8192
+)
+
 // VerifHubScheduledShared is a global variable that tracks whether
-// VerifHub submission has been enabled for this analysis.
+// Verify submits the model to VerifHub once it is verified.
 var VerifHubScheduledShared bool
 
 // VerifHub submits the given Verifpal model to VerifHub by opening
 // the user's browser with the formatted model submission URI.
 func VerifHub(m Model, fileName string, resultsCode string) error {
-	InfoMessage("Your model will now be submitted to VerifHub.", "verifpal", false)
-	submitURI := "https://verifhub.verifpal.com/submit"
-	pretty, err := PrettyModel(m)
+	s := NewSession()
+	s.modelAdopt(m)
+	s.infoMessage("Your model will now be submitted to VerifHub.", "verifpal", false)
+	pretty, err := s.PrettyModel(m)
 	if err != nil {
 		return err
 	}
-	model := url.PathEscape(pretty)
+	return NewVerifHubSink().Submit(Results{
+		FileName:    fileName,
+		Model:       pretty,
+		ResultsCode: resultsCode,
+	})
+}
+
+// NewVerifHubSink returns a ResultsSink which submits each verified model to
+// VerifHub by opening the user's browser with the formatted model submission URI.
+// The whole model is sent to VerifHub, which is public.
+func NewVerifHubSink() ResultsSink {
+	return verifHubSink{}
+}
+
+// Name returns "VerifHub".
+func (verifHubSink) Name() string {
+	return "VerifHub"
+}
+
+// Submit opens the user's browser with the submission URI of the model.
+// It fails if the URI is too long for browsers to open, as can happen
+// with large models.
+func (verifHubSink) Submit(results Results) error {
 	link := fmt.Sprintf(
 		"%s?name=%s&model=%s&results=%s",
-		submitURI, fileName, model, resultsCode,
+		verifHubSubmitURI, url.QueryEscape(results.FileName),
+		url.PathEscape(results.Model), results.ResultsCode,
 	)
-	err = OpenBrowser(link)
-	return err
+	if len(link) > verifHubMaxURILength {
+		return fmt.Errorf(
+			"model is too large to be submitted (%d characters, at most %d)",
+			len(link), verifHubMaxURILength,
+		)
+	}
+	return OpenBrowser(link)
 }
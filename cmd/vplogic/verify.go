@@ -5,62 +5,77 @@
 package vplogic
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"sync"
 	"time"
 )
 
 // Verify runs the main verification engine for Verifpal on a model loaded from a file.
 // It returns a slice of verifyResults and a "results code".
+// The model is submitted to VerifHub if VerifHubScheduledShared is set.
 func Verify(filePath string) ([]VerifyResult, string, error) {
+	opts := DefaultOptions()
+	if VerifHubScheduledShared {
+		opts.Sinks = append(opts.Sinks, NewVerifHubSink())
+	}
+	return VerifyFile(context.Background(), filePath, opts)
+}
+
+// VerifyFile runs the main verification engine for Verifpal on a model loaded from a file,
+// within a new Session configured with the given Options.
+// It returns a slice of verifyResults and a "results code". If ctx ends before the
+// analysis is complete, the partial results are returned along with ctx's error.
+func VerifyFile(ctx context.Context, filePath string, opts Options) ([]VerifyResult, string, error) {
 	/*
 		f, _ := os.Create("cpu.pprof")
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	*/
-	m, err := libpegParseModel(filePath, true)
+	s := NewSessionWithOptions(opts)
+	m, err := s.libpegParseModel(filePath, true)
 	if err != nil {
 		return []VerifyResult{}, "", err
 	}
-	return verifyModel(m)
+	return s.verifyModel(ctx, m)
 }
 
-func verifyModel(m Model) ([]VerifyResult, string, error) {
-	valKnowledgeMap, valPrincipalStates, err := sanity(m)
+func (s *Session) verifyModel(ctx context.Context, m Model) ([]VerifyResult, string, error) {
+	valKnowledgeMap, valPrincipalStates, err := s.sanity(m)
 	if err != nil {
 		return []VerifyResult{}, "", err
 	}
-	initiated := time.Now().Format("03:04:05 PM")
-	verifyAnalysisCountInit()
-	verifyResultsInit(m)
-	InfoMessage(fmt.Sprintf(
-		"Verification initiated for '%s' at %s.", m.FileName, initiated,
+	started := time.Now()
+	s.verifyAnalysisCountInit()
+	s.verifyAnalysisStageInit()
+	s.verifyResultsInit(m)
+	s.infoMessage(fmt.Sprintf(
+		"Verification initiated for '%s' at %s.", m.FileName, started.Format("03:04:05 PM"),
 	), "verifpal", false)
 	switch m.Attacker {
 	case "passive":
-		err := verifyPassive(valKnowledgeMap, valPrincipalStates)
+		err := s.verifyPassive(ctx, valKnowledgeMap, valPrincipalStates)
 		if err != nil {
-			return []VerifyResult{}, "", err
+			return []VerifyResult{}, "", modelErrorInFile(m.FileName, err)
 		}
 	case "active":
-		err := verifyActive(valKnowledgeMap, valPrincipalStates)
+		err := s.verifyActive(ctx, valKnowledgeMap, valPrincipalStates)
 		if err != nil {
-			return []VerifyResult{}, "", err
+			return []VerifyResult{}, "", modelErrorInFile(m.FileName, err)
 		}
 	default:
 		return []VerifyResult{}, "", fmt.Errorf("invalid attacker (%s)", m.Attacker)
 	}
-	return verifyEnd(m)
+	return s.verifyEnd(ctx, m, started)
 }
 
-func verifyResolveQueries(
-	valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
+func (s *Session) verifyResolveQueries(
+	valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState, stage int,
 ) error {
-	valVerifyResults, _ := verifyResultsGetRead()
+	valVerifyResults, _ := s.verifyResultsGetRead()
 	for _, verifyResult := range valVerifyResults {
 		if !verifyResult.Resolved {
-			err := queryStart(verifyResult.Query, valKnowledgeMap, valPrincipalState)
+			err := s.queryStart(verifyResult.Query, stage, valKnowledgeMap, valPrincipalState)
 			if err != nil {
 				return err
 			}
@@ -69,9 +84,10 @@ func verifyResolveQueries(
 	return nil
 }
 
-func verifyStandardRun(valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState, stage int) error {
-	var scanGroup sync.WaitGroup
-	valAttackerState := attackerStateGetRead()
+func (s *Session) verifyStandardRun(
+	ctx context.Context, valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState, stage int,
+) error {
+	valAttackerState := s.attackerStateGetRead()
 	for _, valPrincipalState := range valPrincipalStates {
 		var err error
 		var failedRewrites []*Primitive
@@ -82,10 +98,10 @@ func verifyStandardRun(valKnowledgeMap *KnowledgeMap, valPrincipalStates []*Prin
 		for _, a := range valPrincipalState.Assigned {
 			switch a.Kind {
 			case typesEnumPrimitive:
-				injectMissingSkeletons(a.Data.(*Primitive), valPrincipalState, valAttackerState)
+				s.injectMissingSkeletons(a.Data.(*Primitive), valPrincipalState, valAttackerState)
 			}
 		}
-		failedRewrites, _, valPrincipalState = valuePerformAllRewrites(valPrincipalState)
+		failedRewrites, _, valPrincipalState = s.valuePerformAllRewrites(valPrincipalState)
 		err = sanityFailOnFailedCheckedPrimitiveRewrite(failedRewrites)
 		if err != nil {
 			return err
@@ -93,16 +109,14 @@ func verifyStandardRun(valKnowledgeMap *KnowledgeMap, valPrincipalStates []*Prin
 		for i := range valPrincipalState.Assigned {
 			err = sanityCheckEquationGenerators(valPrincipalState.Assigned[i])
 			if err != nil {
-				return err
+				return modelErrorAt(valPrincipalState.Constants[i].Position, err)
 			}
 		}
-		scanGroup.Add(1)
-		err = verifyAnalysis(valKnowledgeMap, valPrincipalState, valAttackerState, stage, &scanGroup)
+		err = s.verifyAnalysis(ctx, valKnowledgeMap, valPrincipalState, valAttackerState, stage)
 		if err != nil {
 			return err
 		}
-		scanGroup.Wait()
-		err = verifyResolveQueries(valKnowledgeMap, valPrincipalState)
+		err = s.verifyResolveQueries(valKnowledgeMap, valPrincipalState, stage)
 		if err != nil {
 			return err
 		}
@@ -110,23 +124,25 @@ func verifyStandardRun(valKnowledgeMap *KnowledgeMap, valPrincipalStates []*Prin
 	return nil
 }
 
-func verifyPassive(valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState) error {
-	InfoMessage("Attacker is configured as passive.", "info", false)
+func (s *Session) verifyPassive(
+	ctx context.Context, valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState,
+) error {
+	s.infoMessage("Attacker is configured as passive.", "info", false)
 	phase := 0
-	for phase <= valKnowledgeMap.MaxPhase {
-		attackerStateInit(false)
+	for phase <= valKnowledgeMap.MaxPhase && ctx.Err() == nil {
+		s.attackerStateInit(false)
 		valPrincipalStatePureResolved := constructPrincipalStateClone(valPrincipalStates[0], true)
 		valPrincipalStatePureResolved, err := valueResolveAllPrincipalStateValues(
-			valPrincipalStatePureResolved, attackerStateGetRead(),
+			valPrincipalStatePureResolved, s.attackerStateGetRead(),
 		)
 		if err != nil {
 			return err
 		}
-		err = attackerStatePutPhaseUpdate(valKnowledgeMap, valPrincipalStatePureResolved, phase)
+		err = s.attackerStatePutPhaseUpdate(valKnowledgeMap, valPrincipalStatePureResolved, phase)
 		if err != nil {
 			return err
 		}
-		err = verifyStandardRun(valKnowledgeMap, valPrincipalStates, 0)
+		err = s.verifyStandardRun(ctx, valKnowledgeMap, valPrincipalStates, 0)
 		if err != nil {
 			return err
 		}
@@ -152,11 +168,13 @@ func verifyGetResultsCode(valVerifyResults []VerifyResult) string {
 		case typesEnumEquivalence:
 			q = "e"
 		}
-		switch verifyResult.Resolved {
-		case true:
+		switch verifyResult.Status {
+		case VerifyStatusAttackFound:
 			r = "1"
-		case false:
+		case VerifyStatusVerified:
 			r = "0"
+		case VerifyStatusInconclusive:
+			r = "-"
 		}
 		resultsCode = fmt.Sprintf(
 			"%s%s%s",
@@ -166,38 +184,59 @@ func verifyGetResultsCode(valVerifyResults []VerifyResult) string {
 	return resultsCode
 }
 
-func verifyEnd(m Model) ([]VerifyResult, string, error) {
+func (s *Session) verifyEnd(ctx context.Context, m Model, started time.Time) ([]VerifyResult, string, error) {
 	var err error
-	valVerifyResults, fileName := verifyResultsGetRead()
-	noResolved := true
-	for _, verifyResult := range valVerifyResults {
+	interrupted := ctx.Err() != nil
+	exhausted := s.attackerStateGetExhausted()
+	valVerifyResults, fileName := s.verifyResultsGetRead()
+	for i, verifyResult := range valVerifyResults {
+		valVerifyResults[i].Exhausted = exhausted
 		if verifyResult.Resolved {
-			noResolved = false
-			break
+			continue
 		}
-	}
-	fmt.Fprint(os.Stdout, "\n\n")
-	InfoMessage(fmt.Sprintf(
-		"Verification completed for '%s' at %s.",
-		fileName, time.Now().Format("03:04:05 PM"),
-	), "verifpal", false)
-	if noResolved {
-		InfoMessage("All queries pass.", "verifpal", false)
-	} else {
-		InfoMessage("Summary of failed queries will follow.", "verifpal", false)
-	}
-	fmt.Fprint(os.Stdout, "\n")
-	for _, verifyResult := range valVerifyResults {
-		if verifyResult.Resolved {
-			InfoMessage(fmt.Sprintf("%s — %s",
-				prettyQuery(verifyResult.Query), verifyResult.Summary,
-			), "result", false)
+		valVerifyResults[i].Stage = s.verifyAnalysisStageGet()
+		if interrupted {
+			valVerifyResults[i].Status = VerifyStatusInconclusive
+		} else {
+			valVerifyResults[i].Status = VerifyStatusVerified
 		}
 	}
-	InfoMessage("Thank you for using Verifpal.", "verifpal", false)
 	resultsCode := verifyGetResultsCode(valVerifyResults)
-	if VerifHubScheduledShared {
-		err = VerifHub(m, fileName, resultsCode)
+	report := s.verifyReport(m, fileName, valVerifyResults, interrupted, started)
+	s.observe(EventVerificationCompleted{
+		Report:  report,
+		Results: valVerifyResults,
+	})
+	switch {
+	case interrupted:
+		err = fmt.Errorf("verification of '%s' stopped before completion: %w", fileName, ctx.Err())
+	case len(s.options.Sinks) > 0:
+		err = s.verifySubmit(m, fileName, resultsCode, report)
 	}
 	return valVerifyResults, resultsCode, err
 }
+
+// verifySubmit sends the results of a completed verification to each of
+// the Session's ResultsSinks, even if sending them to another one fails.
+// Each failure is returned as a SinkError.
+func (s *Session) verifySubmit(m Model, fileName string, resultsCode string, report Report) error {
+	pretty, err := s.PrettyModel(m)
+	if err != nil {
+		return err
+	}
+	results := Results{
+		FileName:    fileName,
+		Model:       pretty,
+		ResultsCode: resultsCode,
+		Report:      report,
+	}
+	errs := []error{}
+	for _, sink := range s.options.Sinks {
+		s.infoMessage(fmt.Sprintf("Your results will now be submitted to %s.", sink.Name()), "verifpal", false)
+		err = sink.Submit(results)
+		if err != nil {
+			errs = append(errs, &SinkError{Sink: sink.Name(), Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
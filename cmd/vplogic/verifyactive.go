@@ -5,48 +5,49 @@
 package vplogic
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"sync"
+	"runtime"
+
+	"golang.org/x/sync/errgroup"
 )
 
-func verifyActive(valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState) error {
-	InfoMessage("Attacker is configured as active.", "info", false)
+func (s *Session) verifyActive(
+	ctx context.Context, valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState,
+) error {
+	s.infoMessage("Attacker is configured as active.", "info", false)
 	phase := 0
-	for phase <= valKnowledgeMap.MaxPhase {
-		var stageGroup sync.WaitGroup
-		InfoMessage(fmt.Sprintf("Running at phase %d.", phase), "info", false)
-		attackerStateInit(true)
+	for phase <= valKnowledgeMap.MaxPhase && ctx.Err() == nil {
+		s.infoMessage(fmt.Sprintf("Running at phase %d.", phase), "info", false)
+		s.attackerStateInit(true)
 		valPrincipalStatePureResolved := constructPrincipalStateClone(valPrincipalStates[0], true)
 		valPrincipalStatePureResolved, err := valueResolveAllPrincipalStateValues(
-			valPrincipalStatePureResolved, attackerStateGetRead(),
+			valPrincipalStatePureResolved, s.attackerStateGetRead(),
 		)
 		if err != nil {
 			return err
 		}
-		err = attackerStatePutPhaseUpdate(valKnowledgeMap, valPrincipalStatePureResolved, phase)
+		err = s.attackerStatePutPhaseUpdate(valKnowledgeMap, valPrincipalStatePureResolved, phase)
 		if err != nil {
 			return err
 		}
-		err = verifyStandardRun(valKnowledgeMap, valPrincipalStates, 0)
+		err = s.verifyStandardRun(ctx, valKnowledgeMap, valPrincipalStates, 0)
 		if err != nil {
 			return err
 		}
-		stageGroup.Add(1)
-		go verifyActiveStages(1, valKnowledgeMap, valPrincipalStates, attackerStateGetRead(), &stageGroup)
-		stageGroup.Wait()
-		stageGroup.Add(2)
-		go verifyActiveStages(2, valKnowledgeMap, valPrincipalStates, attackerStateGetRead(), &stageGroup)
-		go verifyActiveStages(3, valKnowledgeMap, valPrincipalStates, attackerStateGetRead(), &stageGroup)
-		stageGroup.Wait()
-		stageGroup.Add(2)
-		go verifyActiveStages(4, valKnowledgeMap, valPrincipalStates, attackerStateGetRead(), &stageGroup)
-		go verifyActiveStages(5, valKnowledgeMap, valPrincipalStates, attackerStateGetRead(), &stageGroup)
-		stageGroup.Wait()
+		for _, stages := range [][]int{{1}, {2, 3}, {4, 5}} {
+			err = s.verifyActiveStagesGroup(ctx, valKnowledgeMap, valPrincipalStates, stages...)
+			if err != nil {
+				return err
+			}
+		}
 		stage := 6
-		for !verifyResultsAllResolved() && !attackerStateGetExhausted() {
-			stageGroup.Add(1)
-			go verifyActiveStages(stage, valKnowledgeMap, valPrincipalStates, attackerStateGetRead(), &stageGroup)
-			stageGroup.Wait()
+		for !s.verifyResultsAllResolved() && !s.attackerStateGetExhausted() && ctx.Err() == nil {
+			err = s.verifyActiveStagesGroup(ctx, valKnowledgeMap, valPrincipalStates, stage)
+			if err != nil {
+				return err
+			}
 			stage = stage + 1
 		}
 		phase = phase + 1
@@ -54,90 +55,148 @@ func verifyActive(valKnowledgeMap *KnowledgeMap, valPrincipalStates []*Principal
 	return nil
 }
 
-func verifyActiveStages(
-	stage int, valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState,
-	valAttackerState AttackerState, stageGroup *sync.WaitGroup,
-) {
-	var principalGroup sync.WaitGroup
-	var err error
+func (s *Session) verifyActiveStagesGroup(
+	ctx context.Context, valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState,
+	stages ...int,
+) error {
+	if s.options.Deterministic {
+		for _, stage := range stages {
+			err := s.verifyActiveStages(ctx, stage, valKnowledgeMap, valPrincipalStates, s.attackerStateGetRead())
+			if err != nil || ctx.Err() != nil {
+				return err
+			}
+			s.verifyAnalysisStagePut(stage)
+		}
+		return nil
+	}
+	stageGroup, stageCtx := errgroup.WithContext(ctx)
+	for _, stage := range stages {
+		valAttackerState := s.attackerStateGetRead()
+		stageGroup.Go(func() error {
+			return s.verifyActiveStages(stageCtx, stage, valKnowledgeMap, valPrincipalStates, valAttackerState)
+		})
+	}
+	err := stageGroup.Wait()
+	if err != nil || ctx.Err() != nil {
+		return err
+	}
+	s.verifyAnalysisStagePut(stages[len(stages)-1])
+	return nil
+}
+
+func (s *Session) verifyActiveStages(
+	ctx context.Context, stage int, valKnowledgeMap *KnowledgeMap, valPrincipalStates []*PrincipalState,
+	valAttackerState AttackerState,
+) error {
+	s.observe(EventStageStarted{
+		Phase: valAttackerState.CurrentPhase,
+		Stage: stage,
+	})
 	oldKnown := len(valAttackerState.Known)
-	valAttackerState = attackerStateGetRead()
-	principalGroup.Add(len(valPrincipalStates))
+	valAttackerState = s.attackerStateGetRead()
 	for _, valPrincipalState := range valPrincipalStates {
-		func(valPrincipalState *PrincipalState) {
-			var scanGroup sync.WaitGroup
-			var valMutationMap MutationMap
-			valMutationMap, err = mutationMapInit(
-				valKnowledgeMap, valPrincipalState, valAttackerState, stage,
-			)
-			if err != nil {
-				scanGroup.Done()
-				return
-			}
-			scanGroup.Add(1)
-			err = verifyActiveScan(
-				valKnowledgeMap, valPrincipalState, valAttackerState,
-				mutationMapNext(valMutationMap), stage, &scanGroup,
+		valMutationMap, err := s.mutationMapInit(
+			ctx, valKnowledgeMap, valPrincipalState, valAttackerState, stage,
+		)
+		if err == nil {
+			err = s.verifyActiveScan(
+				ctx, valKnowledgeMap, valPrincipalState, valAttackerState,
+				mutationMapNext(valMutationMap), stage,
 			)
-			if err != nil {
-				scanGroup.Done()
-				return
-			}
-			scanGroup.Wait()
-			principalGroup.Done()
-		}(valPrincipalState)
+		}
+		if err != nil && !errors.Is(err, ctx.Err()) {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
 	}
-	principalGroup.Wait()
 	exhausted := (stage > 5 && (oldKnown == len(valAttackerState.Known)))
 	if exhausted {
-		attackerStatePutExhausted()
+		s.attackerStatePutExhausted()
 	}
-	stageGroup.Done()
+	return nil
 }
 
-func verifyActiveScan(
-	valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
-	valAttackerState AttackerState, valMutationMap MutationMap,
-	stage int, scanGroup *sync.WaitGroup,
+func (s *Session) verifyActiveScan(
+	ctx context.Context, valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
+	valAttackerState AttackerState, valMutationMap MutationMap, stage int,
 ) error {
-	var err error
-	if verifyResultsAllResolved() {
-		scanGroup.Done()
-		return err
+	if s.options.Deterministic {
+		return s.verifyActiveScanMutations(
+			ctx, valKnowledgeMap, valPrincipalState, valAttackerState, valMutationMap,
+			func(valPrincipalStateMutated *PrincipalState) error {
+				return s.verifyAnalysis(
+					ctx, valKnowledgeMap, valPrincipalStateMutated, s.attackerStateGetRead(), stage,
+				)
+			},
+		)
 	}
-	valPrincipalStateMutated, isWorthwhileMutation := verifyActiveMutatePrincipalState(
-		valKnowledgeMap, constructPrincipalStateClone(valPrincipalState, true),
-		valAttackerState, valMutationMap,
-	)
-	if isWorthwhileMutation {
-		scanGroup.Add(1)
-		go func() {
-			err = verifyAnalysis(
-				valKnowledgeMap, valPrincipalStateMutated, attackerStateGetRead(), stage, scanGroup,
-			)
-			if err != nil {
-				scanGroup.Done()
-				return
+	workers := s.verifyActiveWorkers()
+	scanGroup, scanCtx := errgroup.WithContext(ctx)
+	scanQueue := make(chan *PrincipalState, workers)
+	for i := 0; i < workers; i++ {
+		scanGroup.Go(func() error {
+			for valPrincipalStateMutated := range scanQueue {
+				err := s.verifyAnalysis(
+					scanCtx, valKnowledgeMap, valPrincipalStateMutated, s.attackerStateGetRead(), stage,
+				)
+				if err != nil {
+					return err
+				}
 			}
-		}()
+			return nil
+		})
 	}
-	if valMutationMap.OutOfMutations {
-		scanGroup.Done()
+	scanGroup.Go(func() error {
+		err := s.verifyActiveScanMutations(
+			scanCtx, valKnowledgeMap, valPrincipalState, valAttackerState, valMutationMap,
+			func(valPrincipalStateMutated *PrincipalState) error {
+				select {
+				case scanQueue <- valPrincipalStateMutated:
+				case <-scanCtx.Done():
+				}
+				return nil
+			},
+		)
+		close(scanQueue)
 		return err
-	}
-	go func() {
-		err := verifyActiveScan(
-			valKnowledgeMap, valPrincipalState, valAttackerState,
-			mutationMapNext(valMutationMap), stage, scanGroup,
+	})
+	return scanGroup.Wait()
+}
+
+func (s *Session) verifyActiveScanMutations(
+	ctx context.Context, valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
+	valAttackerState AttackerState, valMutationMap MutationMap,
+	analyze func(valPrincipalStateMutated *PrincipalState) error,
+) error {
+	for !s.verifyResultsAllResolved() && ctx.Err() == nil {
+		valPrincipalStateMutated, isWorthwhileMutation := s.verifyActiveMutatePrincipalState(
+			valKnowledgeMap, constructPrincipalStateClone(valPrincipalState, true),
+			valAttackerState, valMutationMap,
 		)
-		if err != nil {
-			scanGroup.Done()
+		if isWorthwhileMutation {
+			err := analyze(valPrincipalStateMutated)
+			if err != nil {
+				return err
+			}
+		}
+		if valMutationMap.OutOfMutations {
+			return nil
 		}
-	}()
+		valMutationMap = mutationMapNext(valMutationMap)
+	}
 	return nil
 }
 
-func verifyActiveMutatePrincipalState(
+func (s *Session) verifyActiveWorkers() int {
+	if s.options.Workers > 0 {
+		return s.options.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+func (s *Session) verifyActiveMutatePrincipalState(
 	valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
 	valAttackerState AttackerState, valMutationMap MutationMap,
 ) (*PrincipalState, bool) {
@@ -149,27 +208,36 @@ func verifyActiveMutatePrincipalState(
 		ar, _ := valueResolveValueInternalValuesFromKnowledgeMap(ai, valKnowledgeMap)
 		switch ar.Kind {
 		case typesEnumPrimitive:
-			_, aar := possibleToRewrite(ar.Data.(*Primitive), valPrincipalState)
+			_, aar := s.possibleToRewrite(ar.Data.(*Primitive), valPrincipalState)
 			switch aar[0].Kind {
 			case typesEnumPrimitive:
-				ar.Data = aar[0].Data.(*Primitive)
+				ar = &Value{Kind: typesEnumPrimitive, Data: aar[0].Data.(*Primitive)}
 			}
 		}
 		switch ac.Kind {
 		case typesEnumPrimitive:
-			_, aac := possibleToRewrite(ac.Data.(*Primitive), valPrincipalState)
+			acp := ac.Data.(*Primitive)
+			_, aac := s.possibleToRewrite(acp, valPrincipalState)
 			switch aac[0].Kind {
 			case typesEnumPrimitive:
-				ac.Data = aac[0].Data.(*Primitive)
+				acp = aac[0].Data.(*Primitive)
 			}
 			switch ai.Kind {
 			case typesEnumPrimitive:
-				ac.Data.(*Primitive).Output = ar.Data.(*Primitive).Output
-				ac.Data.(*Primitive).Check = ar.Data.(*Primitive).Check
+				acp = &Primitive{
+					ID:        acp.ID,
+					Arguments: acp.Arguments,
+					Output:    ar.Data.(*Primitive).Output,
+					Check:     ar.Data.(*Primitive).Check,
+					Position:  ar.Data.(*Primitive).Position,
+				}
 			}
+			ac = &Value{Kind: typesEnumPrimitive, Data: acp}
 		}
-		valPrincipalState.Creator[ii] = principalNamesMap["Attacker"]
-		valPrincipalState.Sender[ii] = principalNamesMap["Attacker"]
+		principalStateWritable(valPrincipalState, principalStateColumnCreator|principalStateColumnSender|
+			principalStateColumnMutated|principalStateColumnAssigned|principalStateColumnBeforeRewrite)
+		valPrincipalState.Creator[ii] = s.principalGetIDFromName("Attacker")
+		valPrincipalState.Sender[ii] = s.principalGetIDFromName("Attacker")
 		valPrincipalState.Mutated[ii] = true
 		valPrincipalState.Assigned[ii] = ac
 		valPrincipalState.BeforeRewrite[ii] = ac
@@ -186,7 +254,7 @@ func verifyActiveMutatePrincipalState(
 		return valPrincipalState, isWorthwhileMutation
 	}
 	valPrincipalState, _ = valueResolveAllPrincipalStateValues(valPrincipalState, valAttackerState)
-	failedRewrites, failedRewriteIndices, valPrincipalState := valuePerformAllRewrites(valPrincipalState)
+	failedRewrites, failedRewriteIndices, valPrincipalState := s.valuePerformAllRewrites(valPrincipalState)
 	for i := 0; i < len(failedRewrites); i++ {
 		if !failedRewrites[i].Check {
 			continue
@@ -5,84 +5,98 @@
 package vplogic
 
 import (
+	"context"
 	"fmt"
-	"sync"
 	"sync/atomic"
 )
 
-var verifyAnalysisCount uint32
-
-func verifyAnalysis(
-	valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
-	valAttackerState AttackerState, stage int, scanGroup *sync.WaitGroup,
+func (s *Session) verifyAnalysis(
+	ctx context.Context, valKnowledgeMap *KnowledgeMap, valPrincipalState *PrincipalState,
+	valAttackerState AttackerState, stage int,
 ) error {
-	o := 0
-	if verifyResultsAllResolved() {
-		scanGroup.Done()
-		return nil
-	}
-	err := verifyResolveQueries(valKnowledgeMap, valPrincipalState)
-	if err != nil {
-		return err
+	for !s.verifyResultsAllResolved() && ctx.Err() == nil {
+		err := s.verifyResolveQueries(valKnowledgeMap, valPrincipalState, stage)
+		if err != nil {
+			return err
+		}
+		if s.verifyAnalysisDeduce(valPrincipalState, valAttackerState) == 0 {
+			s.verifyAnalysisCountIncrement()
+			s.infoAnalysis(stage)
+			return nil
+		}
+		valAttackerState = s.attackerStateGetRead()
 	}
+	return nil
+}
+
+func (s *Session) verifyAnalysisDeduce(valPrincipalState *PrincipalState, valAttackerState AttackerState) int {
+	o := 0
 	for i := 0; i < len(valAttackerState.Known); i++ {
-		o = o + verifyAnalysisDecompose(valAttackerState.Known[i], valPrincipalState, valAttackerState)
+		o = o + s.verifyAnalysisDecompose(valAttackerState.Known[i], valPrincipalState, valAttackerState)
 		if o > 0 {
 			break
 		}
 	}
 	for i := 0; i < len(valPrincipalState.Assigned); i++ {
-		o = o + verifyAnalysisReconstruct(valPrincipalState.Assigned[i], valPrincipalState, valAttackerState, 0)
+		o = o + s.verifyAnalysisReconstruct(valPrincipalState.Assigned[i], valPrincipalState, valAttackerState, 0)
 		if o > 0 {
 			break
 		}
-		o = o + verifyAnalysisRecompose(valPrincipalState.Assigned[i], valPrincipalState, valAttackerState)
+		o = o + s.verifyAnalysisRecompose(valPrincipalState.Assigned[i], valPrincipalState, valAttackerState)
 		if o > 0 {
 			break
 		}
 	}
 	for i := 0; i < len(valAttackerState.Known); i++ {
-		o = o + verifyAnalysisEquivalize(valAttackerState.Known[i], valPrincipalState)
+		o = o + s.verifyAnalysisEquivalize(valAttackerState.Known[i], valPrincipalState)
 		if o > 0 {
 			break
 		}
-		o = o + verifyAnalysisPasswords(valAttackerState.Known[i], valPrincipalState)
+		o = o + s.verifyAnalysisPasswords(valAttackerState.Known[i], valPrincipalState)
 		if o > 0 {
 			break
 		}
-		o = o + verifyAnalysisConcat(valAttackerState.Known[i], valPrincipalState)
+		o = o + s.verifyAnalysisConcat(valAttackerState.Known[i], valPrincipalState)
 		if o > 0 {
 			break
 		}
 	}
-	if o > 0 {
-		go func() {
-			err := verifyAnalysis(valKnowledgeMap, valPrincipalState, attackerStateGetRead(), stage, scanGroup)
-			if err != nil {
-				scanGroup.Done()
-			}
-		}()
-	} else {
-		verifyAnalysisCountIncrement()
-		infoAnalysis(stage)
-		scanGroup.Done()
-	}
-	return nil
+	return o
+}
+
+func (s *Session) verifyAnalysisCountInit() {
+	atomic.StoreUint32(&s.verifyAnalysisCount, uint32(0))
+}
+
+func (s *Session) verifyAnalysisCountIncrement() {
+	atomic.AddUint32(&s.verifyAnalysisCount, 1)
 }
 
-func verifyAnalysisCountInit() {
-	atomic.StoreUint32(&verifyAnalysisCount, uint32(0))
+func (s *Session) verifyAnalysisCountGet() int {
+	return int(atomic.LoadUint32(&s.verifyAnalysisCount))
 }
 
-func verifyAnalysisCountIncrement() {
-	atomic.AddUint32(&verifyAnalysisCount, 1)
+func (s *Session) verifyAnalysisStageInit() {
+	atomic.StoreUint32(&s.verifyAnalysisStage, uint32(0))
+}
+
+func (s *Session) verifyAnalysisStagePut(stage int) {
+	for {
+		reached := atomic.LoadUint32(&s.verifyAnalysisStage)
+		if uint32(stage) <= reached {
+			return
+		}
+		if atomic.CompareAndSwapUint32(&s.verifyAnalysisStage, reached, uint32(stage)) {
+			return
+		}
+	}
 }
 
-func verifyAnalysisCountGet() int {
-	return int(atomic.LoadUint32(&verifyAnalysisCount))
+func (s *Session) verifyAnalysisStageGet() int {
+	return int(atomic.LoadUint32(&s.verifyAnalysisStage))
 }
 
-func verifyAnalysisDecompose(
+func (s *Session) verifyAnalysisDecompose(
 	a *Value, valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) int {
 	o := 0
@@ -91,19 +105,19 @@ func verifyAnalysisDecompose(
 	ar := []*Value{}
 	switch a.Kind {
 	case typesEnumPrimitive:
-		r, revealed, ar = possibleToDecomposePrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
+		r, revealed, ar = s.possibleToDecomposePrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
 	}
-	if r && attackerStatePutWrite(revealed, valPrincipalState) {
-		InfoMessage(fmt.Sprintf(
+	if r && s.attackerStatePutWrite(revealed, valPrincipalState) {
+		s.infoDeduction(revealed, "decompose", fmt.Sprintf(
 			"%s obtained by decomposing %s with %s.",
 			infoOutputText(revealed), prettyValue(a), prettyValues(ar),
-		), "deduction", true)
+		))
 		o = o + 1
 	}
 	return o
 }
 
-func verifyAnalysisRecompose(
+func (s *Session) verifyAnalysisRecompose(
 	a *Value, valPrincipalState *PrincipalState, valAttackerState AttackerState,
 ) int {
 	o := 0
@@ -114,41 +128,41 @@ func verifyAnalysisRecompose(
 	case typesEnumPrimitive:
 		r, revealed, ar = possibleToRecomposePrimitive(a.Data.(*Primitive), valAttackerState)
 	}
-	if r && attackerStatePutWrite(revealed, valPrincipalState) {
-		InfoMessage(fmt.Sprintf(
+	if r && s.attackerStatePutWrite(revealed, valPrincipalState) {
+		s.infoDeduction(revealed, "recompose", fmt.Sprintf(
 			"%s obtained by recomposing %s with %s.",
 			infoOutputText(revealed), prettyValue(a), prettyValues(ar),
-		), "deduction", true)
+		))
 		o = o + 1
 	}
 	return o
 }
 
-func verifyAnalysisReconstruct(
+func (s *Session) verifyAnalysisReconstruct(
 	a *Value, valPrincipalState *PrincipalState, valAttackerState AttackerState, o int,
 ) int {
 	r := false
 	ar := []*Value{}
 	switch a.Kind {
 	case typesEnumPrimitive:
-		r, ar = possibleToReconstructPrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
+		r, ar = s.possibleToReconstructPrimitive(a.Data.(*Primitive), valPrincipalState, valAttackerState)
 		for _, aa := range a.Data.(*Primitive).Arguments {
-			o = o + verifyAnalysisReconstruct(aa, valPrincipalState, valAttackerState, o)
+			o = o + s.verifyAnalysisReconstruct(aa, valPrincipalState, valAttackerState, o)
 		}
 	case typesEnumEquation:
 		r, ar = possibleToReconstructEquation(a.Data.(*Equation), valAttackerState)
 	}
-	if r && attackerStatePutWrite(a, valPrincipalState) {
-		InfoMessage(fmt.Sprintf(
+	if r && s.attackerStatePutWrite(a, valPrincipalState) {
+		s.infoDeduction(a, "reconstruct", fmt.Sprintf(
 			"%s obtained by reconstructing with %s.",
 			infoOutputText(a), prettyValues(ar),
-		), "deduction", true)
+		))
 		o = o + 1
 	}
 	return o
 }
 
-func verifyAnalysisEquivalize(a *Value, valPrincipalState *PrincipalState) int {
+func (s *Session) verifyAnalysisEquivalize(a *Value, valPrincipalState *PrincipalState) int {
 	o := 0
 	ar := a
 	switch a.Kind {
@@ -157,11 +171,11 @@ func verifyAnalysisEquivalize(a *Value, valPrincipalState *PrincipalState) int {
 	}
 	for i := 0; i < len(valPrincipalState.Assigned); i++ {
 		if valueEquivalentValues(ar, valPrincipalState.Assigned[i], true) {
-			if attackerStatePutWrite(valPrincipalState.Assigned[i], valPrincipalState) {
-				InfoMessage(fmt.Sprintf(
+			if s.attackerStatePutWrite(valPrincipalState.Assigned[i], valPrincipalState) {
+				s.infoDeduction(valPrincipalState.Assigned[i], "equivalize", fmt.Sprintf(
 					"%s obtained by equivalizing with the current resolution of %s.",
 					infoOutputText(valPrincipalState.Assigned[i]), prettyValue(a),
-				), "deduction", true)
+				))
 				o = o + 1
 			}
 		}
@@ -169,33 +183,33 @@ func verifyAnalysisEquivalize(a *Value, valPrincipalState *PrincipalState) int {
 	return o
 }
 
-func verifyAnalysisPasswords(a *Value, valPrincipalState *PrincipalState) int {
+func (s *Session) verifyAnalysisPasswords(a *Value, valPrincipalState *PrincipalState) int {
 	o := 0
 	passwords := possibleToObtainPasswords(a, a, -1, valPrincipalState)
 	for i := 0; i < len(passwords); i++ {
-		if attackerStatePutWrite(passwords[i], valPrincipalState) {
-			InfoMessage(fmt.Sprintf(
+		if s.attackerStatePutWrite(passwords[i], valPrincipalState) {
+			s.infoDeduction(passwords[i], "password", fmt.Sprintf(
 				"%s obtained as a password unsafely used within %s.",
 				infoOutputText(passwords[i]), prettyValue(a),
-			), "deduction", true)
+			))
 			o = o + 1
 		}
 	}
 	return o
 }
 
-func verifyAnalysisConcat(a *Value, valPrincipalState *PrincipalState) int {
+func (s *Session) verifyAnalysisConcat(a *Value, valPrincipalState *PrincipalState) int {
 	o := 0
 	switch a.Kind {
 	case typesEnumPrimitive:
 		switch a.Data.(*Primitive).ID {
 		case primitiveEnumCONCAT:
 			for i := 0; i < len(a.Data.(*Primitive).Arguments); i++ {
-				if attackerStatePutWrite(a.Data.(*Primitive).Arguments[i], valPrincipalState) {
-					InfoMessage(fmt.Sprintf(
+				if s.attackerStatePutWrite(a.Data.(*Primitive).Arguments[i], valPrincipalState) {
+					s.infoDeduction(a.Data.(*Primitive).Arguments[i], "concat", fmt.Sprintf(
 						"%s obtained as a concatenated fragment of %s.",
 						infoOutputText(a.Data.(*Primitive).Arguments[i]), prettyValue(a),
-					), "deduction", true)
+					))
 					o = o + 1
 				}
 			}
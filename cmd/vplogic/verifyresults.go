@@ -5,63 +5,98 @@
 package vplogic
 
 import (
-	"sync"
+	"fmt"
 )
 
-var verifyResultsShared []VerifyResult
-var verifyResultsFileNameShared string
-var verifyResultsMutex sync.Mutex
-
-func verifyResultsInit(m Model) bool {
-	verifyResultsMutex.Lock()
-	verifyResultsShared = make([]VerifyResult, len(m.Queries))
+func (s *Session) verifyResultsInit(m Model) bool {
+	s.verifyResultsMutex.Lock()
+	s.verifyResults = make([]VerifyResult, len(m.Queries))
 	for i, q := range m.Queries {
-		verifyResultsShared[i] = VerifyResult{
+		s.verifyResults[i] = VerifyResult{
 			Query:    q,
 			Resolved: false,
 			Summary:  "",
 			Options:  []QueryOptionResult{},
+			Status:   VerifyStatusInconclusive,
 		}
 	}
-	verifyResultsFileNameShared = m.FileName
-	verifyResultsMutex.Unlock()
+	s.verifyResultsFileName = m.FileName
+	s.verifyResultsMutex.Unlock()
 	return true
 }
 
-func verifyResultsGetRead() ([]VerifyResult, string) {
-	verifyResultsMutex.Lock()
-	valVerifyResults := make([]VerifyResult, len(verifyResultsShared))
-	copy(valVerifyResults, verifyResultsShared)
-	fileName := verifyResultsFileNameShared
-	verifyResultsMutex.Unlock()
+func (s *Session) verifyResultsGetRead() ([]VerifyResult, string) {
+	s.verifyResultsMutex.Lock()
+	valVerifyResults := make([]VerifyResult, len(s.verifyResults))
+	copy(valVerifyResults, s.verifyResults)
+	fileName := s.verifyResultsFileName
+	s.verifyResultsMutex.Unlock()
 	return valVerifyResults, fileName
 }
 
-func verifyResultsPutWrite(result VerifyResult) bool {
+func (s *Session) verifyResultsPutWrite(result VerifyResult) bool {
 	written := false
-	qw := prettyQuery(result.Query)
-	verifyResultsMutex.Lock()
-	for i, verifyResult := range verifyResultsShared {
-		qv := prettyQuery(verifyResult.Query)
-		if qw == qv && !verifyResultsShared[i].Resolved {
-			verifyResultsShared[i].Resolved = result.Resolved
-			verifyResultsShared[i].Summary = result.Summary
+	qw := s.prettyQuery(result.Query)
+	s.verifyResultsMutex.Lock()
+	for i, verifyResult := range s.verifyResults {
+		qv := s.prettyQuery(verifyResult.Query)
+		if qw == qv && !s.verifyResults[i].Resolved {
+			s.verifyResults[i].Resolved = result.Resolved
+			s.verifyResults[i].Summary = result.Summary
+			s.verifyResults[i].Options = result.Options
+			s.verifyResults[i].Stage = result.Stage
+			s.verifyResults[i].Trace = result.Trace
+			if result.Resolved {
+				s.verifyResults[i].Status = VerifyStatusAttackFound
+			}
 			written = true
 		}
 	}
-	verifyResultsMutex.Unlock()
+	s.verifyResultsMutex.Unlock()
 	return written
 }
 
-func verifyResultsAllResolved() bool {
+func (s *Session) verifyResultsAllResolved() bool {
 	allResolved := true
-	verifyResultsMutex.Lock()
-	for _, verifyResult := range verifyResultsShared {
+	s.verifyResultsMutex.Lock()
+	for _, verifyResult := range s.verifyResults {
 		if !verifyResult.Resolved {
 			allResolved = false
 			break
 		}
 	}
-	verifyResultsMutex.Unlock()
+	s.verifyResultsMutex.Unlock()
 	return allResolved
 }
+
+// String returns the name of a VerifyStatus, as used in JSON output.
+func (status VerifyStatus) String() string {
+	switch status {
+	case VerifyStatusAttackFound:
+		return "attack"
+	case VerifyStatusVerified:
+		return "verified"
+	default:
+		return "inconclusive"
+	}
+}
+
+// MarshalText encodes a VerifyStatus as its name.
+func (status VerifyStatus) MarshalText() ([]byte, error) {
+	return []byte(status.String()), nil
+}
+
+// UnmarshalText decodes a VerifyStatus from its name.
+func (status *VerifyStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "attack":
+		*status = VerifyStatusAttackFound
+	case "verified":
+		*status = VerifyStatusVerified
+	case "inconclusive":
+		*status = VerifyStatusInconclusive
+	default:
+		return fmt.Errorf("invalid verification status (%s)", text)
+	}
+	return nil
+}